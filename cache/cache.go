@@ -0,0 +1,244 @@
+// Package cache persists parsed type information between runs so that large
+// binaries only need to have their DWARF parsed once.
+//
+// A cache file holds the name index of one binary and every proxy parsed
+// from it, and is named after that binary's build id, or a hash of its
+// contents when it has no build id.
+// Cache files are stamped with FormatVersion; files written by any other
+// version are ignored and rebuilt.
+package cache
+
+import (
+	"crypto/sha256"
+	"debug/dwarf"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jdginn/durins-door/explorer/plat"
+	"github.com/jdginn/durins-door/parser"
+)
+
+// Version of the on-disk format. Bump this whenever the serialized form of
+// any proxy changes.
+//
+// Version 2 added where the addresses of variables were found, and version 3
+// their linkage names.
+const FormatVersion = 3
+
+// Returned by Load when there is no usable cache file for a key
+var ErrMiss = errors.New("cache miss")
+
+// Returns the key identifying the binary at this path
+//
+// Prefers the build id stamped in by the linker since it is cheap to read and
+// survives stripping; falls back to hashing the contents of the file.
+func Key(fname string) (string, error) {
+	id, err := plat.BuildID(fname)
+	if err == nil {
+		return "buildid-" + hex.EncodeToString(id), nil
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256-" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Everything cached for a single binary
+//
+// Proxies are keyed by the offset of the entry they were built from. The
+// accessor methods are safe for concurrent use; the maps themselves may only
+// be touched directly while no other goroutine is using the contents.
+type Contents struct {
+	mux       sync.Mutex
+	Version   int                                   `json:"version"`
+	Key       string                                `json:"key"`
+	Types     map[dwarf.Offset]parser.TypeDefProxy  `json:"types"`
+	Variables map[dwarf.Offset]parser.VariableProxy `json:"variables"`
+	// The name index, or nil if none was cached; files without one are
+	// still valid
+	Index *parser.IndexData `json:"index,omitempty"`
+	// How many times anything was added, and how many of those additions
+	// were last stored
+	changes int
+	stored  int
+}
+
+// Returns empty contents for the binary identified by this key
+func NewContents(key string) *Contents {
+	return &Contents{
+		Version:   FormatVersion,
+		Key:       key,
+		Types:     make(map[dwarf.Offset]parser.TypeDefProxy),
+		Variables: make(map[dwarf.Offset]parser.VariableProxy),
+	}
+}
+
+// Returns the cached proxy for the variable entry at this offset
+func (c *Contents) Variable(offset dwarf.Offset) (parser.VariableProxy, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	p, ok := c.Variables[offset]
+	return p, ok
+}
+
+// Caches the proxy for the variable entry at this offset
+func (c *Contents) AddVariable(offset dwarf.Offset, p parser.VariableProxy) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.Variables[offset] = p
+	c.changes++
+}
+
+// Returns the cached proxy for the type entry at this offset
+func (c *Contents) Type(offset dwarf.Offset) (parser.TypeDefProxy, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	p, ok := c.Types[offset]
+	return p, ok
+}
+
+// Caches the proxy for the type entry at this offset
+func (c *Contents) AddType(offset dwarf.Offset, p parser.TypeDefProxy) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.Types[offset] = p
+	c.changes++
+}
+
+// Returns the cached name index, or nil if there is none
+func (c *Contents) GetIndex() *parser.IndexData {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.Index
+}
+
+// Caches the name index
+func (c *Contents) SetIndex(d *parser.IndexData) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.Index = d
+	c.changes++
+}
+
+// Reports whether anything was added to the contents since they were loaded
+// or last stored
+func (c *Contents) Modified() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.changes != c.stored
+}
+
+// A directory of cache files
+type Cache struct {
+	mux sync.Mutex
+	dir string
+}
+
+// Returns a cache storing its files in dir, creating dir if necessary
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Returns a cache in the user's cache directory
+func NewDefault() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return New(filepath.Join(dir, "durins-door"))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Loads the contents cached for this key
+//
+// Returns ErrMiss if nothing is cached or if the cache file was written by
+// a different version of the format.
+func (c *Cache) Load(key string) (*Contents, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	b, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	contents := &Contents{}
+	if err := json.Unmarshal(b, contents); err != nil {
+		return nil, fmt.Errorf("Could not decode cache file %s: %v: %w", c.path(key), err, ErrMiss)
+	}
+	if contents.Version != FormatVersion || contents.Key != key {
+		return nil, ErrMiss
+	}
+	if contents.Types == nil {
+		contents.Types = make(map[dwarf.Offset]parser.TypeDefProxy)
+	}
+	if contents.Variables == nil {
+		contents.Variables = make(map[dwarf.Offset]parser.VariableProxy)
+	}
+	return contents, nil
+}
+
+// Writes these contents to the cache, replacing anything already cached for
+// the same key
+func (c *Cache) Store(contents *Contents) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	contents.mux.Lock()
+	b, err := json.Marshal(contents)
+	changes := contents.changes
+	contents.mux.Unlock()
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that readers never see a partially
+	// written cache
+	tmp, err := os.CreateTemp(c.dir, contents.Key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(contents.Key)); err != nil {
+		return err
+	}
+	contents.mux.Lock()
+	contents.stored = changes
+	contents.mux.Unlock()
+	return nil
+}
+
+// Loads the contents cached for this key, or returns empty contents if there
+// is nothing usable cached
+func (c *Cache) LoadOrNew(key string) (*Contents, error) {
+	contents, err := c.Load(key)
+	if errors.Is(err, ErrMiss) {
+		return NewContents(key), nil
+	}
+	return contents, err
+}
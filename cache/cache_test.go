@@ -0,0 +1,134 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/cache"
+	"github.com/jdginn/durins-door/explorer/plat"
+	"github.com/jdginn/durins-door/parser"
+)
+
+var testcaseFilename = "../testcase-compiler/testcase.dwarf"
+
+func TestKey(t *testing.T) {
+	key, err := cache.Key(testcaseFilename)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "buildid-"), key)
+
+	// Files without a build id are identified by their contents
+	fname := filepath.Join(t.TempDir(), "data.bin")
+	assert.NoError(t, os.WriteFile(fname, []byte("\xfe\xed\xbe\xef"), 0644))
+	key, err = cache.Key(fname)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "sha256-"), key)
+
+	_, err = cache.Key("invalid_file")
+	assert.Error(t, err)
+}
+
+func TestStoreLoad(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	key, err := cache.Key(testcaseFilename)
+	assert.NoError(t, err)
+
+	_, err = c.Load(key)
+	assert.ErrorIs(t, err, cache.ErrMiss)
+
+	fh, err := plat.GetReaderFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	data, err := parser.GetData(fh)
+	assert.NoError(t, err)
+	reader := data.Reader()
+	g := parser.NewTypeGraph(data)
+	e, _, err := parser.GetEntry(reader, "formula_1_teams")
+	assert.NoError(t, err)
+	v, err := parser.NewVariableProxy(g, e)
+	assert.NoError(t, err)
+	e, _, err = parser.GetEntry(reader, "Team")
+	assert.NoError(t, err)
+	team, err := parser.NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+
+	contents := cache.NewContents(key)
+	contents.Variables[1] = *v
+	contents.Types[2] = *team
+	assert.NoError(t, c.Store(contents))
+
+	loaded, err := c.Load(key)
+	assert.NoError(t, err)
+	expected, err := json.Marshal(contents)
+	assert.NoError(t, err)
+	actual, err := json.Marshal(loaded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+	assert.Equal(t, v.ListChildren(), loaded.Variables[1].ListChildren())
+	assert.Equal(t, team.ListChildren(), loaded.Types[2].ListChildren())
+}
+
+func TestVersionMismatch(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+
+	contents := cache.NewContents("key")
+	contents.Version = cache.FormatVersion + 1
+	assert.NoError(t, c.Store(contents))
+	_, err = c.Load("key")
+	assert.ErrorIs(t, err, cache.ErrMiss)
+
+	loaded, err := c.LoadOrNew("key")
+	assert.NoError(t, err)
+	assert.Equal(t, cache.NewContents("key"), loaded)
+}
+
+func TestVersion1IsMiss(t *testing.T) {
+	dir := t.TempDir()
+	c, err := cache.New(dir)
+	assert.NoError(t, err)
+
+	// Written before variables carried where their addresses were found
+	v1 := `{"version":1,"key":"key","types":{},"variables":{"1":{"name":"config","address":4096}}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "key.json"), []byte(v1), 0644))
+	_, err = c.Load("key")
+	assert.ErrorIs(t, err, cache.ErrMiss)
+}
+
+func TestVersion2IsMiss(t *testing.T) {
+	dir := t.TempDir()
+	c, err := cache.New(dir)
+	assert.NoError(t, err)
+
+	// Written before variables carried their linkage names
+	v2 := `{"version":2,"key":"key","types":{},"variables":{"1":{"name":"config","address":4096,"source":1}}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "key.json"), []byte(v2), 0644))
+	_, err = c.Load("key")
+	assert.ErrorIs(t, err, cache.ErrMiss)
+}
+
+func TestModified(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+
+	contents := cache.NewContents("key")
+	assert.False(t, contents.Modified())
+	contents.AddVariable(1, parser.VariableProxy{})
+	assert.True(t, contents.Modified())
+	assert.NoError(t, c.Store(contents))
+	assert.False(t, contents.Modified())
+
+	contents.SetIndex(&parser.IndexData{ByName: map[string][]parser.IndexEntry{
+		"config": {{Name: "config", Offset: 0x2a, CU: 0xb}},
+	}})
+	assert.True(t, contents.Modified())
+	assert.NoError(t, c.Store(contents))
+	loaded, err := c.Load("key")
+	assert.NoError(t, err)
+	assert.False(t, loaded.Modified())
+	assert.Equal(t, contents.GetIndex(), loaded.GetIndex())
+}
@@ -5,3 +5,16 @@ type Client interface {
 	Write(addr int, data []byte) error
 	SetOffset(offset int64)
 }
+
+// A RegisterClient is a client that can also supply the registers of the
+// thread being inspected, such as a client over a core file or a remote
+// debugging stub. Registers are keyed by their DWARF register number.
+//
+// None of the clients in this module implement it yet: the image and file
+// clients have no threads, and the process client reads memory without
+// stopping the process. Registers for them are given to the explorer with
+// SetRegisters, or with the regs command of the shell.
+type RegisterClient interface {
+	Client
+	Registers() (map[int]uint64, error)
+}
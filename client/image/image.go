@@ -0,0 +1,144 @@
+// Package image provides a client over the initial contents of memory as
+// described by a binary's loadable segments or sections, which lets the
+// values of initialized globals be read without running the program.
+package image
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+
+	"github.com/jdginn/durins-door/explorer/plat"
+	"github.com/jdginn/durins-door/parser"
+)
+
+// Returned by Write; an image is never modified
+var ErrReadOnly = errors.New("image is read-only")
+
+// A loadable segment. Bytes past the end of data and up to size are zero,
+// as for .bss.
+type segment struct {
+	addr uint64
+	size uint64
+	data []byte
+}
+
+// ImageClient reads memory from the segments of an ELF or Mach-O binary, or
+// the sections of a PE binary
+type ImageClient struct {
+	segments []segment
+	offset   int64
+}
+
+// Loads the segments of the binary at this path
+func NewFromPath(path string) (*ImageClient, error) {
+	return NewFromPathArch(path, "")
+}
+
+// Loads the segments of the binary at this path, taking those of a
+// universal Mach-O binary from its slice for arch as plat.OpenArch does
+func NewFromPathArch(path string, arch string) (*ImageClient, error) {
+	f, err := plat.OpenArch(path, arch)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch f := f.(type) {
+	case *parser.ELFFile:
+		return newFromELF(f.File)
+	case *macho.File:
+		return newFromMachO(f)
+	case *pe.File:
+		return newFromPE(f)
+	default:
+		return nil, fmt.Errorf("Could not open %s as an ELF, Mach-O or PE binary", path)
+	}
+}
+
+func newFromELF(f *elf.File) (*ImageClient, error) {
+	c := &ImageClient{}
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_LOAD {
+			continue
+		}
+		data := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(data, 0); err != nil {
+			return nil, fmt.Errorf("Could not read segment at 0x%x: %w", prog.Vaddr, err)
+		}
+		c.segments = append(c.segments, segment{prog.Vaddr, prog.Memsz, data})
+	}
+	return c, nil
+}
+
+func newFromMachO(f *macho.File) (*ImageClient, error) {
+	c := &ImageClient{}
+	for _, l := range f.Loads {
+		seg, ok := l.(*macho.Segment)
+		if !ok || seg.Memsz == 0 {
+			continue
+		}
+		data, err := seg.Data()
+		if err != nil {
+			return nil, fmt.Errorf("Could not read segment %s: %w", seg.Name, err)
+		}
+		c.segments = append(c.segments, segment{seg.Addr, seg.Memsz, data})
+	}
+	return c, nil
+}
+
+// Loads the sections of a PE binary at the image base it was linked for
+//
+// Sections the loader may discard, such as those holding DWARF, are left out.
+func newFromPE(f *pe.File) (*ImageClient, error) {
+	var base uint64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		base = uint64(h.ImageBase)
+	case *pe.OptionalHeader64:
+		base = h.ImageBase
+	}
+	c := &ImageClient{}
+	for _, s := range f.Sections {
+		if s.Characteristics&pe.IMAGE_SCN_MEM_DISCARDABLE != 0 || s.VirtualSize == 0 {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("Could not read section %s: %w", s.Name, err)
+		}
+		// Raw data is padded to the file alignment, past the section's end
+		if len(data) > int(s.VirtualSize) {
+			data = data[:s.VirtualSize]
+		}
+		c.segments = append(c.segments, segment{base + uint64(s.VirtualAddress), uint64(s.VirtualSize), data})
+	}
+	return c, nil
+}
+
+// Sets the load bias of the binary, which is subtracted from every address
+// before it is looked up in the image
+func (p *ImageClient) SetOffset(offset int64) {
+	p.offset = offset
+}
+
+func (p *ImageClient) Read(addr int, size int) ([]byte, error) {
+	start := uint64(int64(addr) - p.offset)
+	for _, s := range p.segments {
+		if start < s.addr || start+uint64(size) > s.addr+s.size {
+			continue
+		}
+		val := make([]byte, size)
+		rel := start - s.addr
+		if rel < uint64(len(s.data)) {
+			copy(val, s.data[rel:])
+		}
+		return val, nil
+	}
+	return nil, fmt.Errorf("Address range 0x%x-0x%x is not in any segment of the image", start, start+uint64(size))
+}
+
+func (p *ImageClient) Write(addr int, data []byte) error {
+	return ErrReadOnly
+}
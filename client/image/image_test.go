@@ -0,0 +1,97 @@
+package image_test
+
+import (
+	"debug/dwarf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/client"
+	"github.com/jdginn/durins-door/client/image"
+	"github.com/jdginn/durins-door/explorer/plat"
+	"github.com/jdginn/durins-door/parser"
+)
+
+var testcaseBinFile = "../../testcase-compiler/testcase.out"
+
+func wantsClient(c client.Client) {}
+
+func TestReadInitialValues(t *testing.T) {
+	c, err := image.NewFromPath(testcaseBinFile)
+	assert.NoError(t, err)
+	wantsClient(c)
+
+	fh, err := plat.GetReaderFromFile(testcaseBinFile)
+	assert.NoError(t, err)
+	data, err := parser.GetData(fh)
+	assert.NoError(t, err)
+	program, err := parser.NewProgram(data)
+	assert.NoError(t, err)
+	ie := program.Index().LookupTag("formula_1_teams", dwarf.TagVariable)
+	assert.Equal(t, 1, len(ie))
+	entry, err := program.Index().Entry(ie[0])
+	assert.NoError(t, err)
+	p, err := parser.NewVariableProxy(program.Types(), entry)
+	assert.NoError(t, err)
+	p.SetClient(c)
+
+	b, err := p.ReadField("[1].drivers[0]")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'L', 'H', 0, 0, 44, 0, 0, 0, 1, 0, 0, 0}, b)
+
+	assert.ErrorIs(t, p.WriteField("[1].drivers[0].car_number", []byte{1, 0, 0, 0}), image.ErrReadOnly)
+	_, err = c.Read(0, 4)
+	assert.Error(t, err)
+}
+
+func TestUniversalBinary(t *testing.T) {
+	// Each slice of a universal binary holds its own initial values
+	fat := "../../explorer/plat/testdata/formats/app.fat"
+	for _, arch := range []string{"arm64", "x86_64"} {
+		c, err := image.NewFromPathArch(fat, arch)
+		if !assert.NoError(t, err, arch) {
+			continue
+		}
+		fh, err := plat.OpenArch(fat, arch)
+		assert.NoError(t, err)
+		program, err := parser.NewProgramFromFile(fh, parser.SearchPaths{})
+		fh.Close()
+		assert.NoError(t, err)
+		e, _, err := program.Index().GetEntry("main.config")
+		assert.NoError(t, err)
+		v, err := program.Variable(e)
+		assert.NoError(t, err)
+		v.SetClient(c)
+		b, err := v.ReadField("Port")
+		assert.NoError(t, err, arch)
+		assert.Equal(t, []byte{0x90, 0x1f}, b, arch)
+	}
+}
+
+func TestPE(t *testing.T) {
+	exe := "../../explorer/plat/testdata/formats/app.exe"
+	c, err := image.NewFromPath(exe)
+	if !assert.NoError(t, err) {
+		return
+	}
+	fh, err := plat.GetReaderFromFile(exe)
+	assert.NoError(t, err)
+	program, err := parser.NewProgramFromFile(fh, parser.SearchPaths{})
+	fh.Close()
+	assert.NoError(t, err)
+	e, _, err := program.Index().GetEntry("main.config")
+	assert.NoError(t, err)
+	v, err := program.Variable(e)
+	assert.NoError(t, err)
+	v.SetClient(c)
+	b, err := v.ReadField("Port")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x90, 0x1f}, b)
+	b, err = v.ReadField("Name")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("durin\x00\x00\x00"), b)
+
+	// The DWARF is not part of the image
+	_, err = c.Read(v.Address+0x1000, 4)
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+)
+
+// ProcessClient reads and writes the memory of a running process through
+// /proc/<pid>/mem. Writing needs the same permissions as ptrace.
+type ProcessClient struct {
+	pid    int
+	mem    *os.File
+	offset int64
+}
+
+// Opens the memory of the process with this pid, read-only if it cannot be
+// opened for writing
+func New(pid int) (*ProcessClient, error) {
+	path := fmt.Sprintf("/proc/%d/mem", pid)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not open memory of process %d: %w", pid, err)
+	}
+	return &ProcessClient{
+		pid: pid,
+		mem: f,
+	}, nil
+}
+
+// Sets the load bias of the binary, which is added to every address before
+// it is accessed. Position-independent executables and shared libraries
+// are loaded at a bias chosen at run time; everything else has a bias of 0.
+func (p *ProcessClient) SetOffset(offset int64) {
+	p.offset = offset
+}
+
+func (p *ProcessClient) Read(addr int, size int) ([]byte, error) {
+	val := make([]byte, size)
+	n, err := p.mem.ReadAt(val, int64(addr)+p.offset)
+	if err != nil {
+		return val, fmt.Errorf("Could not read %d bytes at 0x%x in process %d: %w", size, addr, p.pid, err)
+	}
+	if n != size {
+		return val, fmt.Errorf("Read the incorrect number of bytes\n Expected: %d bytes; Read %d", size, n)
+	}
+	return val, nil
+}
+
+func (p *ProcessClient) Write(addr int, data []byte) error {
+	_, err := p.mem.WriteAt(data, int64(addr)+p.offset)
+	if err != nil {
+		return fmt.Errorf("Could not write %d bytes at 0x%x in process %d: %w", len(data), addr, p.pid, err)
+	}
+	return nil
+}
+
+// Closes the process's memory
+func (p *ProcessClient) Close() error {
+	return p.mem.Close()
+}
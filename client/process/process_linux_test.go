@@ -0,0 +1,42 @@
+//go:build linux
+
+package process_test
+
+import (
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/client"
+	"github.com/jdginn/durins-door/client/process"
+)
+
+func wantsClient(c client.Client) {}
+
+var target = [4]byte{0xfe, 0xed, 0xbe, 0xef}
+
+func TestReadWrite(t *testing.T) {
+	c, err := process.New(os.Getpid())
+	assert.NoError(t, err)
+	defer c.Close()
+	wantsClient(c)
+
+	addr := int(uintptr(unsafe.Pointer(&target[0])))
+	rdata, err := c.Read(addr, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\xfe\xed\xbe\xef"), rdata)
+
+	assert.NoError(t, c.Write(addr+1, []byte("\x00\x01")))
+	assert.Equal(t, [4]byte{0xfe, 0x00, 0x01, 0xef}, target)
+
+	// The load bias is added to every address
+	c.SetOffset(2)
+	rdata, err = c.Read(addr, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x01\xef"), rdata)
+
+	_, err = c.Read(0, 4)
+	assert.Error(t, err)
+}
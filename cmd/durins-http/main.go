@@ -0,0 +1,70 @@
+// Command durins-http serves the types and variables of one or more binaries
+// over HTTP.
+//
+// Usage:
+//
+//	durins-http -addr :8080 -binary fw=firmware.elf -client fw=memory.bin@0x20000000
+//
+// Each -binary flag loads a file under an ID. A -client flag gives the file
+// holding the memory described by the binary with that ID, optionally
+// followed by the address the start of the file corresponds to. Each
+// -debug-dir flag names a directory searched for the separate debug files
+// of stripped binaries, in place of /usr/lib/debug. An -arch flag chooses
+// the slice a universal Mach-O binary is read from, such as arm64 or x86_64,
+// in place of the host's architecture.
+package main
+
+import (
+	"flag"
+	"log"
+	nethttp "net/http"
+
+	"github.com/jdginn/durins-door/client"
+	"github.com/jdginn/durins-door/internal/flags"
+	"github.com/jdginn/durins-door/server"
+	"github.com/jdginn/durins-door/server/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	binaries := flags.Assignments{}
+	clients := flags.Assignments{}
+	flag.Var(binaries, "binary", "load the binary at `id=path`; may be repeated")
+	flag.Var(clients, "client", "read and write memory of binary id from `id=path[@offset]`; may be repeated")
+	arches := flags.Assignments{}
+	flag.Var(arches, "arch", "read universal Mach-O binary id for the architecture `id=name`; may be repeated")
+	var debugDirs flags.DirList
+	flag.Var(&debugDirs, "debug-dir", "look for separate debug files and split DWARF in `dir`; may be repeated")
+	flag.Parse()
+
+	s := http.NewServer()
+	for id, path := range binaries {
+		var c client.Client
+		if spec, ok := clients[id]; ok {
+			fc, err := flags.OpenFileClient(spec)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c = fc
+		}
+		b, err := server.LoadBinaryArch(id, path, arches[id], c, debugDirs...)
+		if err != nil {
+			log.Fatalf("Could not load %s: %v", path, err)
+		}
+		s.Add(b)
+		log.Printf("Loaded %s as %s", path, id)
+	}
+	for id := range clients {
+		if _, ok := binaries[id]; !ok {
+			log.Fatalf("Client given for unknown binary %s", id)
+		}
+	}
+	for id := range arches {
+		if _, ok := binaries[id]; !ok {
+			log.Fatalf("Architecture given for unknown binary %s", id)
+		}
+	}
+
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(nethttp.ListenAndServe(*addr, s))
+}
@@ -0,0 +1,84 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const biasTarget = "testdata/bias/target"
+
+// Returns the address the running executable at path was loaded at, which
+// is its load bias since a position-independent executable is linked at 0
+func loadBias(t *testing.T, pid int, path string) int64 {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 6 || fields[5] != abs || fields[2] != "00000000" {
+			continue
+		}
+		start := strings.SplitN(fields[0], "-", 2)[0]
+		bias, err := strconv.ParseInt(start, 16, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bias
+	}
+	t.Fatalf("%s is not mapped in process %d", abs, pid)
+	return 0
+}
+
+func TestBias(t *testing.T) {
+	cmd := exec.Command(biasTarget)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		stdin.Close()
+		cmd.Wait()
+	}()
+	// The target writes a byte once it is running
+	if _, err := stdout.Read(make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	bias := loadBias(t, pid, biasTarget)
+	assert.NotZero(t, bias)
+
+	// The process is read at the bias, the image at link-time addresses
+	out, err := durins(t, "-pid", strconv.Itoa(pid), "-bias", strconv.FormatInt(bias, 10), biasTarget, "read", "answer")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^answer = 42 \(0x2a\) at 0x[0-9a-f]+\n$`, out)
+	imageOut, err := durins(t, "-image", biasTarget, "read", "answer")
+	assert.NoError(t, err)
+	assert.Equal(t, out, imageOut)
+
+	_, err = durins(t, "-image", "-bias", strconv.FormatInt(bias, 10), biasTarget, "read", "answer")
+	assert.ErrorIs(t, err, errUsage)
+}
@@ -0,0 +1,565 @@
+// Command durins queries the types and variables of a binary and reads and
+// writes their values.
+//
+// Usage:
+//
+//	durins [flags] <binary> <command> [args]
+//
+// Commands:
+//
+//	cus                   list compile units
+//	find <name>           list entries with this name
+//	type <name>           describe a type and its members
+//	layout <name>         tabulate a type's members, padding and cachelines
+//	var <name>            describe a global variable
+//	read <path>           read a variable or field, e.g. teams[1].drivers[0]
+//	write <path>=<value>  write an integer, or hex bytes with -hex
+//	files                 list the source files of each compile unit
+//	line <address>        show the source line containing an address
+//	addrs <file>:<line>   list the addresses where a source line starts
+//	symbol <address>      name the variable, member and element at an address
+//	repl                  explore the binary interactively
+//	arches                list the architectures of a Mach-O binary
+//	diff <binary> [name]  compare the types and variables of another binary
+//
+// Values are read from and written to one of: a file holding the memory
+// described by the binary (-mem), a running process (-pid), or the initial
+// contents of memory stored in the binary itself (-image). Output is text
+// unless -json is given.
+//
+// The DWARF of a stripped binary is read from its separate debug file, found
+// by build id or .gnu_debuglink under each -debug-dir (by default
+// /usr/lib/debug) and next to the binary, while -image still reads the
+// binary given.
+//
+// Universal Mach-O binaries are read from the slice for the host's
+// architecture, or the one named by -arch, such as arm64 or x86_64.
+//
+// layout marks cacheline boundaries every 64 bytes, or every -cacheline
+// bytes.
+//
+// diff reports the types and global variables added, removed or changed in
+// the second binary, or only those named, and exits with status 1 if any
+// change breaks the ABI of the first.
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jdginn/durins-door/client"
+	"github.com/jdginn/durins-door/client/image"
+	"github.com/jdginn/durins-door/client/process"
+	"github.com/jdginn/durins-door/explorer"
+	"github.com/jdginn/durins-door/explorer/plat"
+	"github.com/jdginn/durins-door/internal/flags"
+	"github.com/jdginn/durins-door/parser"
+	"github.com/jdginn/durins-door/repl"
+	"github.com/jdginn/durins-door/server"
+
+	"golang.org/x/term"
+)
+
+// Returned when the command line cannot be understood
+var errUsage = errors.New("usage: durins [flags] <binary> <command> [args]")
+
+// Returned by diff when the second binary breaks the ABI of the first
+var errIncompatible = errors.New("ABI is incompatible")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	json      bool
+	mem       string
+	pid       int
+	image     bool
+	bias      int64
+	hex       bool
+	debugDirs flags.DirList
+	arch      string
+	cacheline int
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var opts options
+	fs := flag.NewFlagSet("durins", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&opts.json, "json", false, "print JSON instead of text")
+	fs.StringVar(&opts.mem, "mem", "", "access memory in the file at `path[@offset]`, where offset is the address of its first byte")
+	fs.IntVar(&opts.pid, "pid", 0, "access the memory of the running process with this `pid`")
+	fs.BoolVar(&opts.image, "image", false, "read the initial values stored in the binary")
+	fs.Int64Var(&opts.bias, "bias", 0, "load `bias` of the binary in the process")
+	fs.BoolVar(&opts.hex, "hex", false, "write values given as hex bytes in target memory order")
+	fs.Var(&opts.debugDirs, "debug-dir", "look for separate debug files and split DWARF in `dir`; may be repeated")
+	fs.StringVar(&opts.arch, "arch", "", "read universal Mach-O binaries for the architecture `name`, such as arm64 or x86_64")
+	fs.IntVar(&opts.cacheline, "cacheline", parser.DefaultCachelineSize, "mark cacheline boundaries every `size` bytes in layouts")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < 2 {
+		return errUsage
+	}
+	path, cmd, cmdArgs := fs.Arg(0), fs.Arg(1), fs.Args()[2:]
+
+	var out output = textOutput{stdout}
+	if opts.json {
+		out = jsonOutput{stdout}
+	}
+	if cmd == "arches" && len(cmdArgs) == 0 {
+		arches, err := plat.Arches(path)
+		if err != nil {
+			return err
+		}
+		return out.arches(arches)
+	}
+
+	c, err := openClient(path, opts)
+	if err != nil {
+		return err
+	}
+	if cmd == "repl" && len(cmdArgs) == 0 {
+		return runREPL(path, opts, c, stdin, stdout)
+	}
+	b, err := server.LoadBinaryArch(path, path, opts.arch, c, opts.debugDirs...)
+	if err != nil {
+		return fmt.Errorf("Could not load %s: %w", path, err)
+	}
+	for _, err := range b.Program.MissingDwo() {
+		fmt.Fprintf(stderr, "Warning: %v\n", err)
+	}
+
+	switch {
+	case cmd == "cus" && len(cmdArgs) == 0:
+		cus := b.Program.Index().CUs()
+		names := make([]string, len(cus))
+		for i, cu := range cus {
+			names[i] = cu.Name
+		}
+		return out.cus(names)
+	case cmd == "find" && len(cmdArgs) == 1:
+		return out.entries(b.Lookup(cmdArgs[0], ""))
+	case cmd == "type" && len(cmdArgs) == 1:
+		p, err := b.Type(cmdArgs[0])
+		if err != nil {
+			return err
+		}
+		return out.typeDef(*p)
+	case cmd == "layout" && len(cmdArgs) == 1:
+		p, err := b.Type(cmdArgs[0])
+		if err != nil {
+			return err
+		}
+		l, err := p.Layout(opts.cacheline)
+		if err != nil {
+			return err
+		}
+		return out.layout(l)
+	case cmd == "var" && len(cmdArgs) == 1:
+		p, err := b.Variable(cmdArgs[0])
+		if err != nil {
+			return err
+		}
+		return out.variable(*p)
+	case cmd == "read" && len(cmdArgs) == 1:
+		v, err := b.Read(cmdArgs[0])
+		if err != nil {
+			return err
+		}
+		return out.value(v)
+	case cmd == "write" && len(cmdArgs) == 1:
+		v, err := write(b, cmdArgs[0], opts.hex)
+		if err != nil {
+			return err
+		}
+		return out.value(v)
+	case cmd == "files" && len(cmdArgs) == 0:
+		files, err := b.Files()
+		if err != nil {
+			return err
+		}
+		return out.files(files)
+	case cmd == "line" && len(cmdArgs) == 1:
+		addr, err := server.ParseAddress(cmdArgs[0])
+		if err != nil {
+			return err
+		}
+		l, err := b.Program.AddrToLine(addr)
+		if err != nil {
+			return err
+		}
+		return out.sourceLine(l)
+	case cmd == "addrs" && len(cmdArgs) == 1:
+		file, line, err := server.ParseFileLine(cmdArgs[0])
+		if err != nil {
+			return err
+		}
+		addrs, err := b.Program.LineToAddrs(file, line)
+		if err != nil {
+			return err
+		}
+		return out.addresses(addrs)
+	case cmd == "symbol" && len(cmdArgs) == 1:
+		addr, err := server.ParseAddress(cmdArgs[0])
+		if err != nil {
+			return err
+		}
+		sym, err := b.Program.Symbolize(addr)
+		if err != nil {
+			return err
+		}
+		return out.symbol(sym)
+	case cmd == "diff" && len(cmdArgs) >= 1:
+		other, err := explorer.LoadProgram(cmdArgs[0], opts.arch, opts.debugDirs)
+		if err != nil {
+			return fmt.Errorf("Could not load %s: %w", cmdArgs[0], err)
+		}
+		r, err := parser.DiffABI(b.Program, other, cmdArgs[1:]...)
+		if err != nil {
+			return err
+		}
+		if err := out.abiReport(r); err != nil {
+			return err
+		}
+		if !r.Compatible {
+			return errIncompatible
+		}
+		return nil
+	default:
+		return fmt.Errorf("Bad command %q: %w", strings.Join(fs.Args()[1:], " "), errUsage)
+	}
+}
+
+// Runs the shell, with line editing and completion if stdin is a terminal
+func runREPL(path string, opts options, c client.Client, stdin io.Reader, stdout io.Writer) error {
+	ex := explorer.NewExplorer()
+	ex.DebugDirs = opts.debugDirs
+	ex.Arch = opts.arch
+	if err := ex.CreateReaderFromFile(path); err != nil {
+		return fmt.Errorf("Could not load %s: %w", path, err)
+	}
+	if c != nil {
+		ex.SetClient(c)
+	}
+	r := repl.New(ex, stdout)
+	f, ok := stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return r.Run(stdin)
+	}
+	state, err := term.MakeRaw(int(f.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(f.Fd()), state)
+	return r.RunTerminal(term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{f, stdout}, ""))
+}
+
+// Returns the client selected by the options, or nil if none was
+func openClient(path string, opts options) (client.Client, error) {
+	n := 0
+	for _, set := range []bool{opts.mem != "", opts.pid != 0, opts.image} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return nil, fmt.Errorf("At most one of -mem, -pid and -image may be given: %w", errUsage)
+	}
+	switch {
+	case opts.mem != "":
+		return flags.OpenFileClient(opts.mem)
+	case opts.pid != 0:
+		c, err := process.New(opts.pid)
+		if err != nil {
+			return nil, err
+		}
+		c.SetOffset(opts.bias)
+		return c, nil
+	case opts.image:
+		// The image is laid out at the addresses the binary was linked at
+		if opts.bias != 0 {
+			return nil, fmt.Errorf("-bias does not apply to -image: %w", errUsage)
+		}
+		c, err := image.NewFromPathArch(path, opts.arch)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Writes an assignment of the form path=value
+func write(b *server.Binary, assignment string, isHex bool) (server.Value, error) {
+	eq := strings.LastIndex(assignment, "=")
+	if eq <= 0 {
+		return server.Value{}, fmt.Errorf("Expected path=value, got %q: %w", assignment, errUsage)
+	}
+	path, value := assignment[:eq], assignment[eq+1:]
+	if isHex {
+		data, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil {
+			return server.Value{}, fmt.Errorf("Bad hex value %q: %v", value, err)
+		}
+		return b.Write(path, data)
+	}
+	f, err := b.Field(path)
+	if err != nil {
+		return server.Value{}, err
+	}
+	data, err := parser.ParseInt(value, (f.BitSize+7)/8, b.Program.ByteOrder())
+	if err != nil {
+		return server.Value{}, err
+	}
+	return b.Write(path, data)
+}
+
+// An output prints the result of each command
+type output interface {
+	cus(names []string) error
+	entries(entries []parser.IndexEntry) error
+	typeDef(p parser.TypeDefProxy) error
+	layout(l *parser.Layout) error
+	abiReport(r *parser.ABIReport) error
+	variable(p parser.VariableProxy) error
+	value(v server.Value) error
+	files(files []server.CUFiles) error
+	sourceLine(l parser.SourceLine) error
+	addresses(addrs []uint64) error
+	symbol(s parser.Symbol) error
+	arches(names []string) error
+}
+
+type textOutput struct {
+	w io.Writer
+}
+
+func (o textOutput) cus(names []string) error {
+	for _, n := range names {
+		fmt.Fprintln(o.w, n)
+	}
+	return nil
+}
+
+func (o textOutput) arches(names []string) error {
+	return o.cus(names)
+}
+
+func (o textOutput) entries(entries []parser.IndexEntry) error {
+	tw := tabwriter.NewWriter(o.w, 0, 8, 2, ' ', 0)
+	for _, ie := range entries {
+		fmt.Fprintf(tw, "0x%x\t%s\t%s\n", ie.Offset, ie.Tag, ie.Name)
+	}
+	return tw.Flush()
+}
+
+func (o textOutput) typeDef(p parser.TypeDefProxy) error {
+	tw := tabwriter.NewWriter(o.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s%s\t\tsize %d\n", p.Name(), dimsString(p.ArrayRanges()), p.BitSize()/8)
+	if err := printMembers(tw, p, "  "); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func (o textOutput) layout(l *parser.Layout) error {
+	_, err := fmt.Fprint(o.w, l)
+	return err
+}
+
+func (o textOutput) abiReport(r *parser.ABIReport) error {
+	_, err := fmt.Fprint(o.w, r)
+	return err
+}
+
+func printMembers(w io.Writer, p parser.TypeDefProxy, indent string) error {
+	members, err := p.Members()
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		fmt.Fprintf(w, "%s%s%s\toffset %d\tsize %d\n", indent, m.Name(), dimsString(m.ArrayRanges()), m.BitOffset()/8, m.BitSize()/8)
+		if err := printMembers(w, m, indent+"  "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dimsString(dims []int) string {
+	var sb strings.Builder
+	for _, d := range dims {
+		fmt.Fprintf(&sb, "[%d]", d)
+	}
+	return sb.String()
+}
+
+func (o textOutput) variable(p parser.VariableProxy) error {
+	fmt.Fprintf(o.w, "%s\t0x%x\t%s%s", p.Name(), p.Address, p.Type.Name(), dimsString(p.Type.ArrayRanges()))
+	if p.Source != parser.SourceDWARF {
+		fmt.Fprintf(o.w, "\t(from %s)", p.Source)
+	}
+	fmt.Fprintln(o.w)
+	return nil
+}
+
+func (o textOutput) value(v server.Value) error {
+	if v.Uint != nil {
+		fmt.Fprintf(o.w, "%s = %d (0x%x) at 0x%x\n", v.Path, *v.Uint, *v.Uint, v.Address)
+	} else {
+		fmt.Fprintf(o.w, "%s = %s at 0x%x\n", v.Path, hex.EncodeToString(v.Bytes), v.Address)
+	}
+	return nil
+}
+
+func (o textOutput) files(files []server.CUFiles) error {
+	for _, cu := range files {
+		fmt.Fprintf(o.w, "%s:\n", cu.CU)
+		for _, f := range cu.Files {
+			if f != "" {
+				fmt.Fprintf(o.w, "  %s\n", f)
+			}
+		}
+	}
+	return nil
+}
+
+func (o textOutput) sourceLine(l parser.SourceLine) error {
+	fmt.Fprintf(o.w, "%s:%d:%d at 0x%x\n", l.File, l.Line, l.Column, l.Address)
+	return nil
+}
+
+func (o textOutput) addresses(addrs []uint64) error {
+	for _, a := range addrs {
+		fmt.Fprintf(o.w, "0x%x\n", a)
+	}
+	return nil
+}
+
+func (o textOutput) symbol(s parser.Symbol) error {
+	fmt.Fprintln(o.w, s)
+	return nil
+}
+
+type jsonOutput struct {
+	w io.Writer
+}
+
+type entryJSON struct {
+	Name   string `json:"name"`
+	Tag    string `json:"tag"`
+	Offset int64  `json:"offset"`
+	CU     int64  `json:"cu"`
+}
+
+type valueJSON struct {
+	Path    string  `json:"path"`
+	Address int     `json:"address"`
+	Bytes   string  `json:"bytes"`
+	Value   *uint64 `json:"value,omitempty"`
+}
+
+type cuFilesJSON struct {
+	CU    string   `json:"cu"`
+	Files []string `json:"files"`
+}
+
+type sourceLineJSON struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Address uint64 `json:"address"`
+}
+
+type symbolJSON struct {
+	Symbol   string `json:"symbol"`
+	Variable string `json:"variable"`
+	Address  uint64 `json:"address"`
+	Path     string `json:"path"`
+	Offset   int    `json:"offset"`
+}
+
+func (o jsonOutput) encode(v interface{}) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (o jsonOutput) cus(names []string) error {
+	return o.encode(names)
+}
+
+func (o jsonOutput) arches(names []string) error {
+	return o.encode(names)
+}
+
+func (o jsonOutput) entries(entries []parser.IndexEntry) error {
+	ret := make([]entryJSON, len(entries))
+	for i, ie := range entries {
+		ret[i] = entryJSON{ie.Name, ie.Tag.String(), int64(ie.Offset), int64(ie.CU)}
+	}
+	return o.encode(ret)
+}
+
+func (o jsonOutput) typeDef(p parser.TypeDefProxy) error {
+	return o.encode(p)
+}
+
+func (o jsonOutput) layout(l *parser.Layout) error {
+	return o.encode(l)
+}
+
+func (o jsonOutput) abiReport(r *parser.ABIReport) error {
+	return o.encode(r)
+}
+
+func (o jsonOutput) variable(p parser.VariableProxy) error {
+	return o.encode(p)
+}
+
+func (o jsonOutput) value(v server.Value) error {
+	return o.encode(valueJSON{
+		Path:    v.Path,
+		Address: v.Address,
+		Bytes:   hex.EncodeToString(v.Bytes),
+		Value:   v.Uint,
+	})
+}
+
+func (o jsonOutput) files(files []server.CUFiles) error {
+	ret := make([]cuFilesJSON, len(files))
+	for i, f := range files {
+		ret[i] = cuFilesJSON{f.CU, f.Files}
+	}
+	return o.encode(ret)
+}
+
+func (o jsonOutput) sourceLine(l parser.SourceLine) error {
+	return o.encode(sourceLineJSON{l.File, l.Line, l.Column, l.Address})
+}
+
+func (o jsonOutput) addresses(addrs []uint64) error {
+	return o.encode(addrs)
+}
+
+func (o jsonOutput) symbol(s parser.Symbol) error {
+	return o.encode(symbolJSON{s.String(), s.Variable, s.Address, s.Path, s.Offset})
+}
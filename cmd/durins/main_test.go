@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/explorer/plat"
+	"github.com/jdginn/durins-door/parser"
+)
+
+var testcaseBinFile = "../../testcase-compiler/testcase.out"
+
+func durins(t *testing.T, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(args, strings.NewReader(""), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestQueries(t *testing.T) {
+	out, err := durins(t, testcaseBinFile, "cus")
+	assert.NoError(t, err)
+	assert.Equal(t, "testcase.cpp\n", out)
+
+	out, err = durins(t, testcaseBinFile, "find", "has_won_wdc")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(out, "Member"))
+
+	out, err = durins(t, testcaseBinFile, "type", "Team")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "Team")
+	assert.Regexp(t, `\n  drivers\[2\] +offset 0 +size 12\n    initials\[2\] +offset 0 +size 1\n`, out)
+	assert.Regexp(t, `\n  last_wcc +offset 44 +size 4\n$`, out)
+
+	out, err = durins(t, testcaseBinFile, "var", "formula_1_teams")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^formula_1_teams\t0x[0-9a-f]+\tTeam\[2\]\n$`, out)
+
+	out, err = durins(t, "-json", testcaseBinFile, "type", "Driver")
+	assert.NoError(t, err)
+	var driver parser.TypeDefProxy
+	assert.NoError(t, json.Unmarshal([]byte(out), &driver))
+	assert.Equal(t, []string{"initials", "car_number", "has_won_wdc"}, driver.ListChildren())
+}
+
+func TestLayout(t *testing.T) {
+	out, err := durins(t, testcaseBinFile, "layout", "Team")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^Team: size 48, align 4\n`, out)
+	assert.Regexp(t, `\n0 +24 +4 +drivers +Driver\[2\]\n`, out)
+	assert.Regexp(t, `\n33 +3 +<hole>\n`, out)
+	assert.Contains(t, out, "holes: 2, sum holes: 6, padding: 0, cachelines: 1 (64 bytes)")
+
+	out, err = durins(t, "-json", "-cacheline", "16", testcaseBinFile, "layout", "Team")
+	assert.NoError(t, err)
+	var l parser.Layout
+	assert.NoError(t, json.Unmarshal([]byte(out), &l))
+	assert.Equal(t, 3, l.Cachelines)
+	assert.Equal(t, 6, l.Members)
+	assert.Equal(t, 48, l.HoleBits)
+	assert.Contains(t, out, `"kind": "cacheline"`)
+}
+
+func TestDiff(t *testing.T) {
+	v1, v2 := "../../parser/testdata/abi/v1.o", "../../parser/testdata/abi/v2.o"
+	out, err := durins(t, v1, "diff", v2)
+	assert.ErrorIs(t, err, errIncompatible)
+	assert.Contains(t, out, "! struct Config.crc: offset 16 -> 20\n")
+	assert.Regexp(t, `\nincompatible: \d+ changes, \d+ breaking\n$`, out)
+
+	out, err = durins(t, "-json", v1, "diff", v2, "Header", "variable timeout")
+	assert.NoError(t, err)
+	var r parser.ABIReport
+	assert.NoError(t, json.Unmarshal([]byte(out), &r))
+	assert.True(t, r.Compatible)
+	assert.Equal(t, []parser.ABIChange{{Kind: parser.ABIAdded, Entity: "variable timeout"}}, r.Changes)
+
+	_, err = durins(t, v1, "diff")
+	assert.ErrorIs(t, err, errUsage)
+}
+
+func TestSourceLines(t *testing.T) {
+	out, err := durins(t, testcaseBinFile, "files")
+	assert.NoError(t, err)
+	assert.Equal(t, "testcase.cpp:\n  /tmp/tc/testcase.cpp\n", out)
+
+	out, err = durins(t, testcaseBinFile, "line", "0x401114")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/tc/testcase.cpp:30:10 at 0x401110\n", out)
+
+	out, err = durins(t, "-json", testcaseBinFile, "addrs", "testcase.cpp:29")
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n  4198672\n]\n", out)
+
+	_, err = durins(t, testcaseBinFile, "addrs", "testcase.cpp")
+	assert.ErrorIs(t, err, parser.ErrBadValue)
+	_, err = durins(t, testcaseBinFile, "line", "0")
+	assert.ErrorIs(t, err, parser.ErrNotFound)
+}
+
+func TestSymbol(t *testing.T) {
+	out, err := durins(t, "-json", testcaseBinFile, "var", "formula_1_teams")
+	assert.NoError(t, err)
+	var teams parser.VariableProxy
+	assert.NoError(t, json.Unmarshal([]byte(out), &teams))
+
+	out, err = durins(t, testcaseBinFile, "symbol", fmt.Sprintf("%#x", teams.Address+48+12+6))
+	assert.NoError(t, err)
+	assert.Equal(t, "formula_1_teams[1].drivers[1].car_number + 2\n", out)
+	_, err = durins(t, testcaseBinFile, "symbol", "0x10")
+	assert.ErrorIs(t, err, parser.ErrNotFound)
+}
+
+func TestReadWrite(t *testing.T) {
+	out, err := durins(t, "-image", testcaseBinFile, "read", "formula_1_teams[1].drivers[0].car_number")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^formula_1_teams\[1\]\.drivers\[0\]\.car_number = 44 \(0x2c\) at 0x[0-9a-f]+\n$`, out)
+
+	out, err = durins(t, "-image", "-json", testcaseBinFile, "read", "formula_1_teams[1].drivers[0].initials")
+	assert.NoError(t, err)
+	var value map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &value))
+	assert.Equal(t, "4c48", value["bytes"])
+
+	_, err = durins(t, testcaseBinFile, "read", "formula_1_teams")
+	assert.ErrorIs(t, err, parser.ErrNoClient)
+
+	// Serve memory from a scratch file starting at address 0
+	mem := filepath.Join(t.TempDir(), "memory.bin")
+	assert.NoError(t, os.WriteFile(mem, make([]byte, 0x10000), 0644))
+	out, err = durins(t, "-json", "-mem", mem, testcaseBinFile, "var", "hamilton")
+	assert.NoError(t, err)
+	var hamilton struct {
+		Address int `json:"address"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out), &hamilton))
+	spec := fmt.Sprintf("%s@%d", mem, hamilton.Address)
+
+	_, err = durins(t, "-mem", spec, testcaseBinFile, "write", "hamilton.car_number=-1")
+	assert.NoError(t, err)
+	_, err = durins(t, "-mem", spec, "-hex", testcaseBinFile, "write", "hamilton.initials=4c48")
+	assert.NoError(t, err)
+	out, err = durins(t, "-mem", spec, testcaseBinFile, "read", "hamilton")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "hamilton = 4c480000ffffffff00000000 at ")
+}
+
+func TestStripped(t *testing.T) {
+	// The DWARF comes from the debug file, the initial values from the
+	// stripped binary itself
+	stripped := "../../explorer/plat/testdata/app"
+	out, err := durins(t, "-image", "-debug-dir", "../../explorer/plat/testdata/debug", stripped, "read", "config.port")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^config\.port = 8080 \(0x1f90\) at 0x[0-9a-f]+\n$`, out)
+}
+
+func TestArches(t *testing.T) {
+	fat := "../../explorer/plat/testdata/formats/app.fat"
+	out, err := durins(t, fat, "arches")
+	assert.NoError(t, err)
+	assert.Equal(t, "x86_64\narm64\n", out)
+
+	// Each slice places the variable at its own address
+	arm64, err := durins(t, "-arch", "arm64", fat, "var", "main.config")
+	assert.NoError(t, err)
+	x86, err := durins(t, "-arch", "x86_64", fat, "var", "main.config")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^main\.config\t0x[0-9a-f]+\t`, arm64)
+	assert.NotEqual(t, arm64, x86)
+
+	_, err = durins(t, "-arch", "ppc", fat, "cus")
+	assert.ErrorIs(t, err, plat.ErrNoArch)
+}
+
+func TestMissingDwo(t *testing.T) {
+	// A binary moved away from its .dwo files still loads
+	b, err := os.ReadFile("../../parser/testdata/split/dwarf5/split.out")
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "split.out")
+	assert.NoError(t, os.WriteFile(path, b, 0o644))
+
+	var stdout, stderr bytes.Buffer
+	// main, whose line table stays with the skeleton
+	assert.NoError(t, run([]string{path, "line", "0x1178"}, strings.NewReader(""), &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "main.cpp:")
+	assert.Contains(t, stderr.String(), "Warning: No .dwo file with id")
+	assert.Contains(t, stderr.String(), "dwarf5/main.dwo")
+}
+
+func TestUsage(t *testing.T) {
+	_, err := durins(t)
+	assert.ErrorIs(t, err, errUsage)
+	_, err = durins(t, testcaseBinFile, "frobnicate")
+	assert.ErrorIs(t, err, errUsage)
+	_, err = durins(t, testcaseBinFile, "write", "hamilton")
+	assert.ErrorIs(t, err, errUsage)
+	_, err = durins(t, "-image", "-pid", "1", testcaseBinFile, "cus")
+	assert.ErrorIs(t, err, errUsage)
+	_, err = durins(t, testcaseBinFile, "type", "nosuchtype")
+	assert.ErrorIs(t, err, parser.ErrNotFound)
+}
+
+func TestREPL(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := strings.NewReader("cd testcase.cpp/hamilton\nread car_number\n")
+	assert.NoError(t, run([]string{"-image", testcaseBinFile, "repl"}, in, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "hamilton> 44 (0x2c) at 0x")
+}
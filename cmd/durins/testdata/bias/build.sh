@@ -0,0 +1,7 @@
+#!/bin/sh
+# Rebuilds target, a position-independent executable that waits on stdin so
+# that a test can read its memory at the load bias chosen at run time.
+set -e
+cd "$(dirname "$0")"
+
+gcc -g -O0 -fPIE -pie -fdebug-prefix-map="$PWD"=. target.c -o target
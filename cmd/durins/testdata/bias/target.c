@@ -0,0 +1,13 @@
+#include <unistd.h>
+
+/* Read through the running process and through the image of the binary */
+int answer = 42;
+
+int main(void) {
+    char c = '\n';
+    /* Tell the test we are running, then stay alive until stdin is closed */
+    write(1, &c, 1);
+    while (read(0, &c, 1) > 0)
+        ;
+    return answer;
+}
@@ -2,7 +2,7 @@ package explorer
 
 import (
 	"debug/dwarf"
-	// "sync"
+	"sync"
 
 	"github.com/jdginn/durins-door/parser"
 )
@@ -19,52 +19,80 @@ type ctxLevel struct {
 	mode  mode
 	entry *dwarf.Entry
 	proxy parser.Proxy
+	// How the proxy was reached from the level below, written as part of a
+	// path: the variable's name, then ".member" or "[index]"
+	step string
+	// Set when the proxy is the type of the level below it rather than a
+	// member of it
+	isType bool
+	// The module of a workspace the entry belongs to, or nil outside one
+	module *Module
 }
 
 type stack struct {
-	// mux    sync.Mutex
+	mux    sync.Mutex
 	levels []ctxLevel
 }
 
 func NewStack() *stack {
-	// s := stack{sync.Mutex{}, make([]ctxLevel, 0, 128)}
-	s := &stack{make([]ctxLevel, 0, 128)}
+	s := &stack{levels: make([]ctxLevel, 0, 128)}
 	s.Push(modeCUs, nil, nil)
 	return s
 }
 
 // Returns the current entry pointed to by this context
 func (c *stack) CurrEntry() *dwarf.Entry {
-	// c.mux.Lock()
-	// defer c.mux.Unlock()
+	c.mux.Lock()
+	defer c.mux.Unlock()
 	return c.levels[len(c.levels)-1].entry
 }
 
 // Returns the current entry pointed to by this context
 func (c *stack) CurrProxy() parser.Proxy {
-	// c.mux.Lock()
-	// defer c.mux.Unlock()
+	c.mux.Lock()
+	defer c.mux.Unlock()
 	return c.levels[len(c.levels)-1].proxy
 }
 
 func (c *stack) CurrMode() mode {
+	c.mux.Lock()
+	defer c.mux.Unlock()
 	if len(c.levels) < 1 {
 		return -1
 	}
-	// c.mux.Lock()
-	// defer c.mux.Unlock()
 	return c.levels[len(c.levels)-1].mode
 }
 
 func (c *stack) Push(m mode, e *dwarf.Entry, p parser.Proxy) {
-	// c.mux.Lock()
-	// defer c.mux.Unlock()
-	c.levels = append(c.levels, ctxLevel{m, e, p})
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.levels = append(c.levels, ctxLevel{m, e, p, "", false, nil})
+}
+
+// Pushes an entry of this module of a workspace
+func (c *stack) PushEntry(e *dwarf.Entry, module *Module) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.levels = append(c.levels, ctxLevel{modeEntry, e, nil, "", false, module})
+}
+
+// Pushes a proxy reached from the current item by this step
+func (c *stack) PushField(p parser.Proxy, step string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.levels = append(c.levels, ctxLevel{modeProxy, nil, p, step, false, nil})
+}
+
+// Pushes the type of the current item
+func (c *stack) PushType(p parser.Proxy) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.levels = append(c.levels, ctxLevel{modeProxy, nil, p, "", true, nil})
 }
 
 func (c *stack) Pop() (ctxLevel, bool) {
-	// c.mux.Lock()
-	// defer c.mux.Unlock()
+	c.mux.Lock()
+	defer c.mux.Unlock()
 	var level ctxLevel
 	if len(c.levels) == 0 {
 		return ctxLevel{}, false
@@ -72,3 +100,19 @@ func (c *stack) Pop() (ctxLevel, bool) {
 	level, c.levels = c.levels[len(c.levels)-1], c.levels[:len(c.levels)-1]
 	return level, true
 }
+
+// Returns a copy of every level of this context, from the root up
+func (c *stack) Levels() []ctxLevel {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	ret := make([]ctxLevel, len(c.levels))
+	copy(ret, c.levels)
+	return ret
+}
+
+// Replaces every level of this context with a copy of these levels
+func (c *stack) Restore(levels []ctxLevel) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.levels = append(c.levels[:0], levels...)
+}
@@ -0,0 +1,24 @@
+package explorer
+
+import (
+	"errors"
+)
+
+// Errors returned by the explorer. Errors from the parser are passed through
+// unchanged, so callers can also match parser.ErrNotFound and friends.
+var (
+	// The explorer has not loaded a file yet
+	ErrNoFile = errors.New("no file loaded; create a reader using CreateReaderFromFile()")
+	// The explorer cannot perform this operation in its current mode
+	ErrInvalidMode = errors.New("invalid mode")
+	// The explorer cannot create a proxy for this kind of entry
+	ErrUnsupportedEntry = errors.New("unsupported entry")
+	// The explorer is already at the list of CUs and cannot move further back
+	ErrAtRoot = errors.New("already at the top level")
+	// The current item is not a variable or a field of one, so has no value
+	ErrNotVariable = errors.New("not a variable")
+	// A workspace has no module with the requested name
+	ErrUnknownModule = errors.New("unknown module")
+	// A workspace already has a module with this name
+	ErrDuplicateModule = errors.New("duplicate module")
+)
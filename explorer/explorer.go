@@ -2,20 +2,38 @@ package explorer
 
 import (
 	"debug/dwarf"
+	"errors"
 	"fmt"
+	"strings"
 	// "log"
 
+	"github.com/jdginn/durins-door/cache"
 	"github.com/jdginn/durins-door/client"
-	"github.com/jdginn/durins-door/explorer/plat"
 	"github.com/jdginn/durins-door/parser"
 )
 
 // Struct that mediates DWARF parsing as well as reading and writing
+//
+// Each Explorer is a single navigation session. Sessions over the same
+// binary share its parsed DWARF but are otherwise independent, so a server
+// can hand one session to each client and serve them concurrently.
 type Explorer struct {
 	DwarfFile string
-	reader    *dwarf.Reader
+	// The architecture CreateReaderFromFile reads universal Mach-O binaries
+	// for, or empty for the host's
+	Arch string
+	// Directories searched for separate debug files and split DWARF by
+	// CreateReaderFromFile, or nil for plat.DefaultDebugDirs
+	DebugDirs []string
+	program   *parser.Program
+	workspace *Workspace
 	client    client.Client
+	regs      parser.Registers
 	ctx       *stack
+	cache     *cache.Cache
+	cached    *cache.Contents
+	// Each context moved away from, most recent last, for Back
+	history [][]ctxLevel
 }
 
 // Returns a new explorer struct with sane defaults
@@ -25,42 +43,206 @@ func NewExplorer() *Explorer {
 	}
 }
 
+// Returns a new explorer over an already loaded program
+func NewExplorerFromProgram(p *parser.Program) *Explorer {
+	e := NewExplorer()
+	e.program = p
+	return e
+}
+
+// Returns a new explorer browsing every module of a workspace as one
+// address space
+//
+// At the top level, names are looked up in every module, preferring
+// definitions, and CUs are listed and written in paths qualified by their
+// module, as in "libteam.so:team.c/team".
+func NewExplorerFromWorkspace(w *Workspace) *Explorer {
+	e := NewExplorer()
+	e.workspace = w
+	return e
+}
+
 // Creates a reader within this explorer, reading the specified file
+//
+// When a cache is set, the name index is read from it rather than built by
+// walking the DWARF, if it was saved there before.
 func (e *Explorer) CreateReaderFromFile(fname string) error {
 	e.DwarfFile = fname
-	fh, err := plat.GetReaderFromFile(fname)
-	if err != nil {
+	if err := e.loadCache(); err != nil {
 		return err
 	}
-	reader, err := parser.GetReader(fh)
+	var saved *parser.IndexData
+	if e.cached != nil {
+		saved = e.cached.GetIndex()
+	}
+	program, err := LoadProgramWithIndex(fname, e.Arch, e.DebugDirs, saved)
+	if saved != nil && errors.Is(err, parser.ErrUnsupportedForm) {
+		// The index was saved from other DWARF, such as before the .dwo
+		// files of the binary were found; build it again
+		e.cached.SetIndex(nil)
+		program, err = LoadProgram(fname, e.Arch, e.DebugDirs)
+	}
 	if err != nil {
 		return err
 	}
-	e.reader = reader
+	e.program = program
 	return nil
 }
 
+// Returns a new session over the same binary as this explorer
+//
+// The new session starts at the list of CUs and shares this explorer's
+// program, client and cache, but navigates independently of it.
+func (e *Explorer) NewSession() *Explorer {
+	return &Explorer{
+		DwarfFile: e.DwarfFile,
+		Arch:      e.Arch,
+		DebugDirs: e.DebugDirs,
+		program:   e.program,
+		workspace: e.workspace,
+		client:    e.client,
+		regs:      e.regs,
+		ctx:       NewStack(),
+		cache:     e.cache,
+		cached:    e.cached,
+	}
+}
+
+// Returns the program this explorer is exploring, which for a workspace is
+// that of the module of the current item, or else of its first module
+func (e *Explorer) Program() *parser.Program {
+	return e.currProgram()
+}
+
+// Returns the workspace this explorer is browsing, or nil if it explores a
+// single binary
+func (e *Explorer) Workspace() *Workspace {
+	return e.workspace
+}
+
+// Returns the module of the current item, or nil outside a workspace
+func (e *Explorer) currModule() *Module {
+	if e.workspace == nil {
+		return nil
+	}
+	levels := e.ctx.Levels()
+	for i := len(levels) - 1; i >= 0; i-- {
+		if levels[i].module != nil {
+			return levels[i].module
+		}
+	}
+	if len(e.workspace.modules) > 0 {
+		return e.workspace.modules[0]
+	}
+	return nil
+}
+
+func (e *Explorer) currProgram() *parser.Program {
+	if m := e.currModule(); m != nil {
+		return m.Program
+	}
+	return e.program
+}
+
+// Sets the client used to read and write variables
+func (e *Explorer) SetClient(c client.Client) {
+	e.client = c
+}
+
+// Sets the registers of the frame in which parameters and locals of
+// functions are read, overriding any registers the client can supply
+func (e *Explorer) SetRegisters(regs parser.Registers) {
+	e.regs = regs
+}
+
+// Returns the registers set with SetRegisters, or else those supplied by the
+// client
+func (e *Explorer) Registers() (parser.Registers, error) {
+	if e.regs != nil {
+		return e.regs, nil
+	}
+	if c, ok := e.client.(client.RegisterClient); ok {
+		return c.Registers()
+	}
+	return nil, fmt.Errorf("No registers set and the client cannot supply them: %w", parser.ErrNoRegister)
+}
+
+// Persists proxies parsed by this explorer in c and reuses any proxies
+// previously cached there for the same binary
+//
+// Set the cache before CreateReaderFromFile for the name index to be cached
+// too. Nothing is written until SaveCache.
+func (e *Explorer) SetCache(c *cache.Cache) error {
+	e.cache = c
+	return e.loadCache()
+}
+
+// Writes the name index and the proxies parsed by this explorer and its
+// sessions to the cache, if one is set and anything was added to it
+func (e *Explorer) SaveCache() error {
+	if e.cached == nil {
+		return nil
+	}
+	if e.cached.GetIndex() == nil && e.program != nil {
+		// An index that reads units lazily is only saved once it has read
+		// all of them
+		if d, ok := e.program.Index().Data(); ok {
+			e.cached.SetIndex(d)
+		}
+	}
+	if !e.cached.Modified() {
+		return nil
+	}
+	return e.cache.Store(e.cached)
+}
+
+func (e *Explorer) loadCache() error {
+	e.cached = nil
+	if e.cache == nil || e.DwarfFile == "" {
+		return nil
+	}
+	key, err := cache.Key(e.DwarfFile)
+	if err != nil {
+		return err
+	}
+	if e.Arch != "" {
+		// The slices of a universal binary share its key
+		key += "-" + e.Arch
+	}
+	e.cached, err = e.cache.LoadOrNew(key)
+	return err
+}
+
 // Returns a new explorer with reader set to the specified file
-func NewExplorerFromFile(fname string) *Explorer {
+func NewExplorerFromFile(fname string) (*Explorer, error) {
 	e := NewExplorer()
 	err := e.CreateReaderFromFile(fname)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return e
+	return e, nil
 }
 
 // Returns a slice containing the names of each child of this Entry
 func (e *Explorer) listEntryChildren() []string {
-	entries, err := parser.GetChildren(e.reader, func(entry *dwarf.Entry) bool {
-		return (entry.Tag == dwarf.TagVariable || entry.Tag == dwarf.TagCompileUnit)
+	reader := e.currProgram().Reader()
+	reader.Seek(e.ctx.CurrEntry().Offset)
+	if _, err := reader.Next(); err != nil {
+		return []string{}
+	}
+	entries, err := parser.GetChildren(reader, func(entry *dwarf.Entry) bool {
+		return (entry.Tag == dwarf.TagVariable || entry.Tag == dwarf.TagSubprogram || entry.Tag == dwarf.TagCompileUnit)
 	})
 	if err != nil {
 		return []string{}
 	}
-	ret := make([]string, len(entries), len(entries))
-	for i, e := range entries {
-		ret[i] = e.Val(dwarf.AttrName).(string)
+	ret := make([]string, 0, len(entries))
+	for _, e := range entries {
+		// Unnamed entries cannot be stepped into by name, so there is no
+		// point listing them
+		if name := parser.EntryName(e); name != "" {
+			ret = append(ret, name)
+		}
 	}
 	return ret
 }
@@ -99,7 +281,7 @@ func (e *Explorer) CurrName() string {
 	case modeCUs:
 		return "all CUs"
 	case modeEntry:
-		return e.ctx.CurrEntry().Val(dwarf.AttrName).(string)
+		return parser.EntryName(e.ctx.CurrEntry())
 	case modeProxy:
 		return e.ctx.CurrProxy().Name()
 	default:
@@ -109,18 +291,62 @@ func (e *Explorer) CurrName() string {
 
 // Moves the context to the specified child of the current item
 //
-// Child is specified by name
+// Child is specified by name. Below a CU the name may continue with a path
+// into the child, so "teams[1].drivers" steps into the variable teams, then
+// its second element, then that element's drivers. At the list of CUs, a
+// name other than a CU's steps into the CU holding it, then into it. The
+// context is left unchanged if any step fails.
 func (e *Explorer) StepIntoChild(childName string) error {
+	if e.currProgram() == nil {
+		return ErrNoFile
+	}
+	before := e.ctx.Levels()
+	if err := e.stepIntoChild(childName); err != nil {
+		e.ctx.Restore(before)
+		return err
+	}
+	e.history = append(e.history, before)
+	return nil
+}
+
+func (e *Explorer) stepIntoChild(childName string) error {
 	switch e.ctx.CurrMode() {
 	case modeCUs:
-		entry, _, err := parser.GetEntry(e.reader, childName)
+		var m *Module
+		var entry, cu *dwarf.Entry
+		var err error
+		// The name of a CU may look like a path, so it is looked up whole
+		name := childName
+		if e.workspace != nil {
+			qualifier := ""
+			if mod, rest, ok := e.workspace.splitModule(childName); ok {
+				qualifier, name = mod.Name+":", rest
+			}
+			m, entry, cu, err = e.workspace.lookup(childName)
+			if err != nil {
+				first, _ := parser.SplitVariablePath(name)
+				m, entry, cu, err = e.workspace.lookup(qualifier + first)
+			}
+		} else {
+			entry, cu, err = e.program.Index().GetEntry(childName)
+			if err != nil {
+				first, _ := parser.SplitVariablePath(childName)
+				entry, cu, err = e.program.Index().GetEntry(first)
+			}
+		}
 		if err != nil {
 			return err
 		}
-		e.ctx.Push(modeEntry, entry, nil)
-		return nil
+		e.ctx.PushEntry(cu, m)
+		if entry.Tag == dwarf.TagCompileUnit {
+			return nil
+		}
+		// Anything else is stepped into from its CU, so that its path
+		// starts with the CU as Goto expects
+		return e.stepIntoChild(name)
 	case modeEntry:
-		entry, _, err := parser.GetEntry(e.reader, childName)
+		name, rest := parser.SplitVariablePath(childName)
+		entry, _, err := e.currProgram().Index().GetEntryInCU(name, e.ctx.CurrEntry().Offset)
 		if err != nil {
 			return err
 		}
@@ -128,58 +354,317 @@ func (e *Explorer) StepIntoChild(childName string) error {
 		if err != nil {
 			return err
 		}
-		e.ctx.Push(modeProxy, nil, p)
-		return nil
+		e.ctx.PushField(p, name)
+		return e.stepIntoPath(rest)
 	case modeProxy:
-		p, err := e.ctx.CurrProxy().GetChild(childName)
-		if err != nil {
-			return err
-		}
-		e.ctx.Push(modeProxy, nil, p)
-		return nil
+		return e.stepIntoPath(childName)
 	default:
-		return fmt.Errorf("Invalid mode")
+		return ErrInvalidMode
 	}
 }
 
-// Moves the context to the previous item
+// Steps through each member and index of a path relative to the current
+// proxy
+func (e *Explorer) stepIntoPath(path string) error {
+	elems, err := parser.ParsePath(path)
+	if err != nil {
+		return err
+	}
+	for _, elem := range elems {
+		if elem.Name != "" {
+			p, err := e.ctx.CurrProxy().GetChild(elem.Name)
+			if err != nil {
+				return err
+			}
+			e.ctx.PushField(p, "."+elem.Name)
+		}
+		for _, i := range elem.Indices {
+			var p *parser.TypeDefProxy
+			switch curr := e.ctx.CurrProxy().(type) {
+			case *parser.VariableProxy:
+				p, err = curr.Index(i)
+			case *parser.TypeDefProxy:
+				p, err = curr.Index(i)
+			default:
+				err = fmt.Errorf("Cannot index %s: %w", e.CurrName(), parser.ErrBadPath)
+			}
+			if err != nil {
+				return err
+			}
+			e.ctx.PushField(p, fmt.Sprintf("[%d]", i))
+		}
+	}
+	return nil
+}
+
+// Moves the context to the item it was at before the last move
 func (e *Explorer) Back() error {
+	if len(e.history) == 0 {
+		return ErrAtRoot
+	}
+	e.ctx.Restore(e.history[len(e.history)-1])
+	e.history = e.history[:len(e.history)-1]
+	return nil
+}
+
+// Moves the context to the parent of the current item: the variable or
+// member containing a member or element, the CU containing a variable, or
+// the list of CUs
+func (e *Explorer) Up() error {
+	if e.ctx.CurrMode() == modeCUs {
+		return ErrAtRoot
+	}
+	before := e.ctx.Levels()
 	e.ctx.Pop()
-	if e.ctx.CurrMode() == modeEntry {
-		e.reader.Seek(e.ctx.CurrEntry().Offset)
+	e.history = append(e.history, before)
+	return nil
+}
+
+// Moves the context to an absolute path as returned by Path, for example
+// "main.c/teams[1].drivers", which is the drivers member of the second
+// element of the variable teams in the CU main.c. The empty path is the
+// list of CUs.
+//
+// The context is left unchanged if the path cannot be followed.
+func (e *Explorer) Goto(path string) error {
+	if e.currProgram() == nil {
+		return ErrNoFile
 	}
+	s := e.NewSession()
+	if path != "" && path != "/" {
+		cu, rest, err := e.splitCU(strings.TrimPrefix(path, "/"))
+		if err != nil {
+			return err
+		}
+		if err := s.stepIntoChild(cu); err != nil {
+			return err
+		}
+		if rest != "" {
+			if err := s.stepIntoChild(rest); err != nil {
+				return err
+			}
+		}
+	}
+	e.history = append(e.history, e.ctx.Levels())
+	e.ctx.Restore(s.ctx.Levels())
 	return nil
 }
 
+// Splits an absolute path into the name of a CU and the path within it
+//
+// CU names are often file paths containing slashes, so the longest CU name
+// that the path starts with is taken.
+func (e *Explorer) splitCU(path string) (string, string, error) {
+	best := -1
+	names, err := e.ListCUs()
+	if err != nil {
+		return "", "", err
+	}
+	for _, name := range names {
+		n := len(name)
+		if n > best && strings.HasPrefix(path, name) && (len(path) == n || path[n] == '/') {
+			best = n
+		}
+	}
+	if best < 0 {
+		return "", "", fmt.Errorf("No CU at the start of path %s: %w", path, parser.ErrNotFound)
+	}
+	return path[:best], strings.TrimPrefix(path[best:], "/"), nil
+}
+
+// Returns the absolute path of the current item, which can be passed to
+// Goto to return to it
+//
+// Types moved to with GetType are not part of the path, so the path of a
+// type is the path of the variable or member it is the type of.
+func (e *Explorer) Path() string {
+	var sb strings.Builder
+	for _, l := range e.ctx.Levels() {
+		switch {
+		case l.mode == modeEntry:
+			if l.module != nil {
+				sb.WriteString(l.module.Name + ":")
+			}
+			sb.WriteString(parser.EntryName(l.entry))
+		case l.mode == modeProxy && l.isType:
+			return sb.String()
+		case l.mode == modeProxy:
+			if !strings.HasPrefix(l.step, ".") && !strings.HasPrefix(l.step, "[") {
+				sb.WriteString("/")
+			}
+			sb.WriteString(l.step)
+		}
+	}
+	return sb.String()
+}
+
 // Moves the context to the applicable TypeDef proxy
 //
 // Creates a TypeDefProxy from the current item if it is either an
 // entry or a VariableProxy. No action if the current item is already a
 // TypeDefProxy.
 func (e *Explorer) GetType() error {
-	switch e.ctx.CurrProxy().(type) {
+	switch p := e.ctx.CurrProxy().(type) {
 	// If we are already looking at a typeDef, there is nothing to do
-	case parser.TypeDefProxy:
+	case parser.TypeDefProxy, *parser.TypeDefProxy:
 	case parser.VariableProxy:
-		e.ctx.Push(modeProxy, nil, e.ctx.CurrProxy().(parser.VariableProxy).Type)
+		e.history = append(e.history, e.ctx.Levels())
+		e.ctx.PushType(p.Type)
+	case *parser.VariableProxy:
+		e.history = append(e.history, e.ctx.Levels())
+		e.ctx.PushType(&p.Type)
 	}
 	return nil
 }
 
+// Returns the proxy for the current item, or nil if the current item is
+// not a proxy
+func (e *Explorer) CurrProxy() parser.Proxy {
+	if e.ctx.CurrMode() != modeProxy {
+		return nil
+	}
+	return e.ctx.CurrProxy()
+}
+
+// Returns the variable containing the current item and the path from that
+// variable to the current item followed by rel, which is a path relative to
+// the current item such as "[1].drivers[0]"
+//
+// Parameters and locals of functions are located in the frame given by the
+// explorer's registers.
+func (e *Explorer) fieldPath(rel string) (*parser.VariableProxy, string, error) {
+	levels := e.ctx.Levels()
+	path := ""
+	for i := len(levels) - 1; i >= 0 && !levels[i].isType; i-- {
+		switch p := levels[i].proxy.(type) {
+		case *parser.VariableProxy:
+			if e.client != nil {
+				p.SetClient(e.client)
+			}
+			return p, joinPath(path, rel), nil
+		case *parser.FunctionProxy:
+			name, rest := parser.SplitVariablePath(joinPath(path, rel))
+			if name == "" {
+				break
+			}
+			regs, err := e.Registers()
+			if err != nil {
+				return nil, "", err
+			}
+			v, err := p.LocalVariable(name, regs)
+			if err != nil {
+				return nil, "", err
+			}
+			if e.client != nil {
+				v.SetClient(e.client)
+			}
+			return v, rest, nil
+		case *parser.TypeDefProxy:
+			path = levels[i].step + path
+		}
+	}
+	return nil, "", fmt.Errorf("%s is not a variable or a field of one: %w", e.CurrName(), ErrNotVariable)
+}
+
+// Appends a path relative to the current item to the path leading to it
+func joinPath(path string, rel string) string {
+	if rel != "" && !strings.HasPrefix(rel, "[") {
+		path += "."
+	}
+	return strings.TrimPrefix(path+rel, ".")
+}
+
+// Locates a field relative to the current item, which must be a variable
+// or a field of one
+func (e *Explorer) Field(rel string) (parser.Field, error) {
+	p, path, err := e.fieldPath(rel)
+	if err != nil {
+		return parser.Field{}, err
+	}
+	return p.Field(path)
+}
+
+// Reads a field relative to the current item through the client
+func (e *Explorer) ReadField(rel string) ([]byte, error) {
+	p, path, err := e.fieldPath(rel)
+	if err != nil {
+		return nil, err
+	}
+	return p.ReadField(path)
+}
+
+// Writes a field relative to the current item through the client
+func (e *Explorer) WriteField(rel string, value []byte) error {
+	p, path, err := e.fieldPath(rel)
+	if err != nil {
+		return err
+	}
+	return p.WriteField(path, value)
+}
+
 // Creates the proxy corresponding to the passed entry
+//
+// Proxies are taken from the cache when one is set, and added to it otherwise
+// to be written by SaveCache.
 func (e *Explorer) getProxy(entry *dwarf.Entry) (parser.Proxy, error) {
+	if p, ok := e.getCachedProxy(entry); ok {
+		return p, nil
+	}
 	switch entry.Tag {
 	case dwarf.TagVariable:
-		return parser.NewVariableProxy(e.reader, entry)
+		p, err := e.variable(entry)
+		if err != nil {
+			return nil, err
+		}
+		if e.cached != nil {
+			e.cached.AddVariable(entry.Offset, *p)
+		}
+		return p, nil
 	case dwarf.TagTypedef:
-		return parser.NewTypeDefProxy(e.reader, entry)
+		p, err := parser.NewTypeDefProxy(e.currProgram().Types(), entry)
+		if err != nil {
+			return nil, err
+		}
+		if e.cached != nil {
+			e.cached.AddType(entry.Offset, *p)
+		}
+		return p, nil
+	case dwarf.TagSubprogram:
+		if e.workspace != nil {
+			p, _, err := e.workspace.Function(e.currModule(), entry)
+			return p, err
+		}
+		return parser.NewFunctionProxy(e.program, entry)
 	default:
-		return nil, fmt.Errorf("Invalid tag %s for entry %s", entry.Tag.String(), parser.FormatEntryInfo(entry))
+		return nil, fmt.Errorf("Invalid tag %s for entry %s: %w", entry.Tag.String(), parser.FormatEntryInfo(entry), ErrUnsupportedEntry)
 	}
 }
 
-func (e *Explorer) Up() bool {
-	panic("explorer.Up() not implemented yet")
+// Constructs the proxy for a variable, resolved against the other modules
+// of a workspace and placed in its address space
+func (e *Explorer) variable(entry *dwarf.Entry) (*parser.VariableProxy, error) {
+	if e.workspace != nil {
+		p, _, err := e.workspace.Variable(e.currModule(), entry)
+		return p, err
+	}
+	return e.program.Variable(entry)
+}
+
+func (e *Explorer) getCachedProxy(entry *dwarf.Entry) (parser.Proxy, bool) {
+	if e.cached == nil {
+		return nil, false
+	}
+	switch entry.Tag {
+	case dwarf.TagVariable:
+		if p, ok := e.cached.Variable(entry.Offset); ok {
+			return &p, true
+		}
+	case dwarf.TagTypedef:
+		if p, ok := e.cached.Type(entry.Offset); ok {
+			return &p, true
+		}
+	}
+	return nil, false
 }
 
 // Returns a string representing key info about the current entry, if there is one
@@ -188,23 +673,74 @@ func (e *Explorer) Info() string {
 	case modeCUs, modeEntry:
 		entry := e.ctx.CurrEntry()
 		return parser.FormatEntryInfo(entry)
+	case modeProxy:
+		if p, ok := e.ctx.CurrProxy().(*parser.FunctionProxy); ok {
+			return e.formatFunctionInfo(p)
+		}
+		return ""
 	default:
 		return ""
 	}
 }
 
-// Returns a list of all CUs in this file
+// Formats the signature, code addresses, source line, locals, scopes and
+// inlined instances of a function
+func (e *Explorer) formatFunctionInfo(p *parser.FunctionProxy) string {
+	str := fmt.Sprintf("Function: %s\n", p.Signature())
+	if p.LinkageName != "" {
+		str += fmt.Sprintf("  Linkage name: %s\n", p.LinkageName)
+		if d := p.DemangledName(); d != p.LinkageName {
+			str += fmt.Sprintf("  Demangled: %s\n", d)
+		}
+	}
+	str += fmt.Sprintf("  Low PC: %#x\n", p.LowPC)
+	str += fmt.Sprintf("  High PC: %#x\n", p.HighPC)
+	if p.HighPC != 0 {
+		if l, err := e.addrToLine(p.LowPC); err == nil {
+			str += fmt.Sprintf("  Source: %s\n", l)
+		}
+	}
+	for _, l := range p.Locals {
+		str += fmt.Sprintf("  Local: %s %s\n", l.TypeName, l.Name)
+	}
+	str += fmt.Sprintf("  Lexical blocks: %d\n", len(p.Blocks))
+	for _, inst := range p.Inlined {
+		str += fmt.Sprintf("  Inlined at line %d:", inst.CallLine)
+		for _, r := range inst.Ranges {
+			str += fmt.Sprintf(" [%#x, %#x)", r[0], r[1])
+		}
+		str += "\n"
+	}
+	return str
+}
+
+func (e *Explorer) addrToLine(pc uint64) (parser.SourceLine, error) {
+	if e.workspace != nil {
+		l, _, err := e.workspace.AddrToLine(pc)
+		return l, err
+	}
+	return e.program.AddrToLine(pc)
+}
+
+// Returns a list of all CUs in this file, or of every module of a
+// workspace qualified by the module's name
 func (e *Explorer) ListCUs() ([]string, error) {
-	if e.reader == nil {
-		return nil, fmt.Errorf("Cannot List CUs without setting a reader. Create a reader using CreateReaderFromFile().")
+	if e.workspace != nil {
+		ret := make([]string, 0)
+		for _, m := range e.workspace.modules {
+			for _, cu := range m.Program.Index().CUs() {
+				ret = append(ret, m.Name+":"+cu.Name)
+			}
+		}
+		return ret, nil
 	}
-	CUs, err := parser.GetCUs(e.reader)
-	if err != nil {
-		return []string{}, err
+	if e.program == nil {
+		return nil, ErrNoFile
 	}
+	CUs := e.program.Index().CUs()
 	ret := make([]string, len(CUs), len(CUs))
 	for i, cu := range CUs {
-		ret[i] = cu.Val(dwarf.AttrName).(string)
+		ret[i] = cu.Name
 	}
 	return ret, nil
 }
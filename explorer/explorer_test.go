@@ -2,14 +2,21 @@ package explorer_test
 
 import (
 	// "fmt"
+	"debug/dwarf"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/jdginn/durins-door/cache"
+	"github.com/jdginn/durins-door/client/image"
 	"github.com/jdginn/durins-door/explorer"
+	"github.com/jdginn/durins-door/internal/dwarftest"
+	"github.com/jdginn/durins-door/parser"
 )
 
 var testcaseFilename = "../testcase-compiler/testcase.dwarf"
+var testcaseBinFile = "../testcase-compiler/testcase.out"
 
 func TestNewExplorer(t *testing.T) {
 	ex := explorer.NewExplorer()
@@ -20,11 +27,15 @@ func TestNewExplorer(t *testing.T) {
 	assert.Error(t, ex.CreateReaderFromFile("invalid_file"))
 	assert.NoError(t, ex.CreateReaderFromFile(testcaseFilename))
 
-	assert.NotPanics(t, func() { explorer.NewExplorerFromFile(testcaseFilename) })
+	_, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	_, err = explorer.NewExplorerFromFile("invalid_file")
+	assert.Error(t, err)
 }
 
 func TestExplore(t *testing.T) {
-	ex := explorer.NewExplorerFromFile(testcaseFilename)
+	ex, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
 	names, err := ex.ListCUs()
 	assert.NoError(t, err)
 	assert.Equal(t, []string{"testcase.cpp"}, names)
@@ -61,15 +72,13 @@ func TestExplore(t *testing.T) {
 	}
 	assert.Equal(t, members, ex.ListChildren())
 
-	// TODO: Back doesn't work properly yet
-	// assert.NoError(t, ex.Back())
-	// assert.Equal(t, "modeEntry", ex.CurrMode())
-	// assert.Equal(t, "testcase.cpp", ex.CurrName())
-	//  fmt.Printf("\n\n\n%v\n\n\n", ex.ListChildren())
-	//  assert.Contains(t, ex.ListChildren(), "formula_1_teams")
+	assert.NoError(t, ex.Back())
+	assert.Equal(t, "modeEntry", ex.CurrMode())
+	assert.Equal(t, "testcase.cpp", ex.CurrName())
+	assert.Contains(t, ex.ListChildren(), "formula_1_teams")
 
-	//  err = ex.StepIntoChild("fomula_1_teams")
-	//  assert.NoError(t, err)
+	err = ex.StepIntoChild("formula_1_teams")
+	assert.NoError(t, err)
 	err = ex.StepIntoChild("drivers")
 	assert.NoError(t, err)
 	assert.Equal(t, "modeProxy", ex.CurrMode())
@@ -90,3 +99,327 @@ func TestReadCUs(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(cus))
 }
+
+func TestExploreWithCache(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	key, err := cache.Key(testcaseFilename)
+	assert.NoError(t, err)
+
+	explore := func() {
+		ex := explorer.NewExplorer()
+		assert.NoError(t, ex.SetCache(c))
+		assert.NoError(t, ex.CreateReaderFromFile(testcaseFilename))
+		assert.NoError(t, ex.StepIntoChild("testcase.cpp"))
+		assert.NoError(t, ex.StepIntoChild("formula_1_teams"))
+		assert.Equal(t, "formula_1_teams", ex.CurrName())
+		assert.Contains(t, ex.ListChildren(), "drivers")
+		assert.NoError(t, ex.SaveCache())
+	}
+
+	// Nothing is written until the cache is saved
+	ex, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	assert.NoError(t, ex.SetCache(c))
+	assert.NoError(t, ex.StepIntoChild("testcase.cpp"))
+	assert.NoError(t, ex.StepIntoChild("formula_1_teams"))
+	_, err = c.Load(key)
+	assert.ErrorIs(t, err, cache.ErrMiss)
+
+	explore()
+	contents, err := c.Load(key)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(contents.Variables))
+	if !assert.NotNil(t, contents.Index) {
+		return
+	}
+
+	// The index is read from the cache rather than built again
+	teams := contents.Index.ByName["formula_1_teams"]
+	contents.Index.ByName["cached_only"] = teams
+	assert.NoError(t, c.Store(contents))
+	explore()
+	ex = explorer.NewExplorer()
+	assert.NoError(t, ex.SetCache(c))
+	assert.NoError(t, ex.CreateReaderFromFile(testcaseFilename))
+	assert.Equal(t, teams, ex.Program().Index().Lookup("cached_only"))
+
+	// An index saved from other DWARF is built again
+	contents.Index.CUs[0].Offset++
+	assert.NoError(t, c.Store(contents))
+	explore()
+	contents, err = c.Load(key)
+	assert.NoError(t, err)
+	assert.NotContains(t, contents.Index.ByName, "cached_only")
+	assert.Equal(t, 1, len(contents.Variables))
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	root, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ex := root.NewSession()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, ex.StepIntoChild("testcase.cpp"))
+				assert.Contains(t, ex.ListChildren(), "formula_1_teams")
+				assert.NoError(t, ex.StepIntoChild("formula_1_teams"))
+				assert.NoError(t, ex.StepIntoChild("drivers"))
+				assert.Equal(t, []string{"initials", "car_number", "has_won_wdc"}, ex.ListChildren())
+				assert.NoError(t, ex.Back())
+				assert.NoError(t, ex.Back())
+				assert.NoError(t, ex.Back())
+				assert.Equal(t, "modeCUs", ex.CurrMode())
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, "modeCUs", root.CurrMode())
+}
+
+func TestExplorerErrors(t *testing.T) {
+	ex := explorer.NewExplorer()
+	assert.ErrorIs(t, ex.StepIntoChild("testcase.cpp"), explorer.ErrNoFile)
+	_, err := ex.ListCUs()
+	assert.ErrorIs(t, err, explorer.ErrNoFile)
+	assert.ErrorIs(t, ex.Back(), explorer.ErrAtRoot)
+
+	ex, err = explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, ex.StepIntoChild("bad name"), parser.ErrNotFound)
+	assert.NoError(t, ex.StepIntoChild("testcase.cpp"))
+	assert.ErrorIs(t, ex.StepIntoChild("Driver"), explorer.ErrUnsupportedEntry)
+	assert.NoError(t, ex.Back())
+	assert.ErrorIs(t, ex.Back(), explorer.ErrAtRoot)
+	assert.Equal(t, "modeCUs", ex.CurrMode())
+}
+
+func TestExplorerFields(t *testing.T) {
+	ex, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	_, err = ex.ReadField("")
+	assert.ErrorIs(t, err, explorer.ErrNotVariable)
+	assert.Nil(t, ex.CurrProxy())
+
+	assert.NoError(t, ex.StepIntoChild("testcase.cpp"))
+	assert.NoError(t, ex.StepIntoChild("formula_1_teams"))
+	_, err = ex.ReadField("")
+	assert.ErrorIs(t, err, parser.ErrNoClient)
+
+	c, err := image.NewFromPath(testcaseBinFile)
+	assert.NoError(t, err)
+	ex.SetClient(c)
+	b, err := ex.ReadField("[1].drivers[0].car_number")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{44, 0, 0, 0}, b)
+	// Members of arrays cannot be reached without an index
+	assert.NoError(t, ex.StepIntoChild("drivers"))
+	assert.Equal(t, "drivers", ex.CurrProxy().Name())
+	_, err = ex.ReadField("[0].car_number")
+	assert.ErrorIs(t, err, parser.ErrBadPath)
+	assert.NoError(t, ex.Back())
+	assert.NoError(t, ex.Back())
+
+	assert.NoError(t, ex.StepIntoChild("mercedes"))
+	assert.NoError(t, ex.StepIntoChild("drivers"))
+	f, err := ex.Field("[0].car_number")
+	assert.NoError(t, err)
+	assert.Equal(t, 32, f.BitSize)
+	b, err = ex.ReadField("[0].car_number")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{44, 0, 0, 0}, b)
+	assert.ErrorIs(t, ex.WriteField("[0].car_number", []byte{1, 0, 0, 0}), image.ErrReadOnly)
+
+	assert.NoError(t, ex.Back())
+	assert.NoError(t, ex.GetType())
+	assert.IsType(t, &parser.TypeDefProxy{}, ex.CurrProxy())
+	assert.Contains(t, ex.ListChildren(), "drivers")
+	_, err = ex.ReadField("")
+	assert.ErrorIs(t, err, explorer.ErrNotVariable)
+}
+
+func TestExplorerPaths(t *testing.T) {
+	ex, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	assert.Equal(t, "", ex.Path())
+	assert.ErrorIs(t, ex.Up(), explorer.ErrAtRoot)
+
+	assert.NoError(t, ex.StepIntoChild("testcase.cpp"))
+	assert.Equal(t, "testcase.cpp", ex.Path())
+	assert.NoError(t, ex.StepIntoChild("formula_1_teams[1].drivers"))
+	assert.Equal(t, "drivers", ex.CurrName())
+	assert.Equal(t, "testcase.cpp/formula_1_teams[1].drivers", ex.Path())
+	assert.NoError(t, ex.StepIntoChild("[0]"))
+	assert.Equal(t, "drivers[0]", ex.CurrName())
+	assert.NoError(t, ex.StepIntoChild("car_number"))
+	assert.Equal(t, "testcase.cpp/formula_1_teams[1].drivers[0].car_number", ex.Path())
+
+	c, err := image.NewFromPath(testcaseBinFile)
+	assert.NoError(t, err)
+	ex.SetClient(c)
+	b, err := ex.ReadField("")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{44, 0, 0, 0}, b)
+
+	// Failed steps leave the context where it was
+	assert.NoError(t, ex.Up())
+	assert.ErrorIs(t, ex.StepIntoChild("initials[2]"), parser.ErrOutOfRange)
+	assert.ErrorIs(t, ex.StepIntoChild("car_number[0]"), parser.ErrBadPath)
+	assert.Equal(t, "testcase.cpp/formula_1_teams[1].drivers[0]", ex.Path())
+	assert.NoError(t, ex.Up())
+	assert.NoError(t, ex.Up())
+	assert.Equal(t, "formula_1_teams[1]", ex.CurrName())
+	assert.NoError(t, ex.Up())
+	assert.NoError(t, ex.Up())
+	assert.Equal(t, "testcase.cpp", ex.Path())
+
+	// Back retraces each move, including moves up
+	assert.NoError(t, ex.Back())
+	assert.Equal(t, "testcase.cpp/formula_1_teams", ex.Path())
+	assert.NoError(t, ex.Back())
+	assert.Equal(t, "testcase.cpp/formula_1_teams[1]", ex.Path())
+
+	assert.NoError(t, ex.Goto("testcase.cpp/mercedes.sponsors[3]"))
+	assert.Equal(t, "testcase.cpp/mercedes.sponsors[3]", ex.Path())
+	f, err := ex.Field("")
+	assert.NoError(t, err)
+	assert.Equal(t, 16, f.BitSize)
+	b, err = ex.ReadField("")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{8, 0}, b)
+	assert.NoError(t, ex.Goto("testcase.cpp/mercedes"))
+	assert.NoError(t, ex.GetType())
+	assert.Equal(t, "testcase.cpp/mercedes", ex.Path())
+	assert.NoError(t, ex.Back())
+	assert.NoError(t, ex.Back())
+	assert.Equal(t, "testcase.cpp/mercedes.sponsors[3]", ex.Path())
+	assert.NoError(t, ex.Back())
+	assert.Equal(t, "testcase.cpp/formula_1_teams[1]", ex.Path())
+
+	assert.ErrorIs(t, ex.Goto("nowhere.cpp/hamilton"), parser.ErrNotFound)
+	assert.ErrorIs(t, ex.Goto("testcase.cpp/hamilton.nothing"), parser.ErrNotFound)
+	assert.Equal(t, "testcase.cpp/formula_1_teams[1]", ex.Path())
+	assert.NoError(t, ex.Goto("/testcase.cpp"))
+	assert.Equal(t, "modeEntry", ex.CurrMode())
+	assert.NoError(t, ex.Goto(""))
+	assert.Equal(t, "modeCUs", ex.CurrMode())
+}
+
+func TestExplorerFunctions(t *testing.T) {
+	ex, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	assert.NoError(t, ex.StepIntoChild("testcase.cpp"))
+	assert.Contains(t, ex.ListChildren(), "main")
+
+	assert.NoError(t, ex.StepIntoChild("main"))
+	assert.Equal(t, "testcase.cpp/main", ex.Path())
+	main, ok := ex.CurrProxy().(*parser.FunctionProxy)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0x401110), main.LowPC)
+	assert.Equal(t, uint64(0x401118), main.HighPC)
+	assert.Equal(t, []string{}, ex.ListChildren())
+	assert.Contains(t, ex.Info(), "Function: int main()")
+	assert.Contains(t, ex.Info(), "testcase.cpp:30\n")
+	_, err = ex.ReadField("")
+	assert.ErrorIs(t, err, explorer.ErrNotVariable)
+}
+
+// A client over a small stack that can also supply the registers of the
+// frame on it
+type stackClient struct {
+	base  int
+	stack []byte
+	regs  map[int]uint64
+}
+
+func (c *stackClient) Read(addr int, size int) ([]byte, error) {
+	return c.stack[addr-c.base : addr-c.base+size], nil
+}
+
+func (c *stackClient) Write(addr int, data []byte) error {
+	copy(c.stack[addr-c.base:], data)
+	return nil
+}
+
+func (c *stackClient) SetOffset(offset int64) {}
+
+func (c *stackClient) Registers() (map[int]uint64, error) {
+	return c.regs, nil
+}
+
+func TestExplorerLocals(t *testing.T) {
+	// void move(struct Point p) with p at DW_OP_fbreg -8 and the frame
+	// base in DW_OP_reg6
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	point := dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "Point"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 8},
+	).With(
+		dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "x"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: 0}),
+		dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "y"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: 4}),
+	)
+	move := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "move"},
+		dwarftest.Attr{Attr: dwarf.AttrFrameBase, Val: []byte{0x56}},
+	).With(
+		dwarftest.New(dwarf.TagFormalParameter,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "p"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: point},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x91, 0x78}}),
+	)
+	data, err := dwarftest.Build(dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "point.c"},
+	).With(intType, point, move))
+	assert.NoError(t, err)
+	prog, err := parser.NewProgram(data)
+	assert.NoError(t, err)
+
+	ex := explorer.NewExplorerFromProgram(prog)
+	assert.NoError(t, ex.Goto("point.c/move.p.y"))
+	_, err = ex.ReadField("")
+	assert.ErrorIs(t, err, parser.ErrNoRegister)
+
+	c := &stackClient{
+		base:  0x7000,
+		stack: []byte{0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0},
+		regs:  map[int]uint64{6: 0x7010},
+	}
+	ex.SetClient(c)
+	b, err := ex.ReadField("")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{4, 0, 0, 0}, b)
+	f, err := ex.Field("")
+	assert.NoError(t, err)
+	assert.Equal(t, 0x700c, f.Address)
+
+	// Paths from the function start with the name of a local, and
+	// registers set on the explorer take precedence over the client's
+	assert.NoError(t, ex.Goto("point.c/move"))
+	ex.SetRegisters(parser.Registers{6: 0x700c})
+	assert.NoError(t, ex.WriteField("p.x", []byte{5, 0, 0, 0}))
+	assert.Equal(t, []byte{5, 0, 0, 0}, c.stack[4:8])
+	_, err = ex.ReadField("")
+	assert.ErrorIs(t, err, explorer.ErrNotVariable)
+}
+
+func TestLoadStripped(t *testing.T) {
+	ex := explorer.NewExplorer()
+	ex.DebugDirs = []string{"plat/testdata/debug"}
+	assert.NoError(t, ex.CreateReaderFromFile("plat/testdata/app"))
+	e, _, err := ex.Program().Index().GetEntry("config")
+	assert.NoError(t, err)
+	v, err := ex.Program().Variable(e)
+	assert.NoError(t, err)
+	assert.Equal(t, 16*8, v.Type.BitSize())
+}
@@ -0,0 +1,35 @@
+package explorer
+
+import (
+	"github.com/jdginn/durins-door/explorer/plat"
+	"github.com/jdginn/durins-door/parser"
+)
+
+// Loads the program in the binary at this path
+//
+// The DWARF is read from the binary's separate debug file when it has been
+// stripped, found by plat.FindDebugFile, and from its .dwo files or .dwp
+// package when it was built with -gsplit-dwarf, which are looked for in
+// debugDirs too. The binary itself is still what any memory image should be
+// read from.
+//
+// A universal Mach-O binary is read from its slice for arch, or the host's
+// architecture when arch is empty; see plat.OpenArch.
+func LoadProgram(path string, arch string, debugDirs []string) (*parser.Program, error) {
+	return LoadProgramWithIndex(path, arch, debugDirs, nil)
+}
+
+// Behaves like LoadProgram, but rebuilds the index of the program from
+// saved rather than walking the DWARF; see parser.NewIndexFromData
+func LoadProgramWithIndex(path string, arch string, debugDirs []string, saved *parser.IndexData) (*parser.Program, error) {
+	debugPath, err := plat.FindDebugFile(path, debugDirs)
+	if err != nil {
+		return nil, err
+	}
+	fh, err := plat.OpenArch(debugPath, arch)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return parser.NewProgramFromFileWithIndex(fh, parser.SearchPaths{Binary: path, Dirs: debugDirs}, saved)
+}
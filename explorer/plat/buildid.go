@@ -0,0 +1,87 @@
+package plat
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Returned when a file carries no build identifier
+var ErrNoBuildID = errors.New("no build id")
+
+const (
+	noteTypeGNUBuildID = 3
+	loadCmdUUID        = 0x1b
+)
+
+// Returns the unique identifier the linker stamped into this binary
+//
+// For ELF files this is the contents of the GNU build-id note; for Mach-O
+// files it is the LC_UUID load command.
+func BuildID(f string) ([]byte, error) {
+	if ef, err := elf.Open(f); err == nil {
+		defer ef.Close()
+		return elfBuildID(ef)
+	}
+	if mf, err := macho.Open(f); err == nil {
+		defer mf.Close()
+		return machoUUID(mf)
+	}
+	return nil, fmt.Errorf("%s is neither an ELF nor a Mach-O file: %w", f, ErrNoBuildID)
+}
+
+func elfBuildID(f *elf.File) ([]byte, error) {
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		if id, ok := findNote(data, f.ByteOrder, "GNU", noteTypeGNUBuildID); ok {
+			return id, nil
+		}
+	}
+	return nil, ErrNoBuildID
+}
+
+// Searches the contents of a note section for a note of this owner and type
+func findNote(data []byte, order binary.ByteOrder, owner string, noteType uint32) ([]byte, bool) {
+	align := func(n uint32) uint32 { return (n + 3) &^ 3 }
+	for len(data) >= 12 {
+		nameSize := order.Uint32(data[0:4])
+		descSize := order.Uint32(data[4:8])
+		typ := order.Uint32(data[8:12])
+		data = data[12:]
+		if uint32(len(data)) < align(nameSize)+descSize {
+			return nil, false
+		}
+		name := bytes.TrimRight(data[:nameSize], "\x00")
+		desc := data[align(nameSize) : align(nameSize)+descSize]
+		if typ == noteType && string(name) == owner {
+			return desc, true
+		}
+		if uint32(len(data)) < align(nameSize)+align(descSize) {
+			return nil, false
+		}
+		data = data[align(nameSize)+align(descSize):]
+	}
+	return nil, false
+}
+
+func machoUUID(f *macho.File) ([]byte, error) {
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if len(raw) < 24 {
+			continue
+		}
+		if f.ByteOrder.Uint32(raw[0:4]) == loadCmdUUID {
+			return raw[8:24], nil
+		}
+	}
+	return nil, ErrNoBuildID
+}
@@ -0,0 +1,133 @@
+package plat
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Directories searched for the separate debug files of stripped binaries
+// when no others are given
+var DefaultDebugDirs = []string{"/usr/lib/debug"}
+
+// Returned when a stripped binary names a separate debug file that cannot
+// be found
+var ErrNoDebugFile = errors.New("no debug file")
+
+// Returns the path of the file holding the DWARF of the binary at path
+//
+// This is the binary itself unless it is an ELF file stripped of its
+// .debug_info, in which case the separate debug file is looked for, as GDB
+// does, first by build id as .build-id/xx/yyyy.debug under each of dirs,
+// then by the name in its .gnu_debuglink next to the binary, in the .debug
+// directory beside it and under each of dirs followed by the binary's own
+// directory. A file found by build id must carry the same build id, and
+// one found through .gnu_debuglink must match its CRC.
+//
+// dirs defaults to DefaultDebugDirs when nil.
+func FindDebugFile(path string, dirs []string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		// Not ELF, so not ours to look into
+		return path, nil
+	}
+	defer f.Close()
+	if hasDWARF(f) {
+		return path, nil
+	}
+	if dirs == nil {
+		dirs = DefaultDebugDirs
+	}
+
+	id, err := elfBuildID(f)
+	if err != nil && !errors.Is(err, ErrNoBuildID) {
+		return "", err
+	}
+	if len(id) > 1 {
+		h := hex.EncodeToString(id)
+		for _, dir := range dirs {
+			candidate := filepath.Join(dir, ".build-id", h[:2], h[2:]+".debug")
+			if matchesBuildID(candidate, id) {
+				return candidate, nil
+			}
+		}
+	}
+
+	name, crc, ok, err := debugLink(f)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		// Nothing names a debug file, so the binary may simply have no DWARF
+		return path, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(abs)
+	candidates := []string{filepath.Join(dir, name), filepath.Join(dir, ".debug", name)}
+	for _, d := range dirs {
+		candidates = append(candidates, filepath.Join(d, dir, name))
+	}
+	for _, candidate := range candidates {
+		if candidate != abs && matchesCRC(candidate, crc) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("No %s matching the .gnu_debuglink of %s: %w", name, path, ErrNoDebugFile)
+}
+
+func hasDWARF(f *elf.File) bool {
+	return f.Section(".debug_info") != nil || f.Section(".zdebug_info") != nil
+}
+
+// Returns the file name and CRC recorded in the .gnu_debuglink section, or
+// false if the file has none
+func debugLink(f *elf.File) (string, uint32, bool, error) {
+	s := f.Section(".gnu_debuglink")
+	if s == nil {
+		return "", 0, false, nil
+	}
+	data, err := s.Data()
+	if err != nil {
+		return "", 0, false, err
+	}
+	// The name is padded to a multiple of 4 bytes, then followed by the CRC
+	end := bytes.IndexByte(data, 0)
+	at := (end + 4) &^ 3
+	if end <= 0 || len(data) < at+4 {
+		return "", 0, false, fmt.Errorf("Malformed .gnu_debuglink: %w", ErrNoDebugFile)
+	}
+	return string(data[:end]), f.ByteOrder.Uint32(data[at:]), true, nil
+}
+
+func matchesBuildID(path string, id []byte) bool {
+	f, err := elf.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	got, err := elfBuildID(f)
+	return err == nil && bytes.Equal(got, id)
+}
+
+// Debug files can run to gigabytes, so they are hashed as they are read
+func matchesCRC(path string, crc uint32) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return h.Sum32() == crc
+}
@@ -0,0 +1,56 @@
+package plat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Built by testdata/build.sh
+const strippedFile = "testdata/app"
+
+func copyFile(t *testing.T, src, dst string) {
+	b, err := os.ReadFile(src)
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(dst), 0o755))
+	assert.NoError(t, os.WriteFile(dst, b, 0o644))
+}
+
+func TestFindDebugFileByBuildID(t *testing.T) {
+	path, err := FindDebugFile(strippedFile, []string{t.TempDir(), "testdata/debug"})
+	assert.NoError(t, err)
+	assert.Equal(t, "testdata/debug/.build-id/dc/6e5af0ba2b0ec78bf278ffbef62b957bb6f790.debug", path)
+}
+
+func TestFindDebugFileByDebugLink(t *testing.T) {
+	path, err := FindDebugFile(strippedFile, []string{t.TempDir()})
+	assert.NoError(t, err)
+	abs, _ := filepath.Abs("testdata/.debug/app.debug")
+	assert.Equal(t, abs, path)
+
+	// Under a debug directory, by the binary's own directory
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin", "app")
+	copyFile(t, strippedFile, bin)
+	debugDir := filepath.Join(dir, "debug")
+	copyFile(t, "testdata/.debug/app.debug", filepath.Join(debugDir, dir, "bin", "app.debug"))
+	path, err = FindDebugFile(bin, []string{debugDir})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(debugDir, dir, "bin", "app.debug"), path)
+
+	// A file whose CRC does not match is not the debug file
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "app.debug"), []byte("stale"), 0o644))
+	assert.NoError(t, os.Remove(filepath.Join(debugDir, dir, "bin", "app.debug")))
+	_, err = FindDebugFile(bin, []string{debugDir})
+	assert.ErrorIs(t, err, ErrNoDebugFile)
+}
+
+func TestFindDebugFileUnstripped(t *testing.T) {
+	for _, path := range []string{"testdata/.debug/app.debug", "testdata/compressed/app.zdebug", "testdata/app.c"} {
+		got, err := FindDebugFile(path, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, path, got)
+	}
+}
@@ -0,0 +1,166 @@
+package plat
+
+import (
+	"bytes"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Returned when a Mach-O binary has no slice for the requested architecture
+var ErrNoArch = errors.New("no such architecture")
+
+const (
+	// The high byte of a CPU subtype holds capability bits
+	cpuSubtypeMask    = 0x00ffffff
+	cpuSubtypeX86_64H = 8
+	cpuSubtypeArm64E  = 2
+)
+
+// Mach-O CPU types for the architectures Go names
+var goarchCpus = map[string]macho.Cpu{
+	"386":     macho.Cpu386,
+	"amd64":   macho.CpuAmd64,
+	"arm":     macho.CpuArm,
+	"arm64":   macho.CpuArm64,
+	"ppc64":   macho.CpuPpc64,
+	"ppc64le": macho.CpuPpc64,
+}
+
+// Other names architectures go by, mapped to the ones Apple's tools use
+var archAliases = map[string]string{
+	"386":     "i386",
+	"x86":     "i386",
+	"amd64":   "x86_64",
+	"x86-64":  "x86_64",
+	"aarch64": "arm64",
+}
+
+// Returns the name Apple's tools give a CPU type and subtype, such as
+// arm64 or x86_64
+func archName(cpu macho.Cpu, sub uint32) string {
+	switch cpu {
+	case macho.Cpu386:
+		return "i386"
+	case macho.CpuAmd64:
+		if sub&cpuSubtypeMask == cpuSubtypeX86_64H {
+			return "x86_64h"
+		}
+		return "x86_64"
+	case macho.CpuArm:
+		return "arm"
+	case macho.CpuArm64:
+		if sub&cpuSubtypeMask == cpuSubtypeArm64E {
+			return "arm64e"
+		}
+		return "arm64"
+	case macho.CpuPpc:
+		return "ppc"
+	case macho.CpuPpc64:
+		return "ppc64"
+	default:
+		return fmt.Sprintf("cpu%#x", uint32(cpu))
+	}
+}
+
+func normalizeArch(arch string) string {
+	arch = strings.ToLower(arch)
+	if alias, ok := archAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
+
+// Returns the architectures of a Mach-O binary by name, such as arm64 and
+// x86_64: one for each slice of a universal binary, in the order they are
+// stored, or the single architecture of a thin file
+func Arches(f string) ([]string, error) {
+	fh, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	format, err := DetectFormat(fh)
+	fh.Close()
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatFatMachO:
+		ff, err := macho.OpenFat(f)
+		if err != nil {
+			return nil, err
+		}
+		defer ff.Close()
+		arches := make([]string, len(ff.Arches))
+		for i, a := range ff.Arches {
+			arches[i] = archName(a.Cpu, a.SubCpu)
+		}
+		return arches, nil
+	case FormatMachO:
+		mf, err := macho.Open(f)
+		if err != nil {
+			return nil, err
+		}
+		defer mf.Close()
+		return []string{archName(mf.Cpu, mf.SubCpu)}, nil
+	default:
+		return nil, fmt.Errorf("%s is not a Mach-O file: %w", f, ErrUnknownFormat)
+	}
+}
+
+func openMachO(f string, arch string) (*macho.File, error) {
+	mf, err := macho.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	if name := archName(mf.Cpu, mf.SubCpu); arch != "" && name != normalizeArch(arch) {
+		mf.Close()
+		return nil, fmt.Errorf("%s is %s, not %s: %w", f, name, arch, ErrNoArch)
+	}
+	return mf, nil
+}
+
+// Opens the slice of a universal binary for this architecture, or when
+// arch is empty the slice for the host's, falling back to the first
+func openFat(f string, arch string) (*macho.File, error) {
+	ff, err := macho.OpenFat(f)
+	if err != nil {
+		return nil, err
+	}
+	defer ff.Close()
+	if arch == "" {
+		for _, a := range ff.Arches {
+			if a.Cpu == goarchCpus[runtime.GOARCH] {
+				return readSlice(f, a)
+			}
+		}
+		return readSlice(f, ff.Arches[0])
+	}
+	names := make([]string, len(ff.Arches))
+	for i, a := range ff.Arches {
+		names[i] = archName(a.Cpu, a.SubCpu)
+		if names[i] == normalizeArch(arch) {
+			return readSlice(f, a)
+		}
+	}
+	return nil, fmt.Errorf("%s has no %s slice, only %s: %w", f, arch, strings.Join(names, ", "), ErrNoArch)
+}
+
+// Reads one slice of a universal binary into memory, so that the file it
+// came from can be closed
+func readSlice(f string, arch macho.FatArch) (*macho.File, error) {
+	fh, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	b, err := io.ReadAll(io.NewSectionReader(fh, int64(arch.Offset), int64(arch.Size)))
+	if err != nil {
+		return nil, err
+	}
+	return macho.NewFile(bytes.NewReader(b))
+}
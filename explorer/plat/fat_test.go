@@ -0,0 +1,85 @@
+package plat
+
+import (
+	"debug/macho"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/parser"
+)
+
+// Built by testdata/formats/build.sh
+const fatFile = "testdata/formats/app.fat"
+
+func TestArches(t *testing.T) {
+	arches, err := Arches(fatFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x86_64", "arm64"}, arches)
+
+	_, err = Arches(strippedFile)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	assert.Equal(t, "arm64e", archName(macho.CpuArm64, 0x80000002))
+}
+
+func configAddress(t *testing.T, f DebugFile) int {
+	prog, err := parser.NewProgramFromFile(f, parser.SearchPaths{})
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	e, _, err := prog.Index().GetEntry("main.config")
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	v, err := prog.Variable(e)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	return v.Address
+}
+
+func TestOpenArch(t *testing.T) {
+	addrs := make(map[int]bool)
+	for arch, cpu := range map[string]macho.Cpu{
+		"arm64":   macho.CpuArm64,
+		"aarch64": macho.CpuArm64,
+		"x86_64":  macho.CpuAmd64,
+		"amd64":   macho.CpuAmd64,
+	} {
+		f, err := OpenArch(fatFile, arch)
+		if !assert.NoError(t, err, arch) {
+			continue
+		}
+		if mf, ok := f.(*macho.File); assert.True(t, ok, arch) {
+			assert.Equal(t, cpu, mf.Cpu, arch)
+		}
+		addrs[configAddress(t, f)] = true
+		assert.NoError(t, f.Close())
+	}
+	// Each slice places the variable differently
+	assert.Len(t, addrs, 2)
+
+	_, err := OpenArch(fatFile, "ppc")
+	assert.ErrorIs(t, err, ErrNoArch)
+
+	// A thin file must match, while other formats ignore the architecture
+	ff, err := macho.OpenFat(fatFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ff.Close()
+	thin := filepath.Join(t.TempDir(), "app.x86_64")
+	copySlice(t, fatFile, ff.Arches[0], thin)
+	f, err := OpenArch(thin, "x86_64")
+	if assert.NoError(t, err) {
+		f.Close()
+	}
+	_, err = OpenArch(thin, "arm64")
+	assert.ErrorIs(t, err, ErrNoArch)
+	f, err = OpenArch(strippedFile, "arm64")
+	if assert.NoError(t, err) {
+		f.Close()
+	}
+}
@@ -0,0 +1,130 @@
+package plat
+
+import (
+	"bytes"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jdginn/durins-door/parser"
+)
+
+// Returned when a file is not a binary of any format we can open
+var ErrUnknownFormat = errors.New("unknown format")
+
+// The formats of binary GetReaderFromFile can open
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatELF
+	FormatMachO
+	// A universal Mach-O binary, holding one Mach-O file per architecture
+	FormatFatMachO
+	FormatPE
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatELF:
+		return "ELF"
+	case FormatMachO:
+		return "Mach-O"
+	case FormatFatMachO:
+		return "fat Mach-O"
+	case FormatPE:
+		return "PE"
+	default:
+		return "unknown"
+	}
+}
+
+// An open binary whose DWARF can be read, whatever its format
+//
+// This is a *parser.ELFFile, *macho.File or *pe.File, which the parser
+// also finds the symbol table of.
+type DebugFile interface {
+	parser.DebugFile
+	io.Closer
+}
+
+// Returns the format of the binary read from r, judged by its contents
+// alone
+func DetectFormat(r io.ReaderAt) (Format, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return FormatUnknown, nil
+		}
+		return FormatUnknown, err
+	}
+	switch binary.BigEndian.Uint32(magic[:]) {
+	case 0x7f454c46:
+		return FormatELF, nil
+	case macho.Magic32, macho.Magic64:
+		return FormatMachO, nil
+	case macho.MagicFat:
+		return FormatFatMachO, nil
+	}
+	switch binary.LittleEndian.Uint32(magic[:]) {
+	case macho.Magic32, macho.Magic64:
+		return FormatMachO, nil
+	}
+	if bytes.HasPrefix(magic[:], []byte("MZ")) {
+		// The DOS header gives the offset of the PE signature at 0x3c
+		var b [4]byte
+		if _, err := r.ReadAt(b[:], 0x3c); err != nil {
+			return FormatUnknown, nil
+		}
+		if _, err := r.ReadAt(b[:], int64(binary.LittleEndian.Uint32(b[:]))); err == nil && string(b[:]) == "PE\x00\x00" {
+			return FormatPE, nil
+		}
+	}
+	return FormatUnknown, nil
+}
+
+// Opens a binary of any supported format, chosen by its contents rather
+// than by the platform we run on
+//
+// ELF debug sections are decompressed whether they are compressed with
+// zlib or zstd or stored as legacy .zdebug sections. Universal Mach-O
+// binaries are read from the slice for the host's architecture if they
+// have one, or else their first; see OpenArch to choose another.
+func GetReaderFromFile(f string) (DebugFile, error) {
+	return OpenArch(f, "")
+}
+
+// Opens a binary as GetReaderFromFile does, reading a universal Mach-O
+// binary from its slice for the architecture with this name, such as arm64
+// or x86_64
+//
+// A thin Mach-O file must be of this architecture. Other formats hold a
+// single architecture and ignore arch, as GetReaderFromFile does when it
+// is empty.
+func OpenArch(f string, arch string) (DebugFile, error) {
+	fh, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	format, err := DetectFormat(fh)
+	fh.Close()
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatELF:
+		return parser.OpenELF(f)
+	case FormatMachO:
+		return openMachO(f, arch)
+	case FormatFatMachO:
+		return openFat(f, arch)
+	case FormatPE:
+		return pe.Open(f)
+	default:
+		return nil, fmt.Errorf("%s is not an ELF, Mach-O or PE file: %w", f, ErrUnknownFormat)
+	}
+}
@@ -0,0 +1,151 @@
+package plat
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/parser"
+)
+
+func TestGetReaderFromFileCompressed(t *testing.T) {
+	// Built by testdata/build.sh, each with its debug sections compressed
+	// differently
+	for file, compressed := range map[string]string{
+		"testdata/compressed/app.zlib":   ".debug_info",
+		"testdata/compressed/app.zstd":   ".debug_info",
+		"testdata/compressed/app.zdebug": ".zdebug_info",
+	} {
+		t.Run(file, func(t *testing.T) {
+			f, err := GetReaderFromFile(file)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer f.Close()
+			ef, ok := f.(*parser.ELFFile)
+			if !assert.True(t, ok) {
+				return
+			}
+			s := ef.Section(compressed)
+			if assert.NotNil(t, s) && compressed == ".debug_info" {
+				assert.NotZero(t, s.Flags&elf.SHF_COMPRESSED)
+			}
+
+			prog, err := parser.NewProgramFromFile(f, parser.SearchPaths{Binary: file})
+			if !assert.NoError(t, err) {
+				return
+			}
+			e, _, err := prog.Index().GetEntry("config")
+			assert.NoError(t, err)
+			v, err := prog.Variable(e)
+			assert.NoError(t, err)
+			assert.Equal(t, parser.SourceDWARF, v.Source)
+			port, err := v.Field("port")
+			assert.NoError(t, err)
+			assert.Equal(t, 16, port.BitSize)
+			assert.Equal(t, v.Address+4, port.Address)
+
+			// Line tables and strings are compressed too
+			cus := prog.Index().CUs()
+			if assert.Len(t, cus, 1) {
+				assert.Equal(t, "app.c", cus[0].Name)
+				files, err := prog.Files(cus[0].Offset)
+				assert.NoError(t, err)
+				assert.Contains(t, files, "app.c")
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for file, want := range map[string]Format{
+		strippedFile:                   FormatELF,
+		"testdata/compressed/app.zstd": FormatELF,
+		"testdata/formats/app.exe":     FormatPE,
+		"testdata/formats/app.fat":     FormatFatMachO,
+		"testdata/app.c":               FormatUnknown,
+	} {
+		fh, err := os.Open(file)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		got, err := DetectFormat(fh)
+		fh.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, file)
+	}
+}
+
+// Checks that the program written by testdata/formats/gen.go reads the
+// same from a binary of any format
+func checkFormatsProgram(t *testing.T, path string) {
+	f, err := GetReaderFromFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	prog, err := parser.NewProgramFromFile(f, parser.SearchPaths{Binary: path})
+	if !assert.NoError(t, err) {
+		return
+	}
+	e, _, err := prog.Index().GetEntry("main.config")
+	if !assert.NoError(t, err) {
+		return
+	}
+	v, err := prog.Variable(e)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, parser.SourceDWARF, v.Source)
+	assert.Equal(t, 8*16, v.Type.BitSize())
+	port, err := v.Field("Port")
+	assert.NoError(t, err)
+	assert.Equal(t, v.Address+4, port.Address)
+
+	// COFF symbols are placed by their section
+	if f, ok := f.(*pe.File); ok {
+		syms, err := parser.PESymbols(f)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(v.Address), syms["main.config"])
+	}
+}
+
+func TestGetReaderFromFileFormats(t *testing.T) {
+	t.Run("PE", func(t *testing.T) {
+		checkFormatsProgram(t, "testdata/formats/app.exe")
+		f, err := GetReaderFromFile("testdata/formats/app.exe")
+		assert.NoError(t, err)
+		assert.IsType(t, &pe.File{}, f)
+		f.Close()
+	})
+	t.Run("fat Mach-O", func(t *testing.T) {
+		checkFormatsProgram(t, "testdata/formats/app.fat")
+	})
+	t.Run("Mach-O", func(t *testing.T) {
+		// A thin binary is one slice of the universal one
+		ff, err := macho.OpenFat("testdata/formats/app.fat")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ff.Close()
+		for _, arch := range ff.Arches {
+			path := filepath.Join(t.TempDir(), arch.Cpu.String())
+			copySlice(t, "testdata/formats/app.fat", arch, path)
+			checkFormatsProgram(t, path)
+		}
+	})
+
+	_, err := GetReaderFromFile("testdata/app.c")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func copySlice(t *testing.T, fat string, arch macho.FatArch, dst string) {
+	b, err := os.ReadFile(fat)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(dst, b[arch.Offset:arch.Offset+arch.Size], 0o644))
+}
@@ -0,0 +1,12 @@
+struct Config {
+    int mode;
+    unsigned short port;
+    char name[8];
+};
+
+struct Config config = {2, 8080, "durin"};
+int counter = 7;
+
+int main(void) {
+    return config.mode + counter;
+}
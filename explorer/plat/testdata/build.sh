@@ -0,0 +1,29 @@
+#!/bin/sh
+# Rebuilds the stripped binary app and its separate debug file from app.c.
+#
+# The debug file is placed where it can be found both through the build id
+# (debug/.build-id/xx/yyyy.debug, with debug standing in for /usr/lib/debug)
+# and through the .gnu_debuglink of app (.debug/app.debug next to app).
+#
+# Copies of the unstripped binary with its debug sections compressed are
+# written to compressed/: app.zlib and app.zstd flagged SHF_COMPRESSED, and
+# app.zdebug with legacy .zdebug sections. The zstd copy is made by
+# zstdelf.py since objcopy is often built without zstd.
+set -e
+cd "$(dirname "$0")"
+
+rm -rf app .debug debug compressed
+gcc -g -O0 -Wl,--build-id -fdebug-prefix-map="$PWD"=. app.c -o app.full
+mkdir .debug
+objcopy --only-keep-debug app.full .debug/app.debug
+objcopy --strip-all --add-gnu-debuglink=.debug/app.debug app.full app
+mkdir compressed
+objcopy --compress-debug-sections=zlib app.full compressed/app.zlib
+objcopy --compress-debug-sections=zlib-gnu app.full compressed/app.zdebug
+python3 zstdelf.py app.full compressed/app.zstd
+rm app.full
+
+id=$(readelf -n app | sed -n 's/.*Build ID: \([0-9a-f]*\)/\1/p')
+dir=debug/.build-id/$(echo "$id" | cut -c1-2)
+mkdir -p "$dir"
+cp .debug/app.debug "$dir/$(echo "$id" | cut -c3-).debug"
@@ -0,0 +1,14 @@
+#!/bin/sh
+# Rewrites the binaries of the program described in gen.go for platforms
+# other than Linux:
+#
+#   app.exe  a Windows PE for amd64
+#   app.fat  a universal Mach-O with x86_64 and arm64 slices
+#
+# They are written by gen.go rather than built, since a toolchain would link
+# in a runtime many times the size of the program and its DWARF.
+set -e
+cd "$(dirname "$0")"
+
+rm -f app.exe app.fat
+go run gen.go
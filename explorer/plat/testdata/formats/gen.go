@@ -0,0 +1,346 @@
+// Writes app.exe and app.fat, the smallest binaries of each format that
+// hold the DWARF and initial value of
+//
+//	package main
+//
+//	type Config struct {
+//		Mode uint32
+//		Port uint16
+//		Name [8]byte
+//	}
+//
+//	var config = Config{Mode: 2, Port: 8080, Name: [8]byte{'d', 'u', 'r', 'i', 'n'}}
+//
+//	func main() {}
+//
+// named as the Go toolchain names them. The binaries cannot run: main only
+// returns, and nothing else a loader needs is there.
+package main
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"log"
+	"math/bits"
+	"os"
+	"strconv"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+// The initial value of config
+var config = []byte{2, 0, 0, 0, 0x90, 0x1f, 'd', 'u', 'r', 'i', 'n', 0, 0, 0, 0, 0}
+
+// Assembles the DWARF of the program with config at addr and main at text
+func debugInfo(addr, text uint64) *dwarftest.Sections {
+	base := func(name string, size int) *dwarftest.DIE {
+		return dwarftest.New(dwarf.TagBaseType,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: name},
+			dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: size},
+			// DW_ATE_unsigned
+			dwarftest.Attr{Attr: dwarf.AttrEncoding, Val: 0x08})
+	}
+	member := func(name string, t *dwarftest.DIE, offset int) *dwarftest.DIE {
+		return dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: name},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: t},
+			dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: offset})
+	}
+	u8, u16, u32 := base("uint8", 1), base("uint16", 2), base("uint32", 4)
+	name := dwarftest.New(dwarf.TagArrayType,
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: u8},
+	).With(dwarftest.New(dwarf.TagSubrangeType,
+		dwarftest.Attr{Attr: dwarf.AttrCount, Val: 8}))
+	configType := dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "main.Config"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: len(config)},
+	).With(member("Mode", u32, 0), member("Port", u16, 4), member("Name", name, 6))
+	// DW_OP_addr
+	loc := make([]byte, 9)
+	loc[0] = 0x03
+	binary.LittleEndian.PutUint64(loc[1:], addr)
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "main"},
+		// DW_LANG_Go
+		dwarftest.Attr{Attr: dwarf.AttrLanguage, Val: 0x16},
+	).With(u8, u16, u32, name, configType,
+		dwarftest.New(dwarf.TagVariable,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "main.config"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: configType},
+			dwarftest.Attr{Attr: dwarf.AttrExternal, Val: true},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: loc}),
+		dwarftest.New(dwarf.TagSubprogram,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "main.main"},
+			dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: text},
+			dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 4},
+			dwarftest.Attr{Attr: dwarf.AttrExternal, Val: true}))
+	s, err := dwarftest.Assemble(cu)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+func put(b *bytes.Buffer, v interface{}) {
+	if err := binary.Write(b, binary.LittleEndian, v); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Pads b with zeros up to offset
+func pad(b *bytes.Buffer, offset uint64) {
+	if uint64(b.Len()) > offset {
+		log.Fatalf("contents overrun offset %#x", offset)
+	}
+	b.Write(make([]byte, offset-uint64(b.Len())))
+}
+
+func align(v, to uint64) uint64 {
+	return (v + to - 1) &^ (to - 1)
+}
+
+func name16(s string) (n [16]byte) {
+	copy(n[:], s)
+	return n
+}
+
+// A Mach-O executable of one architecture
+type slice struct {
+	cpu    macho.Cpu
+	subCpu uint32
+	// The size of a page, to which segments are aligned
+	page uint64
+	// The instructions of main, which only returns
+	ret []byte
+}
+
+// Writes a 64-bit Mach-O executable with segments __TEXT, holding main,
+// __DATA, holding config, and __DWARF, and a symbol table naming both
+func (s slice) write() []byte {
+	const (
+		vmaddr       = 0x100000000
+		textOff      = 0x400
+		instructions = 0x80000400
+		debug        = 0x02000000
+		nSectExt     = 0x0f
+	)
+	dataOff, dwarfOff := s.page, 2*s.page
+	d := debugInfo(vmaddr+dataOff, vmaddr+textOff)
+	dwarfSize := uint64(len(d.Abbrev) + len(d.Info))
+	symOff := align(dwarfOff+dwarfSize, 8)
+	strs := "\x00_main.main\x00_main.config\x00"
+	strOff := symOff + 2*16
+
+	var cmds bytes.Buffer
+	segment := func(name string, off, size uint64, sects ...macho.Section64) {
+		put(&cmds, macho.Segment64{
+			Cmd:     macho.LoadCmdSegment64,
+			Len:     uint32(72 + 80*len(sects)),
+			Name:    name16(name),
+			Addr:    vmaddr + off,
+			Memsz:   align(size, s.page),
+			Offset:  off,
+			Filesz:  size,
+			Maxprot: 7,
+			Prot:    3,
+			Nsect:   uint32(len(sects)),
+		})
+		for _, sect := range sects {
+			put(&cmds, sect)
+		}
+	}
+	segment("__TEXT", 0, s.page, macho.Section64{
+		Name: name16("__text"), Seg: name16("__TEXT"),
+		Addr: vmaddr + textOff, Size: uint64(len(s.ret)), Offset: textOff, Align: 2, Flags: instructions,
+	})
+	segment("__DATA", dataOff, uint64(len(config)), macho.Section64{
+		Name: name16("__data"), Seg: name16("__DATA"),
+		Addr: vmaddr + dataOff, Size: uint64(len(config)), Offset: uint32(dataOff), Align: 3,
+	})
+	segment("__DWARF", dwarfOff, dwarfSize, macho.Section64{
+		Name: name16("__debug_abbrev"), Seg: name16("__DWARF"),
+		Addr: vmaddr + dwarfOff, Size: uint64(len(d.Abbrev)), Offset: uint32(dwarfOff), Flags: debug,
+	}, macho.Section64{
+		Name: name16("__debug_info"), Seg: name16("__DWARF"),
+		Addr: vmaddr + dwarfOff + uint64(len(d.Abbrev)), Size: uint64(len(d.Info)),
+		Offset: uint32(dwarfOff) + uint32(len(d.Abbrev)), Flags: debug,
+	})
+	put(&cmds, macho.SymtabCmd{
+		Cmd: macho.LoadCmdSymtab, Len: 24,
+		Symoff: uint32(symOff), Nsyms: 2, Stroff: uint32(strOff), Strsize: uint32(len(strs)),
+	})
+
+	var b bytes.Buffer
+	put(&b, macho.FileHeader{
+		Magic:  macho.Magic64,
+		Cpu:    s.cpu,
+		SubCpu: s.subCpu,
+		Type:   macho.TypeExec,
+		Ncmd:   4,
+		Cmdsz:  uint32(cmds.Len()),
+	})
+	// The reserved word of the 64-bit header
+	put(&b, uint32(0))
+	b.Write(cmds.Bytes())
+	pad(&b, textOff)
+	b.Write(s.ret)
+	pad(&b, dataOff)
+	b.Write(config)
+	pad(&b, dwarfOff)
+	b.Write(d.Abbrev)
+	b.Write(d.Info)
+	pad(&b, symOff)
+	put(&b, macho.Nlist64{Name: 1, Type: nSectExt, Sect: 1, Value: vmaddr + textOff})
+	put(&b, macho.Nlist64{Name: 12, Type: nSectExt, Sect: 2, Value: vmaddr + dataOff})
+	b.WriteString(strs)
+	return b.Bytes()
+}
+
+// Writes a universal binary of these slices, each aligned to its page
+func writeFat(slices ...slice) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(slices))})
+	bins := make([][]byte, len(slices))
+	offsets := make([]uint64, len(slices))
+	end := uint64(8 + 20*len(slices))
+	for i, s := range slices {
+		bins[i] = s.write()
+		offsets[i] = align(end, s.page)
+		end = offsets[i] + uint64(len(bins[i]))
+		binary.Write(&b, binary.BigEndian, macho.FatArchHeader{
+			Cpu:    s.cpu,
+			SubCpu: s.subCpu,
+			Offset: uint32(offsets[i]),
+			Size:   uint32(len(bins[i])),
+			Align:  uint32(bits.TrailingZeros64(s.page)),
+		})
+	}
+	for i := range bins {
+		pad(&b, offsets[i])
+		b.Write(bins[i])
+	}
+	return b.Bytes()
+}
+
+// Writes a PE32+ executable for amd64 with sections .text, holding main,
+// .data, holding config, and the DWARF, and a COFF symbol table naming
+// main and config
+func writePE() []byte {
+	const (
+		imageBase   = 0x140000000
+		sectAlign   = 0x1000
+		fileAlign   = 0x200
+		headersSize = 0x400
+	)
+	d := debugInfo(imageBase+2*sectAlign, imageBase+sectAlign)
+	// Long section and symbol names are offsets into the string table,
+	// which starts with its own size
+	var strs bytes.Buffer
+	put(&strs, uint32(0))
+	long := func(s string) uint32 {
+		off := uint32(strs.Len())
+		strs.WriteString(s)
+		strs.WriteByte(0)
+		return off
+	}
+	type section struct {
+		name  string
+		data  []byte
+		flags uint32
+	}
+	sections := []section{
+		{".text", []byte{0xc3}, pe.IMAGE_SCN_CNT_CODE | pe.IMAGE_SCN_MEM_EXECUTE | pe.IMAGE_SCN_MEM_READ},
+		{".data", config, pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ | pe.IMAGE_SCN_MEM_WRITE},
+		{".debug_abbrev", d.Abbrev, pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_DISCARDABLE | pe.IMAGE_SCN_MEM_READ},
+		{".debug_info", d.Info, pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_DISCARDABLE | pe.IMAGE_SCN_MEM_READ},
+	}
+	headers := make([]pe.SectionHeader32, len(sections))
+	raw := uint32(headersSize)
+	for i, s := range sections {
+		var n [8]uint8
+		if len(s.name) > 8 {
+			copy(n[:], "/"+strconv.Itoa(int(long(s.name))))
+		} else {
+			copy(n[:], s.name)
+		}
+		headers[i] = pe.SectionHeader32{
+			Name:             n,
+			VirtualSize:      uint32(len(s.data)),
+			VirtualAddress:   uint32(sectAlign * (i + 1)),
+			SizeOfRawData:    uint32(align(uint64(len(s.data)), fileAlign)),
+			PointerToRawData: raw,
+			Characteristics:  s.flags,
+		}
+		raw += headers[i].SizeOfRawData
+	}
+	symbols := []pe.COFFSymbol{
+		{Value: 0, SectionNumber: 1, Type: 0x20, StorageClass: 2},
+		{Value: 0, SectionNumber: 2, StorageClass: 2},
+	}
+	for i, name := range []string{"main.main", "main.config"} {
+		binary.LittleEndian.PutUint32(symbols[i].Name[4:], long(name))
+	}
+	binary.LittleEndian.PutUint32(strs.Bytes(), uint32(strs.Len()))
+
+	var b bytes.Buffer
+	b.WriteString("MZ")
+	pad(&b, 0x3c)
+	put(&b, uint32(0x40))
+	b.WriteString("PE\x00\x00")
+	put(&b, pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		NumberOfSections:     uint16(len(sections)),
+		PointerToSymbolTable: raw,
+		NumberOfSymbols:      uint32(len(symbols)),
+		SizeOfOptionalHeader: uint16(binary.Size(pe.OptionalHeader64{})),
+		Characteristics:      pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_LARGE_ADDRESS_AWARE,
+	})
+	put(&b, pe.OptionalHeader64{
+		Magic:                 0x20b,
+		SizeOfCode:            fileAlign,
+		AddressOfEntryPoint:   sectAlign,
+		BaseOfCode:            sectAlign,
+		ImageBase:             imageBase,
+		SectionAlignment:      sectAlign,
+		FileAlignment:         fileAlign,
+		MajorSubsystemVersion: 6,
+		SizeOfImage:           uint32(sectAlign * (len(sections) + 1)),
+		SizeOfHeaders:         headersSize,
+		// IMAGE_SUBSYSTEM_WINDOWS_CUI
+		Subsystem:           3,
+		NumberOfRvaAndSizes: 16,
+	})
+	put(&b, headers)
+	for i, s := range sections {
+		pad(&b, uint64(headers[i].PointerToRawData))
+		b.Write(s.data)
+	}
+	pad(&b, uint64(raw))
+	put(&b, symbols)
+	b.Write(strs.Bytes())
+	return b.Bytes()
+}
+
+func itoa(v uint32) string {
+	if v < 10 {
+		return string(rune('0' + v))
+	}
+	return itoa(v/10) + string(rune('0'+v%10))
+}
+
+func main() {
+	fat := writeFat(
+		slice{cpu: macho.CpuAmd64, subCpu: 3, page: 0x1000, ret: []byte{0xc3}},
+		// ret
+		slice{cpu: macho.CpuArm64, page: 0x4000, ret: []byte{0xc0, 0x03, 0x5f, 0xd6}},
+	)
+	if err := os.WriteFile("app.fat", fat, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("app.exe", writePE(), 0o755); err != nil {
+		log.Fatal(err)
+	}
+}
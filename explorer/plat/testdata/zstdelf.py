@@ -0,0 +1,57 @@
+#!/usr/bin/env python3
+"""Compresses the .debug_* sections of a 64-bit little-endian ELF with zstd
+
+    zstdelf.py INPUT OUTPUT
+
+Does what objcopy --compress-debug-sections=zstd does on binutils built
+with zstd: each section becomes an Elf64_Chdr of type ELFCOMPRESS_ZSTD
+followed by one zstd frame, and is flagged SHF_COMPRESSED. The compressed
+sections and then the section headers are appended to the file, leaving
+the old contents in place. Compression is done by the zstd command.
+"""
+import struct
+import subprocess
+import sys
+
+SHF_COMPRESSED = 0x800
+ELFCOMPRESS_ZSTD = 2
+SHDR = struct.Struct("<IIQQQQIIQQ")
+
+
+def main(src, dst):
+    with open(src, "rb") as f:
+        elf = bytearray(f.read())
+    if elf[:4] != b"\x7fELF" or elf[4] != 2 or elf[5] != 1:
+        sys.exit("%s is not a 64-bit little-endian ELF file" % src)
+    shoff, = struct.unpack_from("<Q", elf, 0x28)
+    shentsize, shnum, shstrndx = struct.unpack_from("<HHH", elf, 0x3a)
+    headers = [list(SHDR.unpack_from(elf, shoff + i * shentsize)) for i in range(shnum)]
+    strtab = headers[shstrndx]
+
+    for h in headers:
+        name = elf[strtab[4] + h[0]:elf.index(b"\0", strtab[4] + h[0])].decode()
+        if not name.startswith(".debug_") or h[2] & SHF_COMPRESSED:
+            continue
+        data = bytes(elf[h[4]:h[4] + h[5]])
+        frame = subprocess.run(["zstd", "-q", "-19", "-c"], input=data,
+                               stdout=subprocess.PIPE, check=True).stdout
+        contents = struct.pack("<IIQQ", ELFCOMPRESS_ZSTD, 0, len(data), h[8]) + frame
+        elf += b"\0" * (-len(elf) % 8)
+        h[2] |= SHF_COMPRESSED
+        h[4] = len(elf)
+        h[5] = len(contents)
+        h[8] = 8
+        elf += contents
+
+    elf += b"\0" * (-len(elf) % 8)
+    struct.pack_into("<Q", elf, 0x28, len(elf))
+    for h in headers:
+        elf += SHDR.pack(*h)
+    with open(dst, "wb") as f:
+        f.write(elf)
+
+
+if __name__ == "__main__":
+    if len(sys.argv) != 3:
+        sys.exit(__doc__)
+    main(sys.argv[1], sys.argv[2])
@@ -0,0 +1,12 @@
+#!/bin/sh
+# Rebuilds the executable app and the shared library libteam.so it links
+# against, each with its own DWARF.
+#
+# app is built with -fPIC so that it reaches team and wins through the GOT
+# rather than copying them into its own .bss, leaving the library's DWARF as
+# the only definition of either.
+set -e
+cd "$(dirname "$0")"
+
+gcc -g -O0 -fPIC -shared -fdebug-prefix-map="$PWD"=. team.c -o libteam.so
+gcc -g -O0 -fPIC -fdebug-prefix-map="$PWD"=. main.c -L. -lteam -Wl,-rpath,'$ORIGIN' -o app
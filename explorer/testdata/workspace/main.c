@@ -0,0 +1,16 @@
+/* Only the library knows the layout of a Team */
+struct Team;
+
+extern struct Team team;
+extern int wins;
+int score(int races);
+
+int races = 22;
+
+struct Team *current(void) {
+    return &team;
+}
+
+int main(void) {
+    return score(races) + wins + (current() != 0);
+}
@@ -0,0 +1,12 @@
+struct Team {
+    int points;
+    unsigned short car;
+    char name[8];
+};
+
+struct Team team = {44, 16, "ferrari"};
+int wins = 3;
+
+int score(int races) {
+    return team.points * races + wins;
+}
@@ -0,0 +1,300 @@
+package explorer
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/jdginn/durins-door/parser"
+)
+
+// A Module is one binary of a workspace, such as an executable or a shared
+// library, along with the address it is loaded at
+type Module struct {
+	// Names the module in paths, such as "libc.so.6:printf.c"
+	Name    string
+	Path    string
+	Program *parser.Program
+	// Added to every address the module's DWARF gives to place it in the
+	// workspace's address space
+	Base uint64
+}
+
+// A Workspace is a set of binaries sharing one address space, such as an
+// executable and the shared libraries it uses, each with its own DWARF
+//
+// Declarations in one module are resolved against definitions in the
+// others, as the dynamic linker would, and every address is given in the
+// shared address space. Modules should all be added before the workspace
+// is explored.
+type Workspace struct {
+	// Directories searched for separate debug files and split DWARF by Load,
+	// or nil for plat.DefaultDebugDirs
+	DebugDirs []string
+	// The architecture Load reads universal Mach-O binaries for, or empty
+	// for the host's
+	Arch    string
+	modules []*Module
+}
+
+// Returns a workspace with no modules
+func NewWorkspace() *Workspace {
+	return &Workspace{}
+}
+
+// Loads the binary at this path as a module loaded at base, named after
+// the file if name is empty
+func (w *Workspace) Load(name string, path string, base uint64) (*Module, error) {
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	program, err := LoadProgram(path, w.Arch, w.DebugDirs)
+	if err != nil {
+		return nil, err
+	}
+	m := &Module{Name: name, Path: path, Program: program, Base: base}
+	return m, w.Add(m)
+}
+
+// Adds an already loaded module
+//
+// Modules are searched in the order they were added, so the executable
+// should come first, as it does for the dynamic linker.
+func (w *Workspace) Add(m *Module) error {
+	if _, err := w.Module(m.Name); err == nil {
+		return fmt.Errorf("%s: %w", m.Name, ErrDuplicateModule)
+	}
+	w.modules = append(w.modules, m)
+	return nil
+}
+
+// Returns every module, in the order they were added
+func (w *Workspace) Modules() []*Module {
+	ret := make([]*Module, len(w.modules))
+	copy(ret, w.modules)
+	return ret
+}
+
+// Returns the module with this name
+func (w *Workspace) Module(name string) (*Module, error) {
+	for _, m := range w.modules {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrUnknownModule)
+}
+
+// Returns true if an entry only declares something defined elsewhere: a
+// variable with no location, a function with no code or an incomplete type
+func isDeclaration(entry *dwarf.Entry) bool {
+	switch entry.Tag {
+	case dwarf.TagVariable:
+		return !parser.HasAttr(entry, dwarf.AttrLocation)
+	case dwarf.TagSubprogram:
+		return !parser.HasAttr(entry, dwarf.AttrLowpc) && !parser.HasAttr(entry, dwarf.AttrRanges)
+	default:
+		return parser.HasAttr(entry, dwarf.AttrDeclaration)
+	}
+}
+
+// Returns the definition of an entry of module m: the entry itself, or the
+// definition it is completed with in m, or else the first definition of
+// the same name and tag in another module. Declarations defined nowhere
+// are returned unchanged.
+func (w *Workspace) Resolve(m *Module, entry *dwarf.Entry) (*Module, *dwarf.Entry, error) {
+	entry, err := m.Program.Index().Complete(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isDeclaration(entry) {
+		return m, entry, nil
+	}
+	name := parser.LinkageName(entry)
+	if name == "" {
+		name = parser.EntryName(entry)
+	}
+	if name == "" {
+		return m, entry, nil
+	}
+	for _, other := range w.modules {
+		if other == m {
+			continue
+		}
+		def, err := other.definition(name, entry.Tag)
+		if err != nil {
+			return nil, nil, err
+		}
+		if def != nil {
+			return other, def, nil
+		}
+	}
+	return m, entry, nil
+}
+
+// Returns the first definition with this name and tag, or nil if the
+// module has none
+func (m *Module) definition(name string, tag dwarf.Tag) (*dwarf.Entry, error) {
+	idx := m.Program.Index()
+	for _, ie := range idx.LookupTag(name, tag) {
+		e, err := idx.Entry(ie)
+		if err != nil {
+			return nil, err
+		}
+		if e, err = idx.Complete(e); err != nil {
+			return nil, err
+		}
+		if !isDeclaration(e) {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// Returns the first entry with this name, searching the modules in order
+// and preferring definitions, along with the module it belongs to
+//
+// The name may be qualified by a module, as in "libteam.so:team", to search
+// only that module.
+func (w *Workspace) Lookup(name string) (*Module, *dwarf.Entry, error) {
+	m, e, _, err := w.lookup(name)
+	return m, e, err
+}
+
+// Behaves like Lookup, also returning the compile unit of the entry
+func (w *Workspace) lookup(name string) (*Module, *dwarf.Entry, *dwarf.Entry, error) {
+	modules := w.modules
+	if m, rest, ok := w.splitModule(name); ok {
+		modules, name = []*Module{m}, rest
+	}
+	var found *Module
+	var first, firstCU *dwarf.Entry
+	for _, m := range modules {
+		e, cu, err := m.Program.Index().GetEntry(name)
+		if err != nil {
+			continue
+		}
+		if e.Tag == dwarf.TagCompileUnit || !isDeclaration(e) {
+			return m, e, cu, nil
+		}
+		if first == nil {
+			found, first, firstCU = m, e, cu
+		}
+	}
+	if first == nil {
+		return nil, nil, nil, fmt.Errorf("Could not find entry %v in any module: %w", name, parser.ErrNotFound)
+	}
+	return found, first, firstCU, nil
+}
+
+// Splits a name qualified by a module, as in "libteam.so:team"
+func (w *Workspace) splitModule(name string) (*Module, string, bool) {
+	for _, m := range w.modules {
+		if len(name) > len(m.Name) && name[:len(m.Name)] == m.Name && name[len(m.Name)] == ':' {
+			return m, name[len(m.Name)+1:], true
+		}
+	}
+	return nil, "", false
+}
+
+// Constructs the proxy for a variable of module m, at its address in the
+// workspace
+//
+// A variable the module only declares is taken from the module defining
+// it, unless the module's own symbol table places it, as it does for
+// variables the executable holds copies of.
+func (w *Workspace) Variable(m *Module, entry *dwarf.Entry) (*parser.VariableProxy, *Module, error) {
+	p, err := m.Program.Variable(entry)
+	if err != nil {
+		dm, def, rerr := w.Resolve(m, entry)
+		if rerr != nil {
+			return nil, nil, rerr
+		}
+		if dm == m {
+			return nil, nil, err
+		}
+		if p, err = dm.Program.Variable(def); err != nil {
+			return nil, nil, err
+		}
+		m = dm
+	}
+	p.Address += int(m.Base)
+	return p, m, nil
+}
+
+// Constructs the proxy for a function of module m, or of the module
+// defining it if m only declares it, with its code at its addresses in the
+// workspace
+func (w *Workspace) Function(m *Module, entry *dwarf.Entry) (*parser.FunctionProxy, *Module, error) {
+	m, entry, err := w.Resolve(m, entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := parser.NewFunctionProxy(m.Program, entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.HighPC != 0 {
+		p.LowPC += m.Base
+		p.HighPC += m.Base
+	}
+	relocateRanges(p.Ranges, m.Base)
+	relocateBlocks(p.Blocks, m.Base)
+	for _, inst := range p.Inlined {
+		relocateRanges(inst.Ranges, m.Base)
+	}
+	return p, m, nil
+}
+
+func relocateRanges(ranges [][2]uint64, base uint64) {
+	for i := range ranges {
+		ranges[i][0] += base
+		ranges[i][1] += base
+	}
+}
+
+func relocateBlocks(blocks []parser.LexicalBlock, base uint64) {
+	for _, b := range blocks {
+		relocateRanges(b.Ranges, base)
+		relocateBlocks(b.Blocks, base)
+	}
+}
+
+// Returns the modules that may hold an address, nearest base first
+func (w *Workspace) modulesAt(addr uint64) []*Module {
+	ret := make([]*Module, 0, len(w.modules))
+	for _, m := range w.modules {
+		if addr >= m.Base {
+			ret = append(ret, m)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Base > ret[j].Base })
+	return ret
+}
+
+// Names the variable, member and element containing an address of the
+// workspace, along with the module defining the variable
+func (w *Workspace) Symbolize(addr uint64) (parser.Symbol, *Module, error) {
+	for _, m := range w.modulesAt(addr) {
+		sym, err := m.Program.Symbolize(addr - m.Base)
+		if err == nil {
+			sym.Address += m.Base
+			return sym, m, nil
+		}
+	}
+	return parser.Symbol{}, nil, fmt.Errorf("No variable of any module contains %#x: %w", addr, parser.ErrNotFound)
+}
+
+// Returns the source line containing an address of the workspace, along
+// with the module whose code it is
+func (w *Workspace) AddrToLine(addr uint64) (parser.SourceLine, *Module, error) {
+	for _, m := range w.modulesAt(addr) {
+		l, err := m.Program.AddrToLine(addr - m.Base)
+		if err == nil {
+			l.Address += m.Base
+			return l, m, nil
+		}
+	}
+	return parser.SourceLine{}, nil, fmt.Errorf("No code of any module at %#x: %w", addr, parser.ErrNotFound)
+}
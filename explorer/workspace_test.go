@@ -0,0 +1,173 @@
+package explorer_test
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdginn/durins-door/client"
+	"github.com/jdginn/durins-door/client/image"
+	"github.com/jdginn/durins-door/explorer"
+	"github.com/jdginn/durins-door/parser"
+)
+
+const appBase = 0x555555554000
+const libBase = 0x7ffff7fb0000
+
+// Loads the executable and the library it links against, which alone
+// defines team, wins and score
+func loadWorkspace(t *testing.T) *explorer.Workspace {
+	w := explorer.NewWorkspace()
+	_, err := w.Load("", "testdata/workspace/app", appBase)
+	require.NoError(t, err)
+	_, err = w.Load("", "testdata/workspace/libteam.so", libBase)
+	require.NoError(t, err)
+	return w
+}
+
+// A client over the images of several modules, each at its own base
+type spaceClient []client.Client
+
+func (c spaceClient) Read(addr int, size int) ([]byte, error) {
+	for _, m := range c {
+		if b, err := m.Read(addr, size); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("0x%x is in no module", addr)
+}
+
+func (c spaceClient) Write(addr int, data []byte) error {
+	return image.ErrReadOnly
+}
+
+func (c spaceClient) SetOffset(offset int64) {}
+
+func TestWorkspace(t *testing.T) {
+	w := loadWorkspace(t)
+	names := make([]string, 0)
+	for _, m := range w.Modules() {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"app", "libteam.so"}, names)
+	_, err := w.Load("app", "testdata/workspace/app", 0)
+	assert.ErrorIs(t, err, explorer.ErrDuplicateModule)
+	_, err = w.Module("libc.so.6")
+	assert.ErrorIs(t, err, explorer.ErrUnknownModule)
+
+	// The executable only declares team; the library defines it
+	app, err := w.Module("app")
+	assert.NoError(t, err)
+	decl := entry(t, app, "team")
+	m, def, err := w.Resolve(app, decl)
+	assert.NoError(t, err)
+	assert.Equal(t, "libteam.so", m.Name)
+	assert.NotEqual(t, decl.Offset, def.Offset)
+
+	v, m, err := w.Variable(app, decl)
+	assert.NoError(t, err)
+	assert.Equal(t, "libteam.so", m.Name)
+	assert.Equal(t, libBase+0x4010, v.Address)
+	assert.Equal(t, 16*8, v.Type.BitSize())
+
+	v, m, err = w.Variable(app, entry(t, app, "races"))
+	assert.NoError(t, err)
+	assert.Equal(t, "app", m.Name)
+	assert.Equal(t, appBase+0x4018, v.Address)
+
+	f, m, err := w.Function(app, entry(t, app, "score"))
+	assert.NoError(t, err)
+	assert.Equal(t, "libteam.so", m.Name)
+	assert.Equal(t, uint64(libBase+0x10f9), f.LowPC)
+
+	// Lookup prefers the definition, unless qualified by a module
+	m, e, err := w.Lookup("wins")
+	assert.NoError(t, err)
+	assert.Equal(t, "libteam.so", m.Name)
+	assert.True(t, parser.HasAttr(e, dwarf.AttrLocation))
+	m, e, err = w.Lookup("app:wins")
+	assert.NoError(t, err)
+	assert.Equal(t, "app", m.Name)
+	assert.False(t, parser.HasAttr(e, dwarf.AttrLocation))
+	_, _, err = w.Lookup("losses")
+	assert.ErrorIs(t, err, parser.ErrNotFound)
+
+	sym, m, err := w.Symbolize(libBase + 0x4010 + 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "libteam.so", m.Name)
+	assert.Equal(t, "team.name[0]", sym.String())
+	assert.Equal(t, uint64(libBase+0x4010), sym.Address)
+	_, m, err = w.AddrToLine(appBase + 0x1146)
+	assert.NoError(t, err)
+	assert.Equal(t, "app", m.Name)
+	_, _, err = w.Symbolize(0x10)
+	assert.ErrorIs(t, err, parser.ErrNotFound)
+}
+
+func TestExploreWorkspace(t *testing.T) {
+	ex := explorer.NewExplorerFromWorkspace(loadWorkspace(t))
+	names, err := ex.ListCUs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app:main.c", "libteam.so:team.c"}, names)
+
+	// The executable's declaration of team is read from the library
+	assert.NoError(t, ex.Goto("app:main.c/team"))
+	assert.Equal(t, "app:main.c/team", ex.Path())
+	assert.Equal(t, []string{"points", "car", "name"}, ex.ListChildren())
+	field, err := ex.Field("car")
+	assert.NoError(t, err)
+	assert.Equal(t, libBase+0x4014, field.Address)
+
+	appImage, err := image.NewFromPath("testdata/workspace/app")
+	assert.NoError(t, err)
+	appImage.SetOffset(appBase)
+	libImage, err := image.NewFromPath("testdata/workspace/libteam.so")
+	assert.NoError(t, err)
+	libImage.SetOffset(libBase)
+	ex.SetClient(spaceClient{appImage, libImage})
+
+	b, err := ex.ReadField("points")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(44), binary.LittleEndian.Uint32(b))
+	assert.NoError(t, ex.Goto("app:main.c/races"))
+	b, err = ex.ReadField("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(22), binary.LittleEndian.Uint32(b))
+
+	// Unqualified CU names are looked up in every module
+	assert.NoError(t, ex.Back())
+	assert.NoError(t, ex.Back())
+	assert.NoError(t, ex.StepIntoChild("team.c"))
+	assert.Equal(t, "libteam.so:team.c", ex.Path())
+	assert.NoError(t, ex.StepIntoChild("wins"))
+	b, err = ex.ReadField("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), binary.LittleEndian.Uint32(b))
+
+	// Names other than CUs are stepped into through their CU
+	assert.NoError(t, ex.Goto(""))
+	assert.NoError(t, ex.StepIntoChild("libteam.so:wins"))
+	assert.Equal(t, "libteam.so:team.c/wins", ex.Path())
+	assert.NoError(t, ex.Goto(ex.Path()))
+	assert.Equal(t, "wins", ex.CurrName())
+	assert.NoError(t, ex.Goto(""))
+	assert.NoError(t, ex.StepIntoChild("libteam.so:team.car"))
+	assert.Equal(t, "libteam.so:team.c/team.car", ex.Path())
+
+	assert.NoError(t, ex.Goto("libteam.so:team.c/score"))
+	assert.Equal(t, "team.c", ex.Program().Index().CUs()[0].Name)
+	assert.Contains(t, ex.Info(), fmt.Sprintf("%#x", libBase+0x10f9))
+	assert.NoError(t, ex.Goto("app:main.c/score"))
+	assert.Contains(t, ex.Info(), "team.c:")
+	assert.Error(t, ex.Goto("libc.so.6:printf.c"))
+}
+
+func entry(t *testing.T, m *explorer.Module, name string) *dwarf.Entry {
+	e, _, err := m.Program.Index().GetEntry(name)
+	assert.NoError(t, err)
+	return e
+}
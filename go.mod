@@ -2,12 +2,24 @@ module github.com/jdginn/durins-door
 
 go 1.18
 
-require github.com/stretchr/testify v1.7.0
+require (
+	github.com/ianlancetaylor/demangle v0.0.0-20240912202439-0a2b6291aafd
+	github.com/klauspost/compress v1.15.15
+	github.com/stretchr/testify v1.7.0
+	golang.org/x/term v0.5.0
+	google.golang.org/grpc v1.50.0
+	google.golang.org/protobuf v1.28.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.0.0-20201021035429-f5854403a974 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/text v0.3.3 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
@@ -0,0 +1,289 @@
+// Package dwarftest assembles small DWARF sections in memory so that tests
+// can exercise constructs the compiled testcase does not contain.
+//
+// A unit is described as a tree of DIEs. Attribute values are encoded with a
+// form chosen from their Go type:
+//
+//	string       DW_FORM_string
+//	int, int64   DW_FORM_sdata
+//	uint64       DW_FORM_addr
+//	bool         DW_FORM_flag
+//	[]byte       DW_FORM_exprloc
+//	*DIE         DW_FORM_ref4
+//
+// A compile unit may also be given a line table with Lines.
+package dwarftest
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+)
+
+// Attribute form constants from the DWARF 4 standard
+const (
+	formAddr    = 0x01
+	formString  = 0x08
+	formFlag    = 0x0c
+	formSdata   = 0x0d
+	formRef4    = 0x13
+	formExprloc = 0x18
+)
+
+// A single attribute of a DIE
+type Attr struct {
+	Attr dwarf.Attr
+	Val  interface{}
+}
+
+// A debugging information entry along with its children
+type DIE struct {
+	Tag      dwarf.Tag
+	Attrs    []Attr
+	Children []*DIE
+
+	offset uint32
+	lines  *lineTable
+}
+
+// A row of a line table: the instruction at Address starts Line
+type Row struct {
+	Address uint64
+	Line    int
+}
+
+type lineTable struct {
+	file string
+	end  uint64
+	rows []Row
+}
+
+// Returns the offset of this DIE in the assembled .debug_info
+//
+// Only valid after the DIE has been assembled by Build.
+func (d *DIE) Offset() dwarf.Offset {
+	return dwarf.Offset(d.offset)
+}
+
+// Returns a DIE with these attributes
+func New(tag dwarf.Tag, attrs ...Attr) *DIE {
+	return &DIE{Tag: tag, Attrs: attrs}
+}
+
+// Appends children to this DIE and returns it
+func (d *DIE) With(children ...*DIE) *DIE {
+	d.Children = append(d.Children, children...)
+	return d
+}
+
+// Adds an attribute to this DIE and returns it
+func (d *DIE) Set(attr dwarf.Attr, val interface{}) *DIE {
+	d.Attrs = append(d.Attrs, Attr{attr, val})
+	return d
+}
+
+// Gives this compile unit a line table of one sequence in one file, from the
+// first row to end, and returns it
+func (d *DIE) Lines(file string, end uint64, rows ...Row) *DIE {
+	d.lines = &lineTable{file, end, rows}
+	return d
+}
+
+type fixup struct {
+	at     int
+	target *DIE
+}
+
+type builder struct {
+	abbrev bytes.Buffer
+	info   bytes.Buffer
+	code   uint64
+	fixups []fixup
+	line   bytes.Buffer
+}
+
+func putULEB(b *bytes.Buffer, v uint64) {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b.WriteByte(c)
+		if v == 0 {
+			return
+		}
+	}
+}
+
+func putSLEB(b *bytes.Buffer, v int64) {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		done := (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0)
+		if !done {
+			c |= 0x80
+		}
+		b.WriteByte(c)
+		if done {
+			return
+		}
+	}
+}
+
+// Appends a DWARF 4 line program for this table to .debug_line and returns
+// its offset
+func (b *builder) lineProgram(t *lineTable) int64 {
+	start := b.line.Len()
+	// unit_length and header_length are patched once known
+	b.line.Write([]byte{0, 0, 0, 0})
+	binary.Write(&b.line, binary.LittleEndian, uint16(4))
+	b.line.Write([]byte{0, 0, 0, 0})
+	headerStart := b.line.Len()
+	// minimum_instruction_length, maximum_operations_per_instruction,
+	// default_is_stmt, line_base, line_range, opcode_base
+	b.line.Write([]byte{1, 1, 1, 0xfb, 14, 13})
+	b.line.Write([]byte{0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1})
+	// No include directories, then the one file
+	b.line.WriteByte(0)
+	b.line.WriteString(t.file)
+	b.line.Write([]byte{0, 0, 0, 0, 0})
+	binary.LittleEndian.PutUint32(b.line.Bytes()[start+6:], uint32(b.line.Len()-headerStart))
+
+	setAddress := func(addr uint64) {
+		// DW_LNE_set_address
+		b.line.Write([]byte{0, 9, 2})
+		binary.Write(&b.line, binary.LittleEndian, addr)
+	}
+	line := 1
+	for _, r := range t.rows {
+		setAddress(r.Address)
+		// DW_LNS_advance_line, then DW_LNS_copy
+		b.line.WriteByte(3)
+		putSLEB(&b.line, int64(r.Line-line))
+		b.line.WriteByte(1)
+		line = r.Line
+	}
+	setAddress(t.end)
+	// DW_LNE_end_sequence
+	b.line.Write([]byte{0, 1, 1})
+	binary.LittleEndian.PutUint32(b.line.Bytes()[start:], uint32(b.line.Len()-start-4))
+	return int64(start)
+}
+
+// Writes a DIE and its children, along with any extra attributes
+func (b *builder) die(d *DIE, extra ...Attr) error {
+	b.code++
+	d.offset = uint32(b.info.Len())
+	putULEB(&b.abbrev, b.code)
+	putULEB(&b.abbrev, uint64(d.Tag))
+	if len(d.Children) > 0 {
+		b.abbrev.WriteByte(1)
+	} else {
+		b.abbrev.WriteByte(0)
+	}
+	putULEB(&b.info, b.code)
+	for _, a := range append(d.Attrs[:len(d.Attrs):len(d.Attrs)], extra...) {
+		putULEB(&b.abbrev, uint64(a.Attr))
+		switch v := a.Val.(type) {
+		case string:
+			putULEB(&b.abbrev, formString)
+			b.info.WriteString(v)
+			b.info.WriteByte(0)
+		case int:
+			putULEB(&b.abbrev, formSdata)
+			putSLEB(&b.info, int64(v))
+		case int64:
+			putULEB(&b.abbrev, formSdata)
+			putSLEB(&b.info, v)
+		case uint64:
+			putULEB(&b.abbrev, formAddr)
+			binary.Write(&b.info, binary.LittleEndian, v)
+		case bool:
+			putULEB(&b.abbrev, formFlag)
+			if v {
+				b.info.WriteByte(1)
+			} else {
+				b.info.WriteByte(0)
+			}
+		case []byte:
+			putULEB(&b.abbrev, formExprloc)
+			putULEB(&b.info, uint64(len(v)))
+			b.info.Write(v)
+		case *DIE:
+			putULEB(&b.abbrev, formRef4)
+			b.fixups = append(b.fixups, fixup{b.info.Len(), v})
+			b.info.Write([]byte{0, 0, 0, 0})
+		default:
+			return fmt.Errorf("dwarftest: unsupported value %#v for %v", a.Val, a.Attr)
+		}
+	}
+	b.abbrev.Write([]byte{0, 0})
+	if len(d.Children) == 0 {
+		return nil
+	}
+	for _, c := range d.Children {
+		if err := b.die(c); err != nil {
+			return err
+		}
+	}
+	b.info.WriteByte(0)
+	return nil
+}
+
+// Assembles a DWARF 4 compile unit for each of these DIEs and parses the
+// result
+func Build(units ...*DIE) (*dwarf.Data, error) {
+	s, err := Assemble(units...)
+	if err != nil {
+		return nil, err
+	}
+	return dwarf.New(s.Abbrev, nil, nil, s.Info, s.Line, nil, nil, nil)
+}
+
+// The contents of the DWARF sections assembled for some units
+type Sections struct {
+	Abbrev []byte
+	Info   []byte
+	// Empty if no unit was given a line table
+	Line []byte
+}
+
+// Assembles a DWARF 4 compile unit for each of these DIEs, for writing
+// into a binary
+func Assemble(units ...*DIE) (*Sections, error) {
+	b := &builder{}
+	unitStarts := make(map[*DIE]int)
+	for _, u := range units {
+		start := b.info.Len()
+		// unit_length is patched once the unit has been written
+		b.info.Write([]byte{0, 0, 0, 0})
+		binary.Write(&b.info, binary.LittleEndian, uint16(4))
+		binary.Write(&b.info, binary.LittleEndian, uint32(0))
+		b.info.WriteByte(8)
+		var extra []Attr
+		if u.lines != nil {
+			extra = append(extra, Attr{dwarf.AttrStmtList, b.lineProgram(u.lines)})
+		}
+		if err := b.die(u, extra...); err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint32(b.info.Bytes()[start:], uint32(b.info.Len()-start-4))
+		unitStarts[u] = start
+	}
+	b.abbrev.WriteByte(0)
+
+	info := b.info.Bytes()
+	for _, f := range b.fixups {
+		// References are relative to the start of the unit containing them
+		start := 0
+		for _, s := range unitStarts {
+			if s <= f.at && s > start {
+				start = s
+			}
+		}
+		binary.LittleEndian.PutUint32(info[f.at:], f.target.offset-uint32(start))
+	}
+	return &Sections{Abbrev: b.abbrev.Bytes(), Info: info, Line: b.line.Bytes()}, nil
+}
@@ -0,0 +1,62 @@
+// Package flags holds the command-line flags shared by the durins commands.
+package flags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jdginn/durins-door/client/file"
+)
+
+// A repeatable flag of the form id=value
+type Assignments map[string]string
+
+func (a Assignments) String() string {
+	pairs := make([]string, 0, len(a))
+	for k, v := range a {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (a Assignments) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected id=value, got %q", s)
+	}
+	a[parts[0]] = parts[1]
+	return nil
+}
+
+// A repeatable flag naming a directory
+type DirList []string
+
+func (d *DirList) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *DirList) Set(s string) error {
+	*d = append(*d, s)
+	return nil
+}
+
+// Opens a file client from a spec of the form path[@offset]
+func OpenFileClient(spec string) (*file.FileClient, error) {
+	path := spec
+	var offset int64
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		path = spec[:at]
+		var err error
+		offset, err = strconv.ParseInt(spec[at+1:], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad offset in %q: %v", spec, err)
+		}
+	}
+	c, err := file.NewFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	c.SetOffset(offset)
+	return c, nil
+}
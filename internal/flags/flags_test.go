@@ -0,0 +1,37 @@
+package flags
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	binaries := Assignments{}
+	var dirs DirList
+	fs.Var(binaries, "binary", "")
+	fs.Var(&dirs, "debug-dir", "")
+	assert.NoError(t, fs.Parse([]string{"-binary", "fw=a=b.elf", "-debug-dir", "x", "-debug-dir", "y"}))
+	assert.Equal(t, Assignments{"fw": "a=b.elf"}, binaries)
+	assert.Equal(t, DirList{"x", "y"}, dirs)
+	assert.Error(t, fs.Parse([]string{"-binary", "fw="}))
+}
+
+func TestOpenFileClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.bin")
+	assert.NoError(t, os.WriteFile(path, []byte{1, 2, 3, 4}, 0o644))
+	c, err := OpenFileClient(path + "@0x1000")
+	assert.NoError(t, err)
+	b, err := c.Read(0x1002, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{3, 4}, b)
+
+	_, err = OpenFileClient(path + "@zz")
+	assert.Error(t, err)
+}
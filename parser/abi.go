@@ -0,0 +1,581 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The kinds of change DiffABI reports
+type ABIChangeKind int
+
+const (
+	// A type, variable, member or enumerator only the new program has
+	ABIAdded ABIChangeKind = iota
+	// A type, variable, member or enumerator only the old program has
+	ABIRemoved
+	// A member or enumerator with a new name but the same place or value
+	ABIRenamed
+	// A member at a new offset
+	ABIOffsetChanged
+	// A type, variable or member of a new size
+	ABISizeChanged
+	// A variable, member or typedef of a new type
+	ABITypeChanged
+	// An enumerator with a new value
+	ABIValueChanged
+)
+
+var abiChangeKindNames = []string{"added", "removed", "renamed", "offset", "size", "type", "value"}
+
+func (k ABIChangeKind) String() string {
+	if int(k) < 0 || int(k) >= len(abiChangeKindNames) {
+		return "unknown"
+	}
+	return abiChangeKindNames[k]
+}
+
+// Encodes the kind by name, as in JSON
+func (k ABIChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Decodes a kind encoded by MarshalText
+func (k *ABIChangeKind) UnmarshalText(b []byte) error {
+	for i, name := range abiChangeKindNames {
+		if name == string(b) {
+			*k = ABIChangeKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown ABI change kind %q: %w", b, ErrBadValue)
+}
+
+// A difference between the ABIs of two programs
+type ABIChange struct {
+	Kind ABIChangeKind `json:"kind"`
+	// The type or variable that changed, as "struct Config", "enum Mode",
+	// "typedef port_t", "base long int" or "variable config"
+	Entity string `json:"entity"`
+	// The member or enumerator of the entity that changed, if any
+	Member string `json:"member,omitempty"`
+	// What changed, before and after: the name, offset, size, type or value
+	// as text. Offsets and sizes are in bytes, written bytes:bits for bit
+	// fields.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Set when the change breaks compatibility between the programs
+	Breaking bool `json:"breaking"`
+}
+
+func (c ABIChange) String() string {
+	str := c.Entity
+	if c.Member != "" {
+		str += "." + c.Member
+	}
+	str += ": " + c.Kind.String()
+	switch c.Kind {
+	case ABIAdded, ABIRemoved:
+	case ABIRenamed:
+		str += fmt.Sprintf(" from %s to %s", c.Old, c.New)
+	default:
+		str += fmt.Sprintf(" %s -> %s", c.Old, c.New)
+	}
+	return str
+}
+
+// The differences between the ABIs of two programs, with the verdict of
+// whether they are compatible
+type ABIReport struct {
+	Compatible bool        `json:"compatible"`
+	Changes    []ABIChange `json:"changes"`
+}
+
+// Lists the changes, marking those that break compatibility with "!", then
+// gives the verdict
+func (r ABIReport) String() string {
+	var sb strings.Builder
+	breaking := 0
+	for _, c := range r.Changes {
+		if c.Breaking {
+			breaking++
+			sb.WriteString("! ")
+		} else {
+			sb.WriteString("  ")
+		}
+		sb.WriteString(c.String() + "\n")
+	}
+	if r.Compatible {
+		fmt.Fprintf(&sb, "compatible: %d changes, none breaking\n", len(r.Changes))
+	} else {
+		fmt.Fprintf(&sb, "incompatible: %d changes, %d breaking\n", len(r.Changes), breaking)
+	}
+	return sb.String()
+}
+
+// Compares the named types and global variables of two versions of a
+// program, or only those with these names if any are given
+//
+// Types and variables are matched by name, and members and enumerators by
+// name or, failing that, by place or value, which is reported as a rename.
+// Changes that move or resize data, change its type or the value of an
+// enumerator, or take away something the old program had break
+// compatibility; additions and renames do not. A member added in what was
+// padding breaks nothing by itself, but one that moves other members or
+// grows the type is reported with those changes.
+//
+// Types are compared with typedefs resolved, so that a member whose type
+// is spelled differently but is the same underlying type has not changed.
+// Named types that members refer to are compared on their own.
+func DiffABI(before *Program, after *Program, names ...string) (*ABIReport, error) {
+	oldEntities, err := abiEntities(before)
+	if err != nil {
+		return nil, err
+	}
+	newEntities, err := abiEntities(after)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(oldEntities)+len(newEntities))
+	for key := range oldEntities {
+		keys = append(keys, key)
+	}
+	for key := range newEntities {
+		if _, ok := oldEntities[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	d := &abiDiff{
+		before: before,
+		after:  after,
+		or:     before.Reader(),
+		nr:     after.Reader(),
+	}
+	for _, key := range keys {
+		if !abiSelected(key, names) {
+			continue
+		}
+		oe, ne := oldEntities[key], newEntities[key]
+		switch {
+		case ne == nil:
+			d.add(ABIChange{Kind: ABIRemoved, Entity: key, Breaking: true})
+		case oe == nil:
+			d.add(ABIChange{Kind: ABIAdded, Entity: key})
+		default:
+			if err := d.compare(key, oe, ne); err != nil {
+				return nil, err
+			}
+		}
+	}
+	report := &ABIReport{Compatible: true, Changes: d.changes}
+	for _, c := range d.changes {
+		if c.Breaking {
+			report.Compatible = false
+		}
+	}
+	return report, nil
+}
+
+// Returns true if the entity with this key, such as "struct Config", is
+// among these names, which may or may not give its kind
+func abiSelected(key string, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	name := key[strings.Index(key, " ")+1:]
+	for _, n := range names {
+		if n == key || n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the kind of entity DiffABI compares an entry as, or the empty
+// string if it does not compare it
+func abiKind(tag dwarf.Tag) string {
+	switch tag {
+	case dwarf.TagStructType:
+		return "struct"
+	case dwarf.TagClassType:
+		return "class"
+	case dwarf.TagUnionType:
+		return "union"
+	case dwarf.TagEnumerationType:
+		return "enum"
+	case dwarf.TagTypedef:
+		return "typedef"
+	case dwarf.TagBaseType:
+		return "base"
+	case dwarf.TagVariable:
+		return "variable"
+	default:
+		return ""
+	}
+}
+
+// Returns true if an entry only declares a type or variable defined
+// elsewhere
+func abiDeclaration(e *dwarf.Entry) bool {
+	if e.Tag == dwarf.TagVariable {
+		return !HasAttr(e, dwarf.AttrLocation)
+	}
+	return HasAttr(e, dwarf.AttrDeclaration)
+}
+
+// Returns the named types and global variables of a program by a key giving
+// their kind and name, qualified by their namespaces, as "struct Config"
+//
+// Where several compile units define the same name, the first definition is
+// kept. Types that are only declared are left out; variables that are only
+// declared are kept if nothing defines them, since their types still matter.
+func abiEntities(p *Program) (map[string]*dwarf.Entry, error) {
+	ret := make(map[string]*dwarf.Entry)
+	r := p.Reader()
+	// The namespace of each unit or namespace entry we are inside
+	prefixes := make([]string, 0)
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return ret, nil
+		}
+		prefix := ""
+		if len(prefixes) > 0 {
+			prefix = prefixes[len(prefixes)-1]
+		}
+		switch e.Tag {
+		case 0:
+			if len(prefixes) > 0 {
+				prefixes = prefixes[:len(prefixes)-1]
+			}
+			continue
+		case dwarf.TagCompileUnit, dwarf.TagNamespace:
+			if e.Children {
+				if name := EntryName(e); e.Tag == dwarf.TagNamespace && name != "" {
+					prefix += name + "::"
+				}
+				prefixes = append(prefixes, prefix)
+			}
+			continue
+		}
+		if e.Children {
+			r.SkipChildren()
+		}
+		kind, name := abiKind(e.Tag), EntryName(e)
+		if kind == "" || name == "" || (kind != "variable" && abiDeclaration(e)) {
+			continue
+		}
+		key := kind + " " + prefix + name
+		if prev, ok := ret[key]; ok && (!abiDeclaration(prev) || abiDeclaration(e)) {
+			continue
+		}
+		ret[key] = e
+	}
+}
+
+// The state of a comparison between two programs
+type abiDiff struct {
+	before  *Program
+	after   *Program
+	or      *dwarf.Reader
+	nr      *dwarf.Reader
+	changes []ABIChange
+}
+
+func (d *abiDiff) add(c ABIChange) {
+	d.changes = append(d.changes, c)
+}
+
+// Compares an entity both programs have
+func (d *abiDiff) compare(key string, oe *dwarf.Entry, ne *dwarf.Entry) error {
+	switch oe.Tag {
+	case dwarf.TagStructType, dwarf.TagClassType, dwarf.TagUnionType:
+		return d.compareStruct(key, oe, ne)
+	case dwarf.TagEnumerationType:
+		return d.compareEnum(key, oe, ne)
+	case dwarf.TagTypedef:
+		return d.compareTypedef(key, oe, ne)
+	default:
+		return d.compareValue(key, oe, ne)
+	}
+}
+
+// Returns the size in bits of an entry, or of its type, taking in every
+// element of an array
+func (d *abiDiff) size(prog *Program, e *dwarf.Entry) (int, error) {
+	if !HasAttr(e, dwarf.AttrType) {
+		if !HasAttr(e, dwarf.AttrByteSize) && !HasAttr(e, dwarf.AttrBitSize) {
+			return 0, nil
+		}
+		return GetBitSize(e)
+	}
+	p, err := NewTypeDefProxy(prog.Types(), e)
+	if err != nil {
+		return 0, err
+	}
+	return p.BitSize() * numElements(p.dims()), nil
+}
+
+// Reports a change of size, returning true if there was one
+func (d *abiDiff) compareSize(key string, member string, oe *dwarf.Entry, ne *dwarf.Entry) (bool, error) {
+	oldSize, err := d.size(d.before, oe)
+	if err != nil {
+		return false, err
+	}
+	newSize, err := d.size(d.after, ne)
+	if err != nil {
+		return false, err
+	}
+	if oldSize == newSize {
+		return false, nil
+	}
+	d.add(ABIChange{Kind: ABISizeChanged, Entity: key, Member: member, Old: formatBits(oldSize), New: formatBits(newSize), Breaking: true})
+	return true, nil
+}
+
+// Compares the size and type of a variable or the size of a base type
+func (d *abiDiff) compareValue(key string, oe *dwarf.Entry, ne *dwarf.Entry) error {
+	if _, err := d.compareSize(key, "", oe, ne); err != nil {
+		return err
+	}
+	if oe.Tag != dwarf.TagVariable {
+		return nil
+	}
+	d.compareType(key, "", oe, ne)
+	return nil
+}
+
+// Reports a change of the type of an entry with a type, with typedefs
+// resolved
+func (d *abiDiff) compareType(key string, member string, oe *dwarf.Entry, ne *dwarf.Entry) {
+	oldType, newType := canonicalTypeName(d.or, oe), canonicalTypeName(d.nr, ne)
+	if oldType == newType {
+		return
+	}
+	// Types are given as written unless that hides the change, as when a
+	// typedef has changed
+	if o, n := TypeName(d.or, oe), TypeName(d.nr, ne); o != n {
+		oldType, newType = o, n
+	}
+	d.add(ABIChange{Kind: ABITypeChanged, Entity: key, Member: member, Old: oldType, New: newType, Breaking: true})
+}
+
+// Compares the type a typedef names, and the layout of that type when it
+// has no name of its own to be compared by
+func (d *abiDiff) compareTypedef(key string, oe *dwarf.Entry, ne *dwarf.Entry) error {
+	ot, err := ResolveTypeEntry(d.or, oe)
+	if err != nil {
+		return err
+	}
+	nt, err := ResolveTypeEntry(d.nr, ne)
+	if err != nil {
+		return err
+	}
+	if EntryName(ot) == "" && EntryName(nt) == "" && ot.Tag == nt.Tag {
+		switch ot.Tag {
+		case dwarf.TagStructType, dwarf.TagClassType, dwarf.TagUnionType:
+			return d.compareStruct(key, ot, nt)
+		case dwarf.TagEnumerationType:
+			return d.compareEnum(key, ot, nt)
+		}
+	}
+	resized, err := d.compareSize(key, "", oe, ne)
+	if err != nil {
+		return err
+	}
+	if !resized {
+		d.compareType(key, "", oe, ne)
+		return nil
+	}
+	// A resized typedef has surely changed type too, which is worth saying
+	if TypeName(d.or, oe) != TypeName(d.nr, ne) {
+		d.add(ABIChange{Kind: ABITypeChanged, Entity: key, Old: TypeName(d.or, oe), New: TypeName(d.nr, ne), Breaking: true})
+	}
+	return nil
+}
+
+// Compares the size of a struct, class or union and the place, size and
+// type of each member
+func (d *abiDiff) compareStruct(key string, ot *dwarf.Entry, nt *dwarf.Entry) error {
+	if _, err := d.compareSize(key, "", ot, nt); err != nil {
+		return err
+	}
+	oldRows, oldEntries, err := d.before.Types().memberRows(d.or, ot)
+	if err != nil {
+		return err
+	}
+	newRows, newEntries, err := d.after.Types().memberRows(d.nr, nt)
+	if err != nil {
+		return err
+	}
+	oldNames, newNames := memberNames(oldRows), memberNames(newRows)
+	newIndex := make(map[string]int)
+	for i, name := range newNames {
+		newIndex[name] = i
+	}
+	matched := make([]bool, len(newRows))
+	removed := make([]int, 0)
+	for i, name := range oldNames {
+		j, ok := newIndex[name]
+		if !ok {
+			removed = append(removed, i)
+			continue
+		}
+		matched[j] = true
+		o, n := oldRows[i], newRows[j]
+		if o.BitOffset != n.BitOffset {
+			d.add(ABIChange{Kind: ABIOffsetChanged, Entity: key, Member: name, Old: formatBits(o.BitOffset), New: formatBits(n.BitOffset), Breaking: true})
+		}
+		if o.BitSize != n.BitSize {
+			d.add(ABIChange{Kind: ABISizeChanged, Entity: key, Member: name, Old: formatBits(o.BitSize), New: formatBits(n.BitSize), Breaking: true})
+		}
+		d.compareType(key, name, oldEntries[i], newEntries[j])
+	}
+
+	// A member that went away is taken to be renamed if a new member of the
+	// same type and size is at its offset or, failing that, in its place in
+	// the declaration
+	for _, i := range removed {
+		o := oldRows[i]
+		renamed := false
+		for _, sameOffset := range []bool{true, false} {
+			for j, n := range newRows {
+				if renamed || matched[j] || o.BitSize != n.BitSize {
+					continue
+				}
+				if (sameOffset && o.BitOffset != n.BitOffset) || (!sameOffset && i != j) {
+					continue
+				}
+				if canonicalTypeName(d.or, oldEntries[i]) != canonicalTypeName(d.nr, newEntries[j]) {
+					continue
+				}
+				matched[j] = true
+				renamed = true
+				d.add(ABIChange{Kind: ABIRenamed, Entity: key, Member: oldNames[i], Old: oldNames[i], New: newNames[j]})
+				if o.BitOffset != n.BitOffset {
+					d.add(ABIChange{Kind: ABIOffsetChanged, Entity: key, Member: newNames[j], Old: formatBits(o.BitOffset), New: formatBits(n.BitOffset), Breaking: true})
+				}
+			}
+		}
+		if !renamed {
+			d.add(ABIChange{Kind: ABIRemoved, Entity: key, Member: oldNames[i], Breaking: true})
+		}
+	}
+	for j, name := range newNames {
+		if !matched[j] {
+			d.add(ABIChange{Kind: ABIAdded, Entity: key, Member: name})
+		}
+	}
+	return nil
+}
+
+// Names the members of a layout, calling those without names, such as
+// anonymous unions and base classes, by their type or their place
+func memberNames(rows []LayoutRow) []string {
+	names := make([]string, len(rows))
+	anonymous := 0
+	for i, row := range rows {
+		switch {
+		case row.Name != "":
+			names[i] = row.Name
+		case row.Type != "" && row.Type != "<anonymous>":
+			names[i] = "<" + row.Type + ">"
+		default:
+			names[i] = fmt.Sprintf("<anonymous %d>", anonymous)
+			anonymous++
+		}
+	}
+	return names
+}
+
+// An enumerator and its value
+type enumerator struct {
+	name  string
+	value string
+}
+
+// Returns the enumerators of an enumeration type in declaration order
+func enumerators(r *dwarf.Reader, t *dwarf.Entry) ([]enumerator, error) {
+	ret := make([]enumerator, 0)
+	if !t.Children {
+		return ret, nil
+	}
+	r.Seek(t.Offset)
+	if _, err := r.Next(); err != nil {
+		return nil, err
+	}
+	for {
+		child, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil || child.Tag == 0 {
+			return ret, nil
+		}
+		r.SkipChildren()
+		if child.Tag == dwarf.TagEnumerator {
+			ret = append(ret, enumerator{EntryName(child), fmt.Sprint(child.Val(dwarf.AttrConstValue))})
+		}
+	}
+}
+
+// Compares the size of an enumeration type and the value of each
+// enumerator
+func (d *abiDiff) compareEnum(key string, ot *dwarf.Entry, nt *dwarf.Entry) error {
+	if _, err := d.compareSize(key, "", ot, nt); err != nil {
+		return err
+	}
+	oldValues, err := enumerators(d.or, ot)
+	if err != nil {
+		return err
+	}
+	newValues, err := enumerators(d.nr, nt)
+	if err != nil {
+		return err
+	}
+	newIndex := make(map[string]int)
+	for i, e := range newValues {
+		newIndex[e.name] = i
+	}
+	matched := make([]bool, len(newValues))
+	removed := make([]enumerator, 0)
+	for _, o := range oldValues {
+		j, ok := newIndex[o.name]
+		if !ok {
+			removed = append(removed, o)
+			continue
+		}
+		matched[j] = true
+		if n := newValues[j]; o.value != n.value {
+			d.add(ABIChange{Kind: ABIValueChanged, Entity: key, Member: o.name, Old: o.value, New: n.value, Breaking: true})
+		}
+	}
+	// An enumerator that went away is taken to be renamed if a new one has
+	// its value
+	for _, o := range removed {
+		renamed := false
+		for j, n := range newValues {
+			if !matched[j] && n.value == o.value {
+				matched[j] = true
+				renamed = true
+				d.add(ABIChange{Kind: ABIRenamed, Entity: key, Member: o.name, Old: o.name, New: n.name})
+				break
+			}
+		}
+		if !renamed {
+			d.add(ABIChange{Kind: ABIRemoved, Entity: key, Member: o.name, Breaking: true})
+		}
+	}
+	for j, n := range newValues {
+		if !matched[j] {
+			d.add(ABIChange{Kind: ABIAdded, Entity: key, Member: n.name})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Loads one of the objects built from testdata/abi by its build.sh
+func loadABI(t *testing.T, name string) *Program {
+	f, err := OpenELF("testdata/abi/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	prog, err := NewProgramFromFile(f, SearchPaths{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func TestDiffABI(t *testing.T) {
+	v1, v2 := loadABI(t, "v1.o"), loadABI(t, "v2.o")
+	r, err := DiffABI(v1, v2)
+	assert.NoError(t, err)
+	assert.False(t, r.Compatible)
+	has := func(c ABIChange) {
+		t.Helper()
+		assert.Contains(t, r.Changes, c)
+	}
+
+	has(ABIChange{Kind: ABISizeChanged, Entity: "struct Config", Old: "20", New: "24", Breaking: true})
+	has(ABIChange{Kind: ABISizeChanged, Entity: "struct Config", Member: "port", Old: "2", New: "4", Breaking: true})
+	has(ABIChange{Kind: ABITypeChanged, Entity: "struct Config", Member: "port", Old: "short unsigned int", New: "unsigned int", Breaking: true})
+	has(ABIChange{Kind: ABIOffsetChanged, Entity: "struct Config", Member: "crc", Old: "16", New: "20", Breaking: true})
+	has(ABIChange{Kind: ABIRenamed, Entity: "struct Config", Member: "spare", Old: "spare", New: "reserved"})
+	has(ABIChange{Kind: ABIOffsetChanged, Entity: "struct Config", Member: "reserved", Old: "6", New: "8", Breaking: true})
+	has(ABIChange{Kind: ABITypeChanged, Entity: "typedef port_t", Old: "uint16_t", New: "uint32_t", Breaking: true})
+
+	has(ABIChange{Kind: ABIRenamed, Entity: "enum Mode", Member: "MODE_RUN", Old: "MODE_RUN", New: "MODE_ACTIVE"})
+	has(ABIChange{Kind: ABIValueChanged, Entity: "enum Mode", Member: "MODE_FAULT", Old: "2", New: "3", Breaking: true})
+	has(ABIChange{Kind: ABIAdded, Entity: "enum Mode", Member: "MODE_SLEEP"})
+
+	// Point is an anonymous struct, compared through its typedef
+	has(ABIChange{Kind: ABIAdded, Entity: "typedef Point", Member: "z"})
+	has(ABIChange{Kind: ABISizeChanged, Entity: "typedef Point", Old: "8", New: "12", Breaking: true})
+
+	has(ABIChange{Kind: ABIRemoved, Entity: "variable retries", Breaking: true})
+	has(ABIChange{Kind: ABIAdded, Entity: "variable timeout"})
+	has(ABIChange{Kind: ABITypeChanged, Entity: "variable uptime", Old: "long int", New: "long long int", Breaking: true})
+
+	// Header is unchanged, and Config.name only moved
+	for _, c := range r.Changes {
+		assert.NotEqual(t, "struct Header", c.Entity)
+		if c.Member == "name" {
+			assert.Equal(t, ABIOffsetChanged, c.Kind)
+		}
+	}
+	s := r.String()
+	assert.Contains(t, s, "! struct Config.crc: offset 16 -> 20\n")
+	assert.Contains(t, s, "  struct Config.spare: renamed from spare to reserved\n")
+	assert.True(t, strings.HasSuffix(s, "breaking\n"))
+	assert.Contains(t, s, "incompatible: ")
+}
+
+func TestDiffABISelected(t *testing.T) {
+	v1, v2 := loadABI(t, "v1.o"), loadABI(t, "v2.o")
+	r, err := DiffABI(v1, v2, "Header", "variable timeout")
+	assert.NoError(t, err)
+	assert.True(t, r.Compatible)
+	assert.Equal(t, []ABIChange{{Kind: ABIAdded, Entity: "variable timeout"}}, r.Changes)
+	assert.Equal(t, "  variable timeout: added\ncompatible: 1 changes, none breaking\n", r.String())
+
+	r, err = DiffABI(v1, v1)
+	assert.NoError(t, err)
+	assert.True(t, r.Compatible)
+	assert.Empty(t, r.Changes)
+}
+
+func TestABIReportJSON(t *testing.T) {
+	v1, v2 := loadABI(t, "v1.o"), loadABI(t, "v2.o")
+	r, err := DiffABI(v1, v2, "Mode")
+	assert.NoError(t, err)
+	b, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"kind":"value","entity":"enum Mode","member":"MODE_FAULT","old":"2","new":"3","breaking":true}`)
+	var decoded ABIReport
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, *r, decoded)
+}
@@ -0,0 +1,358 @@
+package parser
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Index attributes and forms of .debug_names
+const (
+	idxCompileUnit = 1
+	idxTypeUnit    = 2
+
+	formData1       = 0x0b
+	formData2       = 0x05
+	formData4       = 0x06
+	formData8       = 0x07
+	formRef1        = 0x11
+	formRef2        = 0x12
+	formRef4        = 0x13
+	formRef8        = 0x14
+	formRefUdata    = 0x15
+	formFlagPresent = 0x19
+)
+
+// An accelTable is the name index a compiler or linker stored alongside the
+// DWARF, reduced to the compile units holding entries with each name
+//
+// Units are identified by the offsets of their headers while the table is
+// read, then by the offsets of their DIEs as everywhere else.
+type accelTable struct {
+	// Offsets of the compile units holding entries with each name
+	names map[string][]dwarf.Offset
+	// Offsets of every compile unit, listed in the table or not
+	cus []dwarf.Offset
+}
+
+func newAccelTable() *accelTable {
+	return &accelTable{names: make(map[string][]dwarf.Offset)}
+}
+
+func (a *accelTable) add(name string, cu dwarf.Offset) {
+	for _, have := range a.names[name] {
+		if have == cu {
+			return
+		}
+	}
+	a.names[name] = append(a.names[name], cu)
+}
+
+// Returns the compile units listed for this name, or false if the table
+// does not list it
+func (a *accelTable) lookup(name string) ([]dwarf.Offset, bool) {
+	cus, ok := a.names[name]
+	return cus, ok
+}
+
+// Reads the accelerator table of an ELF file, preferring .debug_names over
+// .gdb_index, or returns nil if it has neither
+func readAccelTable(f *ELFFile) (*accelTable, error) {
+	var a *accelTable
+	names, err := f.SectionData(".debug_names")
+	if err != nil {
+		return nil, err
+	}
+	if names != nil {
+		str, err := f.SectionData(".debug_str")
+		if err != nil {
+			return nil, err
+		}
+		if a, err = parseDebugNames(names, str, f.ByteOrder); err != nil {
+			return nil, err
+		}
+	} else {
+		gdb, err := f.SectionData(".gdb_index")
+		if err != nil || gdb == nil {
+			return nil, err
+		}
+		if a, err = parseGdbIndex(gdb); err != nil {
+			return nil, err
+		}
+	}
+	info, err := f.SectionData(".debug_info")
+	if err != nil {
+		return nil, err
+	}
+	headers, err := unitHeaders(info, f.ByteOrder)
+	if err != nil {
+		return nil, err
+	}
+	return a.resolveUnits(headers)
+}
+
+// Replaces the header offsets of the units in the table with the offsets
+// of their DIEs, and lists every compile unit
+func (a *accelTable) resolveUnits(headers []unitHeader) (*accelTable, error) {
+	dies := make(map[dwarf.Offset]dwarf.Offset, len(headers))
+	for _, h := range headers {
+		if h.unitType == utType || h.unitType == utSplitType {
+			continue
+		}
+		dies[dwarf.Offset(h.offset)] = h.die
+		a.cus = append(a.cus, h.die)
+	}
+	for name, cus := range a.names {
+		for i, cu := range cus {
+			die, ok := dies[cu]
+			if !ok {
+				return nil, fmt.Errorf("Accelerator table lists %s in no unit at %#x: %w", name, cu, ErrUnsupportedForm)
+			}
+			cus[i] = die
+		}
+	}
+	return a, nil
+}
+
+// Parses a .gdb_index section, version 7 or later
+//
+// The table lists the compile units defining each name rather than the
+// entries themselves. Names are qualified, as in "f1::champion", so each is
+// also listed under its last component.
+func parseGdbIndex(b []byte) (*accelTable, error) {
+	order := binary.LittleEndian
+	if len(b) < 24 {
+		return nil, fmt.Errorf("Truncated .gdb_index header: %w", ErrUnsupportedForm)
+	}
+	if v := order.Uint32(b); v < 7 {
+		return nil, fmt.Errorf(".gdb_index version %d: %w", v, ErrUnsupportedForm)
+	}
+	cuList, symbols, pool := order.Uint32(b[4:]), order.Uint32(b[16:]), order.Uint32(b[20:])
+	typeList := order.Uint32(b[8:])
+	if cuList > typeList || symbols > pool || int(pool) > len(b) {
+		return nil, fmt.Errorf("Malformed .gdb_index header: %w", ErrUnsupportedForm)
+	}
+	cus := make([]dwarf.Offset, 0, (typeList-cuList)/16)
+	for at := cuList; at+16 <= typeList; at += 16 {
+		cus = append(cus, dwarf.Offset(order.Uint64(b[at:])))
+	}
+
+	a := newAccelTable()
+	for at := symbols; at+8 <= pool; at += 8 {
+		nameAt, vecAt := order.Uint32(b[at:]), order.Uint32(b[at+4:])
+		if nameAt == 0 && vecAt == 0 {
+			continue
+		}
+		nameAt += pool
+		vecAt += pool
+		end := bytes.IndexByte(b[min32(nameAt, uint32(len(b))):], 0)
+		if end < 0 || int(vecAt)+4 > len(b) {
+			return nil, fmt.Errorf("Malformed .gdb_index symbol: %w", ErrUnsupportedForm)
+		}
+		name := string(b[nameAt : int(nameAt)+end])
+		n := order.Uint32(b[vecAt:])
+		if int(vecAt)+4+4*int(n) > len(b) {
+			return nil, fmt.Errorf("Malformed .gdb_index symbol %s: %w", name, ErrUnsupportedForm)
+		}
+		for i := uint32(0); i < n; i++ {
+			// The low 24 bits index the compile units, then the type units
+			cu := order.Uint32(b[vecAt+4+4*i:]) & 0xffffff
+			if int(cu) >= len(cus) {
+				continue
+			}
+			a.add(name, cus[cu])
+			if short, ok := lastComponent(name); ok {
+				a.add(short, cus[cu])
+			}
+		}
+	}
+	return a, nil
+}
+
+func min32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Returns the part of a qualified name after its last "::", unless the
+// name is a template whose arguments may hold "::" themselves
+func lastComponent(name string) (string, bool) {
+	at := strings.LastIndex(name, "::")
+	if at < 0 || strings.ContainsAny(name, "<(") {
+		return "", false
+	}
+	return name[at+2:], true
+}
+
+// Parses a .debug_names section, which may hold several name indexes one
+// after the other
+//
+// Entries of type units are skipped; their types are found by the walk.
+func parseDebugNames(b, str []byte, order binary.ByteOrder) (*accelTable, error) {
+	a := newAccelTable()
+	for len(b) > 0 {
+		n, err := parseNameIndex(a, b, str, order)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+	}
+	return a, nil
+}
+
+// An abbreviation of a .debug_names entry: the form of each index attribute
+type nameAbbrev struct {
+	attrs []uint64
+	forms []uint64
+}
+
+// Adds the names of the name index at the start of b to the table and
+// returns the length of the index
+func parseNameIndex(a *accelTable, b, str []byte, order binary.ByteOrder) (int, error) {
+	c := &cursor{b: b, order: order}
+	offSize := 4
+	length := uint64(c.addr(4))
+	if length == 0xffffffff {
+		offSize = 8
+		length = c.addr(8)
+	}
+	if c.err != nil || length > uint64(len(b)-c.off) {
+		return 0, fmt.Errorf("Truncated .debug_names header: %w", ErrUnsupportedForm)
+	}
+	end := c.off + int(length)
+	c.b = b[:end]
+	// The version, then two bytes of padding
+	if v := c.bytes(4); v == nil || order.Uint16(v) != 5 {
+		return 0, fmt.Errorf("Unsupported .debug_names version: %w", ErrUnsupportedForm)
+	}
+	cuCount := int(c.addr(4))
+	localTUs := int(c.addr(4))
+	foreignTUs := int(c.addr(4))
+	buckets := int(c.addr(4))
+	nameCount := int(c.addr(4))
+	abbrevSize := int(c.addr(4))
+	augmentation := int(c.addr(4))
+	c.bytes((augmentation + 3) &^ 3)
+
+	cus := make([]dwarf.Offset, cuCount)
+	for i := range cus {
+		cus[i] = dwarf.Offset(c.addr(offSize))
+	}
+	c.bytes(localTUs*offSize + foreignTUs*8)
+	c.bytes(4 * buckets)
+	if buckets > 0 {
+		c.bytes(4 * nameCount)
+	}
+	strOffs := make([]uint64, nameCount)
+	for i := range strOffs {
+		strOffs[i] = c.addr(offSize)
+	}
+	entryOffs := make([]uint64, nameCount)
+	for i := range entryOffs {
+		entryOffs[i] = c.addr(offSize)
+	}
+	abbrevs, err := nameAbbrevs(c.bytes(abbrevSize), order)
+	if err != nil {
+		return 0, err
+	}
+	pool := c.off
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	for i := 0; i < nameCount; i++ {
+		if strOffs[i] >= uint64(len(str)) {
+			return 0, fmt.Errorf("Name offset %#x is past the end of .debug_str: %w", strOffs[i], ErrUnsupportedForm)
+		}
+		nul := bytes.IndexByte(str[strOffs[i]:], 0)
+		if nul < 0 {
+			return 0, fmt.Errorf("Unterminated name at %#x in .debug_str: %w", strOffs[i], ErrUnsupportedForm)
+		}
+		name := string(str[strOffs[i] : strOffs[i]+uint64(nul)])
+		e := &cursor{b: c.b, off: pool + int(entryOffs[i]), order: order}
+		for {
+			code := e.uleb()
+			if e.err != nil {
+				return 0, e.err
+			}
+			if code == 0 {
+				break
+			}
+			abbrev, ok := abbrevs[code]
+			if !ok {
+				return 0, fmt.Errorf("Unknown .debug_names abbreviation %d: %w", code, ErrUnsupportedForm)
+			}
+			cu, inTU := 0, false
+			for j, attr := range abbrev.attrs {
+				v, err := nameValue(e, abbrev.forms[j], offSize)
+				if err != nil {
+					return 0, err
+				}
+				switch attr {
+				case idxCompileUnit:
+					cu = int(v)
+				case idxTypeUnit:
+					inTU = true
+				}
+			}
+			if !inTU && cu < len(cus) {
+				a.add(name, cus[cu])
+			}
+		}
+	}
+	return end, nil
+}
+
+// Parses the abbreviation table of a name index
+func nameAbbrevs(b []byte, order binary.ByteOrder) (map[uint64]nameAbbrev, error) {
+	c := &cursor{b: b, order: order}
+	abbrevs := make(map[uint64]nameAbbrev)
+	for {
+		code := c.uleb()
+		if code == 0 || c.err != nil {
+			return abbrevs, c.err
+		}
+		c.uleb()
+		var abbrev nameAbbrev
+		for {
+			attr, form := c.uleb(), c.uleb()
+			if attr == 0 && form == 0 || c.err != nil {
+				break
+			}
+			abbrev.attrs = append(abbrev.attrs, attr)
+			abbrev.forms = append(abbrev.forms, form)
+		}
+		abbrevs[code] = abbrev
+	}
+}
+
+// Reads the value of an index attribute in this form
+func nameValue(c *cursor, form uint64, offSize int) (uint64, error) {
+	var v uint64
+	switch form {
+	case formData1, formRef1:
+		v = uint64(c.u8())
+	case formData2, formRef2:
+		b := c.bytes(2)
+		if b != nil {
+			v = uint64(c.order.Uint16(b))
+		}
+	case formData4, formRef4:
+		v = c.addr(4)
+	case formData8, formRef8:
+		v = c.addr(8)
+	case formUdata, formRefUdata:
+		v = c.uleb()
+	case formSecOffset:
+		v = c.addr(offSize)
+	case formFlagPresent:
+		v = 1
+	default:
+		return 0, fmt.Errorf(".debug_names form %#x: %w", form, ErrUnsupportedForm)
+	}
+	return v, c.err
+}
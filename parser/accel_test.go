@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Built from testdata/accel by its build.sh
+func loadAccel(t *testing.T) *Program {
+	f, err := OpenELF("testdata/accel/cars.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	prog, err := NewProgramFromFile(f, SearchPaths{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func TestGdbIndex(t *testing.T) {
+	prog := loadAccel(t)
+	idx := prog.Index()
+	if !assert.NotNil(t, idx.accel) {
+		return
+	}
+	cus := idx.CUs()
+	if !assert.Len(t, cus, 2) {
+		return
+	}
+	assert.Equal(t, "cars.c", cus[0].Name)
+	assert.Equal(t, "track.c", cus[1].Name)
+	assert.Empty(t, idx.walked)
+
+	// Only track.c is read to find the global speed
+	e, cu, err := idx.GetEntry("speed")
+	assert.NoError(t, err)
+	assert.Equal(t, dwarf.TagVariable, e.Tag)
+	assert.Equal(t, "track.c", cu.Val(dwarf.AttrName))
+	assert.Equal(t, map[dwarf.Offset]bool{cus[1].Offset: true}, idx.walked)
+	assert.False(t, idx.full)
+
+	// cars.c holds both the member and the global laps
+	ies := idx.Lookup("laps")
+	if assert.Len(t, ies, 2) {
+		assert.Equal(t, dwarf.TagMember, ies[0].Tag)
+		assert.Equal(t, dwarf.TagVariable, ies[1].Tag)
+	}
+	assert.Len(t, idx.walked, 2)
+
+	// Static variables are listed too
+	_, _, err = idx.GetEntry("hidden")
+	assert.NoError(t, err)
+	assert.False(t, idx.full)
+
+	// Names the table does not list are found by walking everything
+	assert.Len(t, idx.Lookup("cars.c"), 1)
+	assert.True(t, idx.full)
+	assert.Len(t, idx.Lookup("speed"), 2)
+	_, _, err = idx.GetEntry("nosuchname")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDebugNames(t *testing.T) {
+	order := binary.LittleEndian
+	var str bytes.Buffer
+	str.WriteString("\x00speed\x00laps\x00")
+
+	// Two units; speed is in the second, laps in both
+	var body bytes.Buffer
+	put := func(v interface{}) { binary.Write(&body, order, v) }
+	put(uint16(5))
+	put(uint16(0))
+	put(uint32(2)) // comp_unit_count
+	put(uint32(0)) // local_type_unit_count
+	put(uint32(0)) // foreign_type_unit_count
+	put(uint32(0)) // bucket_count
+	put(uint32(2)) // name_count
+	abbrevs := []byte{
+		// Code 1: DW_TAG_variable, DW_IDX_compile_unit as data1 and
+		// DW_IDX_die_offset as ref4
+		1, 0x34, idxCompileUnit, formData1, 3, formRef4, 0, 0,
+		// Code 2: a type unit entry, which is skipped
+		2, 0x13, idxTypeUnit, formData1, 0, 0,
+		0,
+	}
+	put(uint32(len(abbrevs)))
+	put(uint32(0)) // augmentation_string_size
+	put(uint32(0x0))
+	put(uint32(0x40))
+	put(uint32(1)) // string offsets
+	put(uint32(7))
+	put(uint32(0)) // entry offsets
+	put(uint32(12))
+	body.Write(abbrevs)
+	// speed: in unit 1
+	body.Write([]byte{1, 1, 0x20, 0, 0, 0, 0})
+	// padding to keep the entries of laps at 12
+	body.Write([]byte{0, 0, 0, 0, 0})
+	// laps: in units 0 and 1, and a type unit
+	body.Write([]byte{1, 0, 0x10, 0, 0, 0, 1, 1, 0x18, 0, 0, 0, 2, 0, 0})
+
+	var section bytes.Buffer
+	binary.Write(&section, order, uint32(body.Len()))
+	section.Write(body.Bytes())
+	// A second index may follow the first
+	section.Write(section.Bytes())
+
+	a, err := parseDebugNames(section.Bytes(), str.Bytes(), order)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]dwarf.Offset{
+		"speed": {0x40},
+		"laps":  {0x0, 0x40},
+	}, a.names)
+
+	a, err = a.resolveUnits([]unitHeader{{offset: 0, die: 0xb}, {offset: 0x40, die: 0x4b}})
+	assert.NoError(t, err)
+	assert.Equal(t, []dwarf.Offset{0x4b}, a.names["speed"])
+	assert.Equal(t, []dwarf.Offset{0xb, 0x4b}, a.cus)
+
+	_, err = parseDebugNames(section.Bytes()[:20], str.Bytes(), order)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+}
@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ELFCOMPRESS_ZSTD, which debug/elf only knows from Go 1.21
+const compressZstd elf.CompressionType = 2
+
+// An ELFFile is an ELF file whose debug sections are decompressed here
+// rather than by debug/elf, so that sections compressed with zstd can be
+// read whatever the Go version, along with those compressed with zlib and
+// the legacy .zdebug sections
+type ELFFile struct {
+	*elf.File
+	// The file's contents, or nil if only the *elf.File is known, in which
+	// case compressed sections are left to debug/elf
+	r      io.ReaderAt
+	closer io.Closer
+}
+
+// Opens the ELF file at this path
+func OpenELF(path string) (*ELFFile, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewELFFile(fh)
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	f.closer = fh
+	return f, nil
+}
+
+// Reads an ELF file from r, which must stay readable while the file is used
+func NewELFFile(r io.ReaderAt) (*ELFFile, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ELFFile{File: f, r: r}, nil
+}
+
+// Closes the file if it was opened by OpenELF
+func (f *ELFFile) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// Returns the decompressed contents of the section with this name, reading
+// .zdebug_x when asked for .debug_x and the file has only the former, or
+// nil if the file has neither
+func (f *ELFFile) SectionData(name string) ([]byte, error) {
+	s := f.Section(name)
+	if s == nil && strings.HasPrefix(name, ".debug_") {
+		s = f.Section(".zdebug_" + strings.TrimPrefix(name, ".debug_"))
+	}
+	if s == nil || s.Type == elf.SHT_NOBITS {
+		return nil, nil
+	}
+	b, err := f.sectionData(s)
+	if err != nil {
+		return nil, fmt.Errorf("Reading section %s: %w", s.Name, err)
+	}
+	return b, nil
+}
+
+func (f *ELFFile) sectionData(s *elf.Section) ([]byte, error) {
+	if f.r == nil {
+		// Left to debug/elf, which decompresses what this Go version knows,
+		// including .zdebug sections in newer versions
+		b, err := io.ReadAll(s.Open())
+		if err == nil && strings.HasPrefix(s.Name, ".zdebug_") && bytes.HasPrefix(b, []byte("ZLIB")) {
+			return decompressZdebug(b)
+		}
+		return b, err
+	}
+	raw := make([]byte, s.FileSize)
+	if _, err := f.r.ReadAt(raw, int64(s.Offset)); err != nil {
+		return nil, err
+	}
+	switch {
+	case s.Flags&elf.SHF_COMPRESSED != 0:
+		return decompressSection(raw, f.Class, f.ByteOrder)
+	case strings.HasPrefix(s.Name, ".zdebug_"):
+		return decompressZdebug(raw)
+	default:
+		return raw, nil
+	}
+}
+
+// Returns the DWARF data of this file, decompressing its sections as needed
+//
+// Relocatable objects are left to debug/elf, which applies their
+// relocations.
+func (f *ELFFile) DWARF() (*dwarf.Data, error) {
+	if f.r == nil || f.Type == elf.ET_REL {
+		return f.File.DWARF()
+	}
+	sections := make(map[string][]byte)
+	for _, name := range []string{"abbrev", "info", "line", "ranges", "str"} {
+		b, err := f.SectionData(".debug_" + name)
+		if err != nil {
+			return nil, err
+		}
+		sections[name] = b
+	}
+	d, err := dwarf.New(sections["abbrev"], nil, nil, sections["info"], sections["line"], nil, sections["ranges"], sections["str"])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{".debug_addr", ".debug_line_str", ".debug_loclists", ".debug_rnglists", ".debug_str_offsets"} {
+		b, err := f.SectionData(name)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		if err := d.AddSection(name, b); err != nil {
+			return nil, err
+		}
+	}
+	// There may be one .debug_types section per COMDAT group
+	for i, s := range f.Sections {
+		if s.Name != ".debug_types" && s.Name != ".zdebug_types" {
+			continue
+		}
+		b, err := f.sectionData(s)
+		if err != nil {
+			return nil, fmt.Errorf("Reading section %s: %w", s.Name, err)
+		}
+		if err := d.AddTypes(fmt.Sprintf("types-%d", i), b); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Decompresses a section flagged SHF_COMPRESSED, which starts with an
+// Elf32_Chdr or Elf64_Chdr giving the algorithm and the uncompressed size
+func decompressSection(raw []byte, class elf.Class, order binary.ByteOrder) ([]byte, error) {
+	var typ elf.CompressionType
+	var size uint64
+	switch class {
+	case elf.ELFCLASS32:
+		if len(raw) < 12 {
+			return nil, fmt.Errorf("Truncated compression header: %w", ErrBadCompression)
+		}
+		typ = elf.CompressionType(order.Uint32(raw))
+		size = uint64(order.Uint32(raw[4:]))
+		raw = raw[12:]
+	case elf.ELFCLASS64:
+		if len(raw) < 24 {
+			return nil, fmt.Errorf("Truncated compression header: %w", ErrBadCompression)
+		}
+		typ = elf.CompressionType(order.Uint32(raw))
+		size = order.Uint64(raw[8:])
+		raw = raw[24:]
+	default:
+		return nil, fmt.Errorf("Unknown ELF class %v: %w", class, ErrBadCompression)
+	}
+	switch typ {
+	case elf.COMPRESS_ZLIB:
+		return inflate(raw, size)
+	case compressZstd:
+		return unzstd(raw, size)
+	default:
+		return nil, fmt.Errorf("Unknown compression type %d: %w", typ, ErrBadCompression)
+	}
+}
+
+// Decompresses a legacy .zdebug section, which is "ZLIB", the uncompressed
+// size as 8 big-endian bytes, then a zlib stream
+func decompressZdebug(raw []byte) ([]byte, error) {
+	if len(raw) < 12 || string(raw[:4]) != "ZLIB" {
+		return nil, fmt.Errorf("Missing ZLIB header: %w", ErrBadCompression)
+	}
+	return inflate(raw[12:], binary.BigEndian.Uint64(raw[4:]))
+}
+
+func inflate(raw []byte, size uint64) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrBadCompression)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrBadCompression)
+	}
+	return checkSize(out, size)
+}
+
+func unzstd(raw []byte, size uint64) ([]byte, error) {
+	d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	out, err := d.DecodeAll(raw, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrBadCompression)
+	}
+	return checkSize(out, size)
+}
+
+func checkSize(out []byte, size uint64) ([]byte, error) {
+	if uint64(len(out)) != size {
+		return nil, fmt.Errorf("Decompressed to %d bytes rather than %d: %w", len(out), size, ErrBadCompression)
+	}
+	return out, nil
+}
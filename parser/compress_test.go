@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+var uncompressed = []byte("durin's door: speak, friend, and enter")
+
+func deflate(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write(b)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func chdr64(typ elf.CompressionType, size int) []byte {
+	h := make([]byte, 24)
+	binary.LittleEndian.PutUint32(h, uint32(typ))
+	binary.LittleEndian.PutUint64(h[8:], uint64(size))
+	binary.LittleEndian.PutUint64(h[16:], 1)
+	return h
+}
+
+func TestDecompressSection(t *testing.T) {
+	b, err := decompressSection(append(chdr64(elf.COMPRESS_ZLIB, len(uncompressed)), deflate(t, uncompressed)...), elf.ELFCLASS64, binary.LittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, uncompressed, b)
+
+	enc, err := zstd.NewWriter(nil)
+	assert.NoError(t, err)
+	frame := enc.EncodeAll(uncompressed, nil)
+	assert.NoError(t, enc.Close())
+	b, err = decompressSection(append(chdr64(compressZstd, len(uncompressed)), frame...), elf.ELFCLASS64, binary.LittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, uncompressed, b)
+
+	// Elf32_Chdr is three words, big-endian here
+	h := make([]byte, 12)
+	binary.BigEndian.PutUint32(h, uint32(compressZstd))
+	binary.BigEndian.PutUint32(h[4:], uint32(len(uncompressed)))
+	b, err = decompressSection(append(h, frame...), elf.ELFCLASS32, binary.BigEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, uncompressed, b)
+
+	for _, raw := range [][]byte{
+		append(chdr64(3, len(uncompressed)), frame...),
+		append(chdr64(compressZstd, len(uncompressed)+1), frame...),
+		append(chdr64(elf.COMPRESS_ZLIB, len(uncompressed)), frame...),
+		chdr64(elf.COMPRESS_ZLIB, 0)[:20],
+	} {
+		_, err = decompressSection(raw, elf.ELFCLASS64, binary.LittleEndian)
+		assert.ErrorIs(t, err, ErrBadCompression)
+	}
+}
+
+func TestDecompressZdebug(t *testing.T) {
+	h := []byte("ZLIB\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint64(h[4:], uint64(len(uncompressed)))
+	b, err := decompressZdebug(append(h, deflate(t, uncompressed)...))
+	assert.NoError(t, err)
+	assert.Equal(t, uncompressed, b)
+
+	_, err = decompressZdebug(deflate(t, uncompressed))
+	assert.ErrorIs(t, err, ErrBadCompression)
+}
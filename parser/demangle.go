@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"strings"
+
+	"github.com/ianlancetaylor/demangle"
+)
+
+// Returns the human-readable form of a C++ (Itanium ABI) or Rust (legacy or
+// v0) symbol name, for example "ns::count" for "_ZN2ns5countE", or the name
+// unchanged if it is not mangled
+//
+// The extra underscore Mach-O puts in front of every symbol is ignored.
+func Demangle(name string) string {
+	s, err := demangle.ToString(trimMachOPrefix(name))
+	if err != nil {
+		return name
+	}
+	return s
+}
+
+// Returns the demangled name without the parameters of the function it
+// names, for example "ns::add" for "_ZN2ns3addEii", or false if the name is
+// not mangled
+func demangleNoParams(name string) (string, bool) {
+	s, err := demangle.ToString(trimMachOPrefix(name), demangle.NoParams)
+	if err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// Strips the underscore Mach-O adds to symbols that are already mangled
+func trimMachOPrefix(name string) string {
+	if strings.HasPrefix(name, "__Z") || strings.HasPrefix(name, "__R") {
+		return name[1:]
+	}
+	return name
+}
+
+// DW_AT_MIPS_linkage_name, which GCC emitted in place of DW_AT_linkage_name
+// before DWARF 4 and debug/dwarf does not name
+const attrMIPSLinkageName dwarf.Attr = 0x2007
+
+// Returns the symbol name the compiler gave an entry, or the empty string if
+// it recorded none
+func LinkageName(entry *dwarf.Entry) string {
+	if name, ok := entry.Val(dwarf.AttrLinkageName).(string); ok {
+		return name
+	}
+	name, _ := entry.Val(attrMIPSLinkageName).(string)
+	return name
+}
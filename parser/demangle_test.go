@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+func TestDemangle(t *testing.T) {
+	for mangled, want := range map[string]string{
+		"_ZN2ns5countE":           "ns::count",
+		"_ZN2ns3addEii":           "ns::add(int, int)",
+		"__ZN2ns5countE":          "ns::count",
+		"_RNvCs1234_7mycrate3foo": "mycrate::foo",
+		"count":                   "count",
+		"main":                    "main",
+	} {
+		assert.Equal(t, want, Demangle(mangled), mangled)
+	}
+}
+
+// Builds DWARF for:
+//
+//	namespace ns {
+//	  int count;                 // at 0x5000
+//	  int add(int, int) {}
+//	  double add(double, double) {}
+//	}
+//	int legacy;                  // with DW_AT_MIPS_linkage_name
+func buildMangled(t *testing.T) *Program {
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	doubleType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "double"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 8})
+	ns := dwarftest.New(dwarf.TagNamespace,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "ns"},
+	).With(
+		// DW_OP_addr 0x5000
+		dwarftest.New(dwarf.TagVariable,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "count"},
+			dwarftest.Attr{Attr: dwarf.AttrLinkageName, Val: "_ZN2ns5countE"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x03, 0x00, 0x50, 0, 0, 0, 0, 0, 0}}),
+		dwarftest.New(dwarf.TagSubprogram,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "add"},
+			dwarftest.Attr{Attr: dwarf.AttrLinkageName, Val: "_ZN2ns3addEii"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x1000)},
+			dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x10}),
+		dwarftest.New(dwarf.TagSubprogram,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "add"},
+			dwarftest.Attr{Attr: dwarf.AttrLinkageName, Val: "_ZN2ns3addEdd"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: doubleType},
+			dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x2000)},
+			dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x10}),
+	)
+	legacy := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "legacy"},
+		dwarftest.Attr{Attr: attrMIPSLinkageName, Val: "_ZL6legacy"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType})
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "ns.cpp"},
+	).With(intType, doubleType, ns, legacy)
+	data, err := dwarftest.Build(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := NewProgram(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func TestLookupSymbol(t *testing.T) {
+	idx := buildMangled(t).Index()
+
+	for _, name := range []string{"_ZN2ns5countE", "__ZN2ns5countE", "ns::count"} {
+		ies := idx.Lookup(name)
+		if assert.Len(t, ies, 1, name) {
+			assert.Equal(t, "count", ies[0].Name)
+			assert.Equal(t, dwarf.TagVariable, ies[0].Tag)
+		}
+	}
+
+	// Overloads are told apart by their parameters
+	assert.Len(t, idx.Lookup("ns::add"), 2)
+	assert.Len(t, idx.Lookup("ns::add(double, double)"), 1)
+	assert.Len(t, idx.LookupTag("_ZN2ns3addEii", dwarf.TagSubprogram), 1)
+	// A clone made by the optimizer is found through the function it copies
+	assert.Len(t, idx.Lookup("_ZN2ns3addEii.constprop.0"), 2)
+
+	assert.Len(t, idx.Lookup("_ZL6legacy"), 1)
+	assert.Empty(t, idx.Lookup("_ZN2ns7missingE"))
+	assert.Empty(t, idx.Lookup("ns::missing"))
+
+	// Plain names are still preferred
+	assert.Len(t, idx.Lookup("add"), 2)
+}
+
+func TestLinkageNames(t *testing.T) {
+	prog := buildMangled(t)
+
+	e, _, err := prog.Index().GetEntry("ns::count")
+	assert.NoError(t, err)
+	v, err := prog.Variable(e)
+	assert.NoError(t, err)
+	assert.Equal(t, "count", v.Name())
+	assert.Equal(t, "_ZN2ns5countE", v.LinkageName)
+	assert.Equal(t, "ns::count", v.DemangledName())
+	assert.Equal(t, 0x5000, v.Address)
+
+	e, _, err = prog.Index().GetEntry("_ZN2ns3addEdd")
+	assert.NoError(t, err)
+	f, err := NewFunctionProxy(prog, e)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0x2000), f.LowPC)
+	assert.Equal(t, "ns::add(double, double)", f.DemangledName())
+
+	info := FormatEntryInfo(e)
+	assert.Contains(t, info, "DW_AT_linkage_name: _ZN2ns3addEdd")
+	assert.Contains(t, info, "Demangled: ns::add(double, double)")
+}
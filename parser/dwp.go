@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Identifiers of the sections a unit contributes to in a .dwp package,
+// which DWARF 5 and the GNU packages before it number alike
+const (
+	dwSectInfo       = 1
+	dwSectAbbrev     = 3
+	dwSectStrOffsets = 6
+	dwSectRnglists   = 8
+)
+
+// The .debug_cu_index of a .dwp package, which locates the contributions
+// each unit makes to the package's sections
+type packageIndex struct {
+	columns []uint32
+	// Row of each unit, keyed by its dwo_id
+	rows    map[uint64]int
+	offsets []uint32
+	sizes   []uint32
+}
+
+// Parses a version 2 (GNU) or version 5 unit index
+func parsePackageIndex(b []byte, order binary.ByteOrder) (*packageIndex, error) {
+	if len(b) < 16 {
+		return nil, fmt.Errorf("Truncated .debug_cu_index: %w", ErrUnsupportedForm)
+	}
+	// Version 5 is a 2-byte field followed by padding, version 2 fills all 4
+	if order.Uint16(b) != 5 && order.Uint32(b) != 2 {
+		return nil, fmt.Errorf(".debug_cu_index version %d: %w", order.Uint32(b), ErrUnsupportedForm)
+	}
+	ncols := uint64(order.Uint32(b[4:]))
+	nunits := uint64(order.Uint32(b[8:]))
+	nslots := uint64(order.Uint32(b[12:]))
+	if uint64(len(b)) < 16+nslots*12+ncols*4+nunits*ncols*8 {
+		return nil, fmt.Errorf("Truncated .debug_cu_index: %w", ErrUnsupportedForm)
+	}
+
+	idx := &packageIndex{rows: make(map[uint64]int)}
+	sigs := b[16:]
+	rows := sigs[nslots*8:]
+	for i := uint64(0); i < nslots; i++ {
+		// Rows are numbered from 1, with 0 marking an empty slot
+		row := uint64(order.Uint32(rows[i*4:]))
+		if row == 0 {
+			continue
+		}
+		if row > nunits {
+			return nil, fmt.Errorf(".debug_cu_index row %d of %d: %w", row, nunits, ErrUnsupportedForm)
+		}
+		idx.rows[order.Uint64(sigs[i*8:])] = int(row - 1)
+	}
+	table := rows[nslots*4:]
+	for i := uint64(0); i < ncols; i++ {
+		idx.columns = append(idx.columns, order.Uint32(table[i*4:]))
+	}
+	table = table[ncols*4:]
+	for i := uint64(0); i < 2*nunits*ncols; i++ {
+		v := order.Uint32(table[i*4:])
+		if i < nunits*ncols {
+			idx.offsets = append(idx.offsets, v)
+		} else {
+			idx.sizes = append(idx.sizes, v)
+		}
+	}
+	return idx, nil
+}
+
+// Returns the part of a package section contributed by the unit in this row,
+// or nil if it makes no contribution
+func (idx *packageIndex) slice(b []byte, row int, section uint32) ([]byte, error) {
+	for col, id := range idx.columns {
+		if id != section {
+			continue
+		}
+		i := row*len(idx.columns) + col
+		off, size := uint64(idx.offsets[i]), uint64(idx.sizes[i])
+		if off+size > uint64(len(b)) {
+			return nil, fmt.Errorf("Contribution to section %d at %#x runs past its end: %w", section, off, ErrUnsupportedForm)
+		}
+		return b[off : off+size], nil
+	}
+	return nil, nil
+}
+
+// A .dwp package, which gathers the .dwo files of a binary into one
+type dwpPackage struct {
+	sections dwoSections
+	index    *packageIndex
+	order    binary.ByteOrder
+}
+
+// Opens the package named after the binary, found next to it or in one of
+// the search directories, or returns nil if there is none
+func openPackage(paths SearchPaths, order binary.ByteOrder) (*dwpPackage, error) {
+	if paths.Binary == "" {
+		return nil, nil
+	}
+	name := filepath.Base(paths.Binary) + ".dwp"
+	candidates := []string{paths.Binary + ".dwp"}
+	for _, dir := range paths.Dirs {
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		pkg, err := readPackage(path, order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return pkg, nil
+	}
+	return nil, nil
+}
+
+func readPackage(path string, order binary.ByteOrder) (*dwpPackage, error) {
+	f, err := OpenELF(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkg := &dwpPackage{order: order}
+	for name, dst := range pkg.sections.fields() {
+		if *dst, err = f.SectionData(name); err != nil {
+			return nil, err
+		}
+	}
+	index, err := f.SectionData(".debug_cu_index")
+	if err != nil {
+		return nil, err
+	}
+	if pkg.index, err = parsePackageIndex(index, order); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Reads the unit of a skeleton from this package
+func (p *dwpPackage) unit(skel *skeleton, addrs []byte) (*dwoUnit, error) {
+	row, ok := p.index.rows[skel.id]
+	if !ok {
+		return nil, errDwoMismatch
+	}
+	s := dwoSections{str: p.sections.str}
+	for _, c := range []struct {
+		dst     *[]byte
+		src     []byte
+		section uint32
+	}{
+		{&s.info, p.sections.info, dwSectInfo},
+		{&s.abbrev, p.sections.abbrev, dwSectAbbrev},
+		{&s.strOffsets, p.sections.strOffsets, dwSectStrOffsets},
+		{&s.rnglists, p.sections.rnglists, dwSectRnglists},
+	} {
+		var err error
+		if *c.dst, err = p.index.slice(c.src, row, c.section); err != nil {
+			return nil, err
+		}
+	}
+	return s.unit(skel, addrs, p.order)
+}
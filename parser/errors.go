@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the parser. Callers should match them with errors.Is
+// since they are usually wrapped with more context.
+var (
+	// No entry, member or child matches the requested name
+	ErrNotFound = errors.New("not found")
+	// An attribute is encoded in a form or with an operation we cannot decode
+	ErrUnsupportedForm = errors.New("unsupported form")
+	// An entry has no location, so it cannot be found in memory
+	ErrNoLocation = errors.New("no location")
+	// An entry has no size, so its extent in memory is unknown
+	ErrNoSize = errors.New("no size")
+	// A proxy was asked to read or write memory without a client
+	ErrNoClient = errors.New("no client set")
+	// A value does not fit in the type or field it was written to, or an
+	// array index is beyond the end of the array
+	ErrOutOfRange = errors.New("out of range")
+	// A path into a variable is malformed or does not fit the variable's type
+	ErrBadPath = errors.New("bad path")
+	// A value to be written could not be parsed
+	ErrBadValue = errors.New("bad value")
+	// A location depends on a register whose value was not supplied
+	ErrNoRegister = errors.New("register not available")
+	// A compressed section uses an unknown algorithm or does not decompress
+	// to the size its header gives
+	ErrBadCompression = errors.New("bad compressed section")
+)
+
+// An EntryError records a problem parsing a particular DWARF entry
+type EntryError struct {
+	Offset dwarf.Offset
+	Tag    dwarf.Tag
+	Name   string
+	Err    error
+}
+
+func newEntryError(e *dwarf.Entry, err error) *EntryError {
+	return &EntryError{
+		Offset: e.Offset,
+		Tag:    e.Tag,
+		Name:   EntryName(e),
+		Err:    err,
+	}
+}
+
+func (e *EntryError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s at offset %#x: %v", e.Tag, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("%s %s at offset %#x: %v", e.Tag, e.Name, e.Offset, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+func TestNotFoundErrors(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	program, err := NewProgram(data)
+	assert.NoError(t, err)
+
+	_, _, err = GetEntry(data.Reader(), "badname")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = program.Index().GetEntry("badname")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	e, _, err := program.Index().GetEntry("formula_1_teams")
+	assert.NoError(t, err)
+	v, err := NewVariableProxy(program.Types(), e)
+	assert.NoError(t, err)
+	_, err = v.GetChild("badname")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = v.Type.GetChild("badname")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, v.Read(), ErrNoClient)
+	assert.ErrorIs(t, v.Write(), ErrNoClient)
+	assert.ErrorIs(t, v.SetField("last_wcc", 2021), ErrOutOfRange)
+}
+
+func TestLocationErrors(t *testing.T) {
+	_, err := ParseLocation(nil)
+	assert.ErrorIs(t, err, ErrNoLocation)
+	// DW_OP_fbreg describes a local variable rather than a static address
+	_, err = ParseLocation([]byte{0x91, 0x7c})
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	addr, err := ParseLocation([]byte{0x03, 0xef, 0xbe, 0xed, 0xfe})
+	assert.NoError(t, err)
+	assert.Equal(t, 0xfeedbeef, addr)
+	// Operations after the address are not part of it
+	_, err = ParseLocation([]byte{0x03, 0xef, 0xbe, 0xed, 0xfe, 0x23, 0x08})
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	_, err = ParseLocation([]byte{0x03, 0xef, 0xbe})
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	_, err = ParseLocationIn([]byte{0x03, 0xef, 0xbe, 0xed, 0xfe}, 8, binary.LittleEndian)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	addr, err = ParseLocationIn([]byte{0x03, 0xfe, 0xed, 0xbe, 0xef}, 4, binary.BigEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, 0xfeedbeef, addr)
+
+	data, _ := getDataFromFile(testcaseFilename)
+	program, err := NewProgram(data)
+	assert.NoError(t, err)
+	e, _, err := program.Index().GetEntry("Driver")
+	assert.NoError(t, err)
+	_, err = GetLocation(e)
+	assert.ErrorIs(t, err, ErrNoLocation)
+	var entryErr *EntryError
+	assert.True(t, errors.As(err, &entryErr))
+	assert.Equal(t, "Driver", entryErr.Name)
+	assert.Equal(t, e.Offset, entryErr.Offset)
+
+	// Types have no location, so they cannot be variables
+	_, err = NewVariableProxy(program.Types(), e)
+	assert.ErrorIs(t, err, ErrNoLocation)
+}
+
+func TestOddEntries(t *testing.T) {
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	union := dwarftest.New(dwarf.TagUnionType,
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4},
+	).With(
+		dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "as_int"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType}),
+	)
+	flags := dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "Flags"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 8},
+	).With(
+		// An anonymous union has no name
+		dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: union},
+			dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: 0}),
+		// Older compilers encode member offsets as location expressions
+		dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "bits"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: []byte{0x23, 0x04}}),
+	)
+	// A location list is an offset into .debug_loc rather than an expression
+	moving := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "moving"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: 16})
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "odd.c"},
+	).With(intType, union, flags, moving)
+	data, err := dwarftest.Build(cu)
+	assert.NoError(t, err)
+	program, err := NewProgram(data)
+	assert.NoError(t, err)
+
+	e, _, err := program.Index().GetEntry("Flags")
+	assert.NoError(t, err)
+	p, err := NewTypeDefProxy(program.Types(), e)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "bits"}, p.ListChildren())
+	bits, err := p.GetChild("bits")
+	assert.NoError(t, err)
+	assert.Equal(t, 32, bits.structOffset)
+
+	e, _, err = program.Index().GetEntry("moving")
+	assert.NoError(t, err)
+	_, err = NewVariableProxy(program.Types(), e)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	assert.NotPanics(t, func() { FormatEntryInfo(e) })
+	assert.NotPanics(t, func() { FormatEntryInfo(nil) })
+}
@@ -0,0 +1,374 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"fmt"
+)
+
+// A FunctionProxy describes a function: where its code lies, the parameters
+// it takes, what it returns, the scopes inside it and each place it was
+// inlined.
+//
+// Like the other proxies it carries no DWARF data, only what a user needs.
+// The children of a function are its parameters followed by the variables
+// declared at the top of its body.
+type FunctionProxy struct {
+	name string
+	// The symbol name of the function, such as "_Z3fooi", or empty if the
+	// compiler did not record one
+	LinkageName string
+	// The address of the first instruction of the function and the address
+	// just past its last one, both 0 for functions with no code such as
+	// declarations and functions that were only ever inlined
+	LowPC  uint64
+	HighPC uint64
+	// Every address range of the function's code, which only differs from
+	// [LowPC, HighPC) for functions split into several pieces
+	Ranges [][2]uint64
+	Params []Local
+	Locals []Local
+	// The type returned by the function, named as in C, or nil for void
+	ReturnType *TypeDefProxy
+	Blocks     []LexicalBlock
+	Inlined    []InlinedInstance
+	// The offset of the entry describing this function
+	Offset dwarf.Offset
+
+	frameBase []byte
+	// The size of an address in the unit holding the function
+	addrSize int
+}
+
+// A Local is a parameter of a function or a variable declared inside one
+type Local struct {
+	Name string
+	// The type of the local written as in C, for example "const char*"
+	TypeName string
+	// The layout of the local's type, named after the local
+	Type TypeDefProxy
+	// The location expression of the local, usually relative to the frame
+	// base, or nil if it has none or it moves as the function runs
+	Location []byte
+	// The offset of the entry describing this local
+	Offset dwarf.Offset
+}
+
+// A LexicalBlock is a scope nested inside a function
+type LexicalBlock struct {
+	Ranges [][2]uint64
+	Locals []Local
+	Blocks []LexicalBlock
+}
+
+// An InlinedInstance is one place a function was inlined into another
+type InlinedInstance struct {
+	Ranges [][2]uint64
+	// The index of the source file of the call in the line table of the
+	// enclosing CU, and the line of the call
+	CallFile int
+	CallLine int
+	// The offset of the entry describing this instance
+	Offset dwarf.Offset
+}
+
+// Construct a new FunctionProxy for a DW_TAG_subprogram entry
+func NewFunctionProxy(prog *Program, entry *dwarf.Entry) (*FunctionProxy, error) {
+	if entry.Tag != dwarf.TagSubprogram {
+		return nil, newEntryError(entry, fmt.Errorf("Expected %s: %w", dwarf.TagSubprogram, ErrUnsupportedForm))
+	}
+	entry, err := prog.Index().Complete(entry)
+	if err != nil {
+		return nil, err
+	}
+	p := &FunctionProxy{
+		name:   EntryName(entry),
+		Params: make([]Local, 0),
+		Locals: make([]Local, 0),
+		Blocks: make([]LexicalBlock, 0),
+		Offset: entry.Offset,
+	}
+	p.LinkageName = LinkageName(entry)
+	p.frameBase, _ = entry.Val(dwarf.AttrFrameBase).([]byte)
+
+	p.Ranges, err = prog.Data().Ranges(entry)
+	if err != nil {
+		return nil, newEntryError(entry, err)
+	}
+	p.LowPC, p.HighPC = bounds(p.Ranges)
+
+	r := prog.Reader()
+	r.Seek(entry.Offset)
+	p.addrSize = r.AddressSize()
+
+	if HasAttr(entry, dwarf.AttrType) {
+		t, err := NewTypeDefProxy(prog.Types(), entry)
+		if err != nil {
+			return nil, err
+		}
+		ret := *t
+		ret.name = TypeName(prog.Reader(), entry)
+		p.ReturnType = &ret
+	}
+
+	if entry.Children {
+		if _, err := r.Next(); err != nil {
+			return nil, err
+		}
+		if err := readScope(prog, r, &p.Params, &p.Locals, &p.Blocks); err != nil {
+			return nil, err
+		}
+	}
+
+	p.Inlined = make([]InlinedInstance, 0)
+	for _, ie := range prog.Index().Inlined(entry.Offset) {
+		e, err := prog.Index().Entry(ie)
+		if err != nil {
+			return nil, err
+		}
+		inst, err := newInlinedInstance(prog, e)
+		if err != nil {
+			return nil, err
+		}
+		p.Inlined = append(p.Inlined, inst)
+	}
+	return p, nil
+}
+
+// Reads the children of a function or block, which the reader must be
+// positioned at, into the parameters, locals and blocks of that scope.
+// Blocks may not have parameters, in which case params is nil.
+func readScope(prog *Program, r *dwarf.Reader, params *[]Local, locals *[]Local, blocks *[]LexicalBlock) error {
+	for {
+		child, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if child == nil || child.Tag == 0 {
+			return nil
+		}
+		switch {
+		case child.Tag == dwarf.TagFormalParameter && params != nil:
+			l, err := newLocal(prog, child)
+			if err != nil {
+				return err
+			}
+			*params = append(*params, l)
+		case child.Tag == dwarf.TagVariable:
+			l, err := newLocal(prog, child)
+			if err != nil {
+				return err
+			}
+			*locals = append(*locals, l)
+		case child.Tag == dwarf.TagLexDwarfBlock:
+			ranges, err := prog.Data().Ranges(child)
+			if err != nil {
+				return newEntryError(child, err)
+			}
+			b := LexicalBlock{
+				Ranges: ranges,
+				Locals: make([]Local, 0),
+				Blocks: make([]LexicalBlock, 0),
+			}
+			if child.Children {
+				if err := readScope(prog, r, nil, &b.Locals, &b.Blocks); err != nil {
+					return err
+				}
+			}
+			*blocks = append(*blocks, b)
+			continue
+		}
+		// The children of anything else, such as nested types and inlined
+		// calls, do not belong to this scope
+		if child.Children {
+			r.SkipChildren()
+		}
+	}
+}
+
+func newLocal(prog *Program, entry *dwarf.Entry) (Local, error) {
+	// Locals of an out-of-line copy of an inlined function take their names
+	// and types from the abstract instance
+	entry, err := prog.Index().Merge(entry)
+	if err != nil {
+		return Local{}, err
+	}
+	t, err := NewTypeDefProxy(prog.Types(), entry)
+	if err != nil {
+		return Local{}, err
+	}
+	l := Local{
+		Name:     EntryName(entry),
+		TypeName: TypeName(prog.Reader(), entry),
+		Type:     *t,
+		Offset:   entry.Offset,
+	}
+	// Locals in location lists have no single location, so are left nil
+	l.Location, _ = entry.Val(dwarf.AttrLocation).([]byte)
+	l.Type.name = l.Name
+	return l, nil
+}
+
+func newInlinedInstance(prog *Program, entry *dwarf.Entry) (InlinedInstance, error) {
+	ranges, err := prog.Data().Ranges(entry)
+	if err != nil {
+		return InlinedInstance{}, newEntryError(entry, err)
+	}
+	file, _ := entry.Val(dwarf.AttrCallFile).(int64)
+	line, _ := entry.Val(dwarf.AttrCallLine).(int64)
+	return InlinedInstance{
+		Ranges:   ranges,
+		CallFile: int(file),
+		CallLine: int(line),
+		Offset:   entry.Offset,
+	}, nil
+}
+
+// Returns the lowest and highest addresses covered by these ranges
+func bounds(ranges [][2]uint64) (uint64, uint64) {
+	if len(ranges) == 0 {
+		return 0, 0
+	}
+	low, high := ranges[0][0], ranges[0][1]
+	for _, r := range ranges[1:] {
+		if r[0] < low {
+			low = r[0]
+		}
+		if r[1] > high {
+			high = r[1]
+		}
+	}
+	return low, high
+}
+
+func (p FunctionProxy) Name() string {
+	return p.name
+}
+
+// Returns the demangled linkage name of this function, such as
+// "ns::add(int, int)", or its name if it has no linkage name
+func (p FunctionProxy) DemangledName() string {
+	if p.LinkageName == "" {
+		return p.name
+	}
+	return Demangle(p.LinkageName)
+}
+
+// Returns the names of the parameters of this function followed by those
+// of the variables at the top of its body
+func (p FunctionProxy) ListChildren() []string {
+	ret := make([]string, 0, len(p.Params)+len(p.Locals))
+	for _, l := range p.locals() {
+		if l.Name != "" {
+			ret = append(ret, l.Name)
+		}
+	}
+	return ret
+}
+
+// Returns the type of the parameter or top-level variable with this name
+func (p FunctionProxy) GetChild(childName string) (*TypeDefProxy, error) {
+	for _, l := range p.locals() {
+		if l.Name == childName {
+			t := l.Type
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("Could not find parameter or local %s in function %s: %w", childName, p.name, ErrNotFound)
+}
+
+// Returns the frame base of this function in a frame with these registers
+func (p FunctionProxy) FrameBase(regs Registers) (uint64, error) {
+	if p.frameBase == nil {
+		return 0, fmt.Errorf("Function %s has no frame base: %w", p.name, ErrNoLocation)
+	}
+	return EvalFrameBase(p.frameBase, p.addrSize, regs)
+}
+
+// Returns the parameter or local variable with this name as a variable,
+// located in a frame of this function with these registers
+//
+// Parameters and variables at the top of the function are searched first,
+// then those of nested blocks. Locals held in a register rather than in
+// memory have no address and cannot be returned.
+func (p FunctionProxy) LocalVariable(name string, regs Registers) (*VariableProxy, error) {
+	l, ok := p.findLocal(name)
+	if !ok {
+		return nil, fmt.Errorf("Could not find parameter or local %s in function %s: %w", name, p.name, ErrNotFound)
+	}
+	if l.Location == nil {
+		return nil, fmt.Errorf("Local %s of function %s: %w", name, p.name, ErrNoLocation)
+	}
+	// Locations relative to the frame base cannot be found without one
+	var frameBase *uint64
+	if p.frameBase != nil {
+		base, err := p.FrameBase(regs)
+		if err != nil {
+			return nil, err
+		}
+		frameBase = &base
+	}
+	loc, err := evalLocation(l.Location, p.addrSize, frameBase, regs)
+	if err != nil {
+		return nil, fmt.Errorf("Local %s of function %s: %w", name, p.name, err)
+	}
+	if loc.InRegister {
+		return nil, fmt.Errorf("Local %s of function %s is held in register %d: %w", name, p.name, loc.Register, ErrNoLocation)
+	}
+	return &VariableProxy{
+		name:    l.Name,
+		Type:    l.Type,
+		Address: int(loc.Address),
+		value:   []byte{},
+	}, nil
+}
+
+func (p FunctionProxy) findLocal(name string) (Local, bool) {
+	for _, l := range p.locals() {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return findInBlocks(p.Blocks, name)
+}
+
+func findInBlocks(blocks []LexicalBlock, name string) (Local, bool) {
+	for _, b := range blocks {
+		for _, l := range b.Locals {
+			if l.Name == name {
+				return l, true
+			}
+		}
+		if l, ok := findInBlocks(b.Blocks, name); ok {
+			return l, true
+		}
+	}
+	return Local{}, false
+}
+
+func (p FunctionProxy) locals() []Local {
+	return append(append([]Local{}, p.Params...), p.Locals...)
+}
+
+// Returns the signature of this function as in C, for example
+// "int add(int a, int b)"
+func (p FunctionProxy) Signature() string {
+	ret := "void"
+	if p.ReturnType != nil {
+		ret = p.ReturnType.name
+	}
+	s := ret + " " + p.name + "("
+	for i, l := range p.Params {
+		if i > 0 {
+			s += ", "
+		}
+		s += l.TypeName
+		if l.Name != "" {
+			s += " " + l.Name
+		}
+	}
+	return s + ")"
+}
+
+func (p *FunctionProxy) GoString() string {
+	return fmt.Sprintf("Function %s at [%#x, %#x)", p.Signature(), p.LowPC, p.HighPC)
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+// Builds DWARF for:
+//
+//	int add(int a, const char *s) { // s is kept in a register
+//	  int sum;
+//	  {
+//	    int tmp;
+//	  }
+//	}
+//	void caller(void) {
+//	  add(1, "x"); // inlined at line 12
+//	}
+//	void frameless(void) { // with no frame base recorded
+//	  int x; // on the frame
+//	  int y; // relative to the stack pointer
+//	}
+func buildFunctions(t *testing.T) *Program {
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	charType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "char"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 1})
+	constChar := dwarftest.New(dwarf.TagConstType,
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: charType})
+	charPtr := dwarftest.New(dwarf.TagPointerType,
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 8},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: constChar})
+	add := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "add"},
+		dwarftest.Attr{Attr: dwarf.AttrLinkageName, Val: "_Z3addiPKc"},
+		dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x1000)},
+		dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x20},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		// DW_OP_reg6
+		dwarftest.Attr{Attr: dwarf.AttrFrameBase, Val: []byte{0x56}},
+	).With(
+		// DW_OP_fbreg -20
+		dwarftest.New(dwarf.TagFormalParameter,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "a"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x91, 0x6c}}),
+		// DW_OP_reg5
+		dwarftest.New(dwarf.TagFormalParameter,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "s"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: charPtr},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x55}}),
+		// DW_OP_breg7 +8
+		dwarftest.New(dwarf.TagVariable,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "sum"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x77, 0x08}}),
+		dwarftest.New(dwarf.TagLexDwarfBlock,
+			dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x1008)},
+			dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x8},
+		).With(
+			// DW_OP_fbreg -4
+			dwarftest.New(dwarf.TagVariable,
+				dwarftest.Attr{Attr: dwarf.AttrName, Val: "tmp"},
+				dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+				dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x91, 0x7c}}),
+		),
+	)
+	caller := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "caller"},
+		dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x2000)},
+		dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x10},
+	).With(
+		dwarftest.New(dwarf.TagInlinedSubroutine,
+			dwarftest.Attr{Attr: dwarf.AttrAbstractOrigin, Val: add},
+			dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x2004)},
+			dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x6},
+			dwarftest.Attr{Attr: dwarf.AttrCallFile, Val: 1},
+			dwarftest.Attr{Attr: dwarf.AttrCallLine, Val: 12},
+		).With(
+			dwarftest.New(dwarf.TagFormalParameter,
+				dwarftest.Attr{Attr: dwarf.AttrAbstractOrigin, Val: add}),
+		),
+	)
+	frameless := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "frameless"},
+		dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x3000)},
+		dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x10},
+	).With(
+		// DW_OP_fbreg -8
+		dwarftest.New(dwarf.TagVariable,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "x"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x91, 0x78}}),
+		// DW_OP_breg7 +4
+		dwarftest.New(dwarf.TagVariable,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "y"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x77, 0x04}}),
+	)
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "add.c"},
+	).With(intType, charType, constChar, charPtr, add, caller, frameless)
+	data, err := dwarftest.Build(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := NewProgram(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func getFunction(t *testing.T, prog *Program, name string) *FunctionProxy {
+	e, _, err := prog.Index().GetEntry(name)
+	assert.NoError(t, err)
+	p, err := NewFunctionProxy(prog, e)
+	assert.NoError(t, err)
+	return p
+}
+
+func TestFunctionProxy(t *testing.T) {
+	prog := buildFunctions(t)
+
+	add := getFunction(t, prog, "add")
+	assert.Equal(t, "add", add.Name())
+	assert.Equal(t, "_Z3addiPKc", add.LinkageName)
+	assert.Equal(t, uint64(0x1000), add.LowPC)
+	assert.Equal(t, uint64(0x1020), add.HighPC)
+	assert.Equal(t, "int", add.ReturnType.Name())
+	assert.Equal(t, 32, add.ReturnType.BitSize())
+	assert.Equal(t, "int add(int a, const char* s)", add.Signature())
+	assert.Equal(t, []string{"a", "s", "sum"}, add.ListChildren())
+
+	s, err := add.GetChild("s")
+	assert.NoError(t, err)
+	assert.Equal(t, "s", s.Name())
+	assert.Equal(t, 64, s.BitSize())
+	_, err = add.GetChild("tmp")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Equal(t, 1, len(add.Blocks))
+	assert.Equal(t, [][2]uint64{{0x1008, 0x1010}}, add.Blocks[0].Ranges)
+	assert.Equal(t, "tmp", add.Blocks[0].Locals[0].Name)
+	assert.Equal(t, "int", add.Blocks[0].Locals[0].TypeName)
+
+	assert.Equal(t, 1, len(add.Inlined))
+	assert.Equal(t, [][2]uint64{{0x2004, 0x200a}}, add.Inlined[0].Ranges)
+	assert.Equal(t, 1, add.Inlined[0].CallFile)
+	assert.Equal(t, 12, add.Inlined[0].CallLine)
+
+	// The parameters of inlined calls belong to the call, not the caller
+	caller := getFunction(t, prog, "caller")
+	assert.Nil(t, caller.ReturnType)
+	assert.Equal(t, "void caller()", caller.Signature())
+	assert.Equal(t, []string{}, caller.ListChildren())
+	assert.Equal(t, 0, len(caller.Inlined))
+
+	e, _, err := prog.Index().GetEntry("sum")
+	assert.NoError(t, err)
+	_, err = NewFunctionProxy(prog, e)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+}
+
+func TestFunctionProxyLocals(t *testing.T) {
+	prog := buildFunctions(t)
+	add := getFunction(t, prog, "add")
+	regs := Registers{6: 0x7000, 7: 0x6f00}
+
+	base, err := add.FrameBase(regs)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0x7000), base)
+
+	a, err := add.LocalVariable("a", regs)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", a.Name())
+	assert.Equal(t, 0x7000-20, a.Address)
+	assert.Equal(t, 32, a.Type.BitSize())
+	sum, err := add.LocalVariable("sum", regs)
+	assert.NoError(t, err)
+	assert.Equal(t, 0x6f08, sum.Address)
+	tmp, err := add.LocalVariable("tmp", regs)
+	assert.NoError(t, err)
+	assert.Equal(t, 0x7000-4, tmp.Address)
+
+	_, err = add.LocalVariable("s", regs)
+	assert.ErrorIs(t, err, ErrNoLocation)
+	_, err = add.LocalVariable("nothing", regs)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = add.LocalVariable("a", Registers{7: 0x6f00})
+	assert.ErrorIs(t, err, ErrNoRegister)
+
+	caller := getFunction(t, prog, "caller")
+	_, err = caller.FrameBase(regs)
+	assert.ErrorIs(t, err, ErrNoLocation)
+
+	// Locals on the frame cannot be found without a frame base
+	frameless := getFunction(t, prog, "frameless")
+	_, err = frameless.LocalVariable("x", regs)
+	assert.ErrorIs(t, err, ErrNoLocation)
+	y, err := frameless.LocalVariable("y", regs)
+	assert.NoError(t, err)
+	assert.Equal(t, 0x6f04, y.Address)
+}
@@ -0,0 +1,491 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// An IndexEntry locates a single named entry within the DWARF without
+// holding on to the entry itself.
+type IndexEntry struct {
+	Name   string
+	Offset dwarf.Offset
+	Tag    dwarf.Tag
+	// Offset of the compile unit containing this entry
+	CU dwarf.Offset
+}
+
+// An Index maps names to the offsets of every entry carrying that name so that
+// entries can be found without walking the DWARF.
+//
+// When the binary carries an accelerator table (.debug_names or .gdb_index),
+// only the compile units it lists for a name are read, the first time that
+// name is looked up. The tables only describe names visible across units, so
+// a name they do not list, such as that of a member or a local type, is
+// found by walking the rest of the DWARF once. Without a table, the index is
+// built with a single walk when it is created; every lookup afterwards is a
+// map access.
+//
+// Entries are also found by their mangled linkage names and by the
+// demangled forms of those, which are worked out the first time they are
+// needed.
+type Index struct {
+	data *dwarf.Data
+	// The units listed for each name, or nil if every unit was walked when
+	// the index was built
+	accel *accelTable
+	cus   []IndexEntry
+
+	// Guards the maps below, which grow as units are walked
+	mux    sync.Mutex
+	walked map[dwarf.Offset]bool
+	full   bool
+
+	byName    map[string][]IndexEntry
+	byLinkage map[string][]IndexEntry
+	// The inlined instances of each function, keyed by the offset of the
+	// entry they name as their abstract origin
+	inlined map[dwarf.Offset][]IndexEntry
+	// The variables and functions completing each declaration or abstract
+	// instance, keyed by the offset of the entry they name as their
+	// specification or abstract origin
+	completions map[dwarf.Offset][]IndexEntry
+
+	demangledOnce sync.Once
+	byDemangled   map[string][]IndexEntry
+}
+
+// Builds an index over all named entries in this DWARF data
+func NewIndex(data *dwarf.Data) (*Index, error) {
+	idx := &Index{data: data}
+	if err := idx.walkAll(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// The contents of a fully built index, which can be saved and used to
+// rebuild the index without walking the DWARF again
+type IndexData struct {
+	CUs         []IndexEntry                  `json:"cus"`
+	ByName      map[string][]IndexEntry       `json:"by_name"`
+	ByLinkage   map[string][]IndexEntry       `json:"by_linkage"`
+	Inlined     map[dwarf.Offset][]IndexEntry `json:"inlined"`
+	Completions map[dwarf.Offset][]IndexEntry `json:"completions"`
+}
+
+// Rebuilds an index over this DWARF data from its saved contents
+//
+// Only the compile units are read, to check that the contents were saved
+// from this same DWARF; ErrUnsupportedForm is returned if they were not.
+func NewIndexFromData(data *dwarf.Data, d *IndexData) (*Index, error) {
+	r := data.Reader()
+	for _, cu := range d.CUs {
+		r.Seek(cu.Offset)
+		entry, err := r.Next()
+		if err != nil || entry == nil || entry.Tag != cu.Tag || entry.Val(dwarf.AttrName) != cu.Name {
+			return nil, fmt.Errorf("Saved index lists %s at %#x, which this DWARF does not: %w", cu.Name, cu.Offset, ErrUnsupportedForm)
+		}
+	}
+	idx := &Index{
+		data:        data,
+		cus:         d.CUs,
+		full:        true,
+		byName:      d.ByName,
+		byLinkage:   d.ByLinkage,
+		inlined:     d.Inlined,
+		completions: d.Completions,
+	}
+	if idx.cus == nil {
+		idx.cus = make([]IndexEntry, 0)
+	}
+	if idx.byName == nil {
+		idx.byName = make(map[string][]IndexEntry)
+	}
+	if idx.byLinkage == nil {
+		idx.byLinkage = make(map[string][]IndexEntry)
+	}
+	if idx.inlined == nil {
+		idx.inlined = make(map[dwarf.Offset][]IndexEntry)
+	}
+	if idx.completions == nil {
+		idx.completions = make(map[dwarf.Offset][]IndexEntry)
+	}
+	return idx, nil
+}
+
+// Returns the contents of this index for NewIndexFromData, or false if it
+// reads units lazily and has not yet read all of them
+//
+// The contents are shared with the index and must not be modified.
+func (idx *Index) Data() (*IndexData, bool) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	if !idx.full {
+		return nil, false
+	}
+	return &IndexData{
+		CUs:         idx.cus,
+		ByName:      idx.byName,
+		ByLinkage:   idx.byLinkage,
+		Inlined:     idx.inlined,
+		Completions: idx.completions,
+	}, true
+}
+
+// Builds an index that reads the units listed by an accelerator table as
+// their names are looked up
+func newAccelIndex(data *dwarf.Data, accel *accelTable) (*Index, error) {
+	idx := &Index{
+		data:   data,
+		accel:  accel,
+		cus:    make([]IndexEntry, 0, len(accel.cus)),
+		walked: make(map[dwarf.Offset]bool),
+	}
+	idx.reset()
+	r := data.Reader()
+	for _, cu := range accel.cus {
+		r.Seek(cu)
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := entry.Val(dwarf.AttrName).(string)
+		if entry.Tag == dwarf.TagCompileUnit && ok {
+			idx.cus = append(idx.cus, IndexEntry{Name: name, Offset: cu, Tag: entry.Tag, CU: cu})
+		}
+	}
+	return idx, nil
+}
+
+func (idx *Index) reset() {
+	idx.byName = make(map[string][]IndexEntry)
+	idx.byLinkage = make(map[string][]IndexEntry)
+	idx.inlined = make(map[dwarf.Offset][]IndexEntry)
+	idx.completions = make(map[dwarf.Offset][]IndexEntry)
+}
+
+// Indexes every unit, replacing whatever was indexed before
+func (idx *Index) walkAll() error {
+	if idx.full {
+		return nil
+	}
+	idx.reset()
+	cus := make([]IndexEntry, 0)
+	r := idx.data.Reader()
+	var cu dwarf.Offset
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag == dwarf.TagCompileUnit {
+			cu = entry.Offset
+			if name, ok := entry.Val(dwarf.AttrName).(string); ok {
+				cus = append(cus, IndexEntry{Name: name, Offset: cu, Tag: entry.Tag, CU: cu})
+			}
+		}
+		idx.add(entry, cu)
+	}
+	// With an accelerator table, the units were listed when it was built
+	if idx.accel == nil {
+		idx.cus = cus
+	}
+	idx.full = true
+	return nil
+}
+
+// Indexes the unit whose DIE is at this offset, unless it already is
+func (idx *Index) walkUnit(cu dwarf.Offset) error {
+	if idx.full || idx.walked[cu] {
+		return nil
+	}
+	idx.walked[cu] = true
+	r := idx.data.Reader()
+	r.Seek(cu)
+	for depth := 0; ; {
+		entry, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		if entry.Tag == 0 {
+			depth--
+		} else {
+			idx.add(entry, cu)
+			if entry.Children {
+				depth++
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// Makes sure every entry that may carry this name is indexed: those in the
+// units the accelerator table lists for it, or all of them if it lists none
+//
+// Units that cannot be read are left out of the index.
+func (idx *Index) need(name string) {
+	if idx.full {
+		return
+	}
+	cus, ok := idx.accel.lookup(name)
+	if !ok {
+		idx.walkAll()
+		return
+	}
+	for _, cu := range cus {
+		idx.walkUnit(cu)
+	}
+}
+
+// Returns the entries of the index in the order they appear in the DWARF,
+// which units walked one by one may not be in
+func (idx *Index) ordered(ies []IndexEntry) []IndexEntry {
+	if idx.full {
+		return ies
+	}
+	ret := append([]IndexEntry(nil), ies...)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Offset < ret[j].Offset })
+	return ret
+}
+
+// Adds an entry of the unit at offset cu to the index
+func (idx *Index) add(entry *dwarf.Entry, cu dwarf.Offset) {
+	// Inlined instances are unnamed and can only be found through the
+	// function they were inlined from
+	if entry.Tag == dwarf.TagInlinedSubroutine {
+		if origin, ok := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset); ok {
+			idx.inlined[origin] = append(idx.inlined[origin], IndexEntry{
+				Offset: entry.Offset,
+				Tag:    entry.Tag,
+				CU:     cu,
+			})
+		}
+	}
+	// Definitions completing a declaration are usually unnamed too
+	if entry.Tag == dwarf.TagVariable || entry.Tag == dwarf.TagSubprogram {
+		if decl, ok := origin(entry); ok {
+			idx.completions[decl] = append(idx.completions[decl], IndexEntry{
+				Offset: entry.Offset,
+				Tag:    entry.Tag,
+				CU:     cu,
+			})
+		}
+	}
+	name, ok := entry.Val(dwarf.AttrName).(string)
+	linkage := LinkageName(entry)
+	if !ok && linkage == "" {
+		return
+	}
+	ie := IndexEntry{
+		Name:   name,
+		Offset: entry.Offset,
+		Tag:    entry.Tag,
+		CU:     cu,
+	}
+	if linkage != "" {
+		if !ok {
+			ie.Name = linkage
+		}
+		idx.byLinkage[linkage] = append(idx.byLinkage[linkage], ie)
+	}
+	if ok {
+		idx.byName[name] = append(idx.byName[name], ie)
+	}
+}
+
+// Returns every entry with this name, in the order they appear in the DWARF
+//
+// Names that no entry carries as its DW_AT_name are looked up as symbols,
+// as by LookupSymbol.
+func (idx *Index) Lookup(name string) []IndexEntry {
+	idx.mux.Lock()
+	idx.need(name)
+	ies, ok := idx.byName[name]
+	ies = idx.ordered(ies)
+	idx.mux.Unlock()
+	if ok {
+		return ies
+	}
+	return idx.LookupSymbol(name)
+}
+
+// Returns every entry with this mangled linkage name or whose linkage name
+// demangles to this, as with or without its parameters
+//
+// Mangled names the DWARF does not record, such as those of clones made by
+// the optimizer, are demangled and looked up by their demangled forms, so
+// symbols copied from a backtrace or a log can be used as they are.
+func (idx *Index) LookupSymbol(name string) []IndexEntry {
+	linkage := trimMachOPrefix(name)
+	idx.mux.Lock()
+	idx.need(linkage)
+	ies, ok := idx.byLinkage[linkage]
+	ies = idx.ordered(ies)
+	idx.mux.Unlock()
+	if ok {
+		return ies
+	}
+	demangled := idx.demangled()
+	if ies, ok := demangled[name]; ok {
+		return ies
+	}
+	if full := Demangle(name); full != name {
+		if ies, ok := demangled[full]; ok {
+			return ies
+		}
+		if short, ok := demangleNoParams(name); ok {
+			return demangled[short]
+		}
+	}
+	return nil
+}
+
+// Returns the entries keyed by the demangled forms of their linkage names,
+// demangling them the first time they are needed
+func (idx *Index) demangled() map[string][]IndexEntry {
+	idx.demangledOnce.Do(func() {
+		idx.mux.Lock()
+		defer idx.mux.Unlock()
+		idx.walkAll()
+		idx.byDemangled = make(map[string][]IndexEntry)
+		for linkage, ies := range idx.byLinkage {
+			full := Demangle(linkage)
+			if full == linkage {
+				continue
+			}
+			idx.byDemangled[full] = append(idx.byDemangled[full], ies...)
+			if short, ok := demangleNoParams(linkage); ok && short != full {
+				idx.byDemangled[short] = append(idx.byDemangled[short], ies...)
+			}
+		}
+		// Overloads share a name without parameters; list them in DWARF order
+		for _, ies := range idx.byDemangled {
+			sort.Slice(ies, func(i, j int) bool { return ies[i].Offset < ies[j].Offset })
+		}
+	})
+	return idx.byDemangled
+}
+
+// Returns every entry with this name and tag
+func (idx *Index) LookupTag(name string, tag dwarf.Tag) []IndexEntry {
+	return idx.filter(name, func(ie IndexEntry) bool {
+		return ie.Tag == tag
+	})
+}
+
+// Returns every entry with this name inside the compile unit at offset cu
+func (idx *Index) LookupInCU(name string, cu dwarf.Offset) []IndexEntry {
+	return idx.filter(name, func(ie IndexEntry) bool {
+		return ie.CU == cu
+	})
+}
+
+func (idx *Index) filter(name string, f func(IndexEntry) bool) []IndexEntry {
+	entries := make([]IndexEntry, 0)
+	for _, ie := range idx.Lookup(name) {
+		if f(ie) {
+			entries = append(entries, ie)
+		}
+	}
+	return entries
+}
+
+// Returns the named compile units in this DWARF
+func (idx *Index) CUs() []IndexEntry {
+	return idx.cus
+}
+
+// Returns the inlined instances of the function whose entry is at this
+// offset
+func (idx *Index) Inlined(origin dwarf.Offset) []IndexEntry {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	// An abstract instance may be inlined into any unit
+	idx.walkAll()
+	return idx.inlined[origin]
+}
+
+// Returns the entries completing the declaration or abstract instance at
+// this offset, which are looked for in the unit holding it
+func (idx *Index) completing(decl dwarf.Offset) []IndexEntry {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	if !idx.full {
+		idx.walkUnit(idx.unitOf(decl))
+	}
+	return idx.completions[decl]
+}
+
+// Returns the offset of the unit holding the entry at this offset
+func (idx *Index) unitOf(off dwarf.Offset) dwarf.Offset {
+	cus := idx.accel.cus
+	i := sort.Search(len(cus), func(i int) bool { return cus[i] > off })
+	if i == 0 {
+		return 0
+	}
+	return cus[i-1]
+}
+
+// Reads the entry referenced by this index entry
+func (idx *Index) Entry(ie IndexEntry) (*dwarf.Entry, error) {
+	r := idx.data.Reader()
+	r.Seek(ie.Offset)
+	return r.Next()
+}
+
+// Searches for an entry matching a requested name
+//
+// Behaves like GetEntry, returning the first matching entry along with the
+// compile unit containing it, but never walks the DWARF. Declarations are
+// completed with their definitions, as by Complete.
+func (idx *Index) GetEntry(name string) (*dwarf.Entry, *dwarf.Entry, error) {
+	matches := idx.Lookup(name)
+	if len(matches) == 0 {
+		return nil, nil, fmt.Errorf("Could not find entry %v: %w", name, ErrNotFound)
+	}
+	return idx.resolve(matches[0])
+}
+
+// Searches for an entry matching a requested name, preferring entries inside
+// the compile unit at offset cu over those elsewhere in the DWARF
+func (idx *Index) GetEntryInCU(name string, cu dwarf.Offset) (*dwarf.Entry, *dwarf.Entry, error) {
+	matches := idx.LookupInCU(name, cu)
+	if len(matches) == 0 {
+		return idx.GetEntry(name)
+	}
+	return idx.resolve(matches[0])
+}
+
+// Reads both the entry and the compile unit it belongs to
+func (idx *Index) resolve(ie IndexEntry) (*dwarf.Entry, *dwarf.Entry, error) {
+	r := idx.data.Reader()
+	r.Seek(ie.CU)
+	cu, err := r.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ie.Offset == ie.CU {
+		return cu, cu, nil
+	}
+	r.Seek(ie.Offset)
+	entry, err := r.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err = idx.Complete(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, cu, nil
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIndex(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	idx, err := NewIndex(data)
+	assert.NoError(t, err)
+
+	cus := idx.CUs()
+	assert.Equal(t, 1, len(cus))
+	assert.Equal(t, "testcase.cpp", cus[0].Name)
+
+	teams := idx.Lookup("formula_1_teams")
+	assert.Equal(t, 1, len(teams))
+	assert.Equal(t, dwarf.TagVariable, teams[0].Tag)
+	assert.Equal(t, cus[0].Offset, teams[0].CU)
+
+	// has_won_wdc is a member of both Driver and Team
+	assert.Equal(t, 2, len(idx.Lookup("has_won_wdc")))
+	assert.Equal(t, 1, len(idx.LookupTag("Driver", dwarf.TagStructType)))
+	assert.Equal(t, 0, len(idx.LookupTag("Driver", dwarf.TagVariable)))
+	assert.Equal(t, 1, len(idx.LookupInCU("Team", cus[0].Offset)))
+	assert.Equal(t, 0, len(idx.Lookup("badname")))
+}
+
+func TestIndexGetEntry(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	idx, err := NewIndex(data)
+	assert.NoError(t, err)
+
+	for _, name := range []string{"formula_1_teams", "testcase.cpp", "Driver", "drivers", "char"} {
+		expected, _, err := GetEntry(reader, name)
+		assert.NoError(t, err)
+		entry, cu, err := idx.GetEntry(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, entry)
+		assert.Equal(t, "testcase.cpp", cu.Val(dwarf.AttrName))
+	}
+
+	_, _, err = idx.GetEntry("badname")
+	assert.Error(t, err)
+
+	entry, err := idx.Entry(idx.Lookup("Team")[0])
+	assert.NoError(t, err)
+	assert.Equal(t, dwarf.TagStructType, entry.Tag)
+}
+
+func TestIndexFromData(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	idx, err := NewIndex(data)
+	assert.NoError(t, err)
+	d, ok := idx.Data()
+	assert.True(t, ok)
+
+	rebuilt, err := NewIndexFromData(data, d)
+	assert.NoError(t, err)
+	assert.Equal(t, idx.CUs(), rebuilt.CUs())
+	assert.Equal(t, idx.Lookup("formula_1_teams"), rebuilt.Lookup("formula_1_teams"))
+	assert.Equal(t, idx.Lookup("has_won_wdc"), rebuilt.Lookup("has_won_wdc"))
+
+	// Contents saved from other DWARF are rejected
+	moved := *d
+	moved.CUs = []IndexEntry{{Name: "testcase.cpp", Offset: d.CUs[0].Offset + 1, Tag: dwarf.TagCompileUnit}}
+	_, err = NewIndexFromData(data, &moved)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	moved.CUs = []IndexEntry{{Name: "other.cpp", Offset: d.CUs[0].Offset, Tag: dwarf.TagCompileUnit}}
+	_, err = NewIndexFromData(data, &moved)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+
+	// An index reading units lazily has nothing to save until it has read
+	// them all
+	idx = loadAccel(t).Index()
+	_, ok = idx.Data()
+	assert.False(t, ok)
+	idx.Lookup("nosuchname")
+	_, ok = idx.Data()
+	assert.True(t, ok)
+}
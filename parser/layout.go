@@ -0,0 +1,423 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// The size in bytes of a cacheline on most current CPUs
+const DefaultCachelineSize = 64
+
+// DW_ATE_complex_float, whose alignment is that of one of its two halves
+const encComplexFloat = 0x03
+
+// The kinds of row in a layout table
+type LayoutRowKind int
+
+const (
+	// A member of the type, or a base class
+	LayoutMember LayoutRowKind = iota
+	// Padding between members
+	LayoutHole
+	// Padding after the last member, up to the size of the type
+	LayoutPadding
+	// The start of a cacheline, which takes no space
+	LayoutCacheline
+)
+
+func (k LayoutRowKind) String() string {
+	switch k {
+	case LayoutMember:
+		return "member"
+	case LayoutHole:
+		return "hole"
+	case LayoutPadding:
+		return "padding"
+	case LayoutCacheline:
+		return "cacheline"
+	default:
+		return "unknown"
+	}
+}
+
+// Encodes the kind by name, as in JSON
+func (k LayoutRowKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Decodes a kind encoded by MarshalText
+func (k *LayoutRowKind) UnmarshalText(b []byte) error {
+	for _, kind := range []LayoutRowKind{LayoutMember, LayoutHole, LayoutPadding, LayoutCacheline} {
+		if kind.String() == string(b) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown layout row kind %q: %w", b, ErrBadValue)
+}
+
+// A row of a layout table
+type LayoutRow struct {
+	Kind LayoutRowKind `json:"kind"`
+	// The offset from the start of the type and the size, in bits so that
+	// bit fields can be placed
+	BitOffset int `json:"bit_offset"`
+	BitSize   int `json:"bit_size"`
+	// The alignment in bytes of the member's type, or 0 for other rows and
+	// for proxies not built from DWARF
+	Align int    `json:"align,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// The layout of a type in memory: where each member lies, where the
+// padding is and where cachelines start, as from pahole
+type Layout struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type,omitempty"`
+	BitSize       int         `json:"bit_size"`
+	Align         int         `json:"align,omitempty"`
+	CachelineSize int         `json:"cacheline_size"`
+	Rows          []LayoutRow `json:"rows"`
+
+	Members     int `json:"members"`
+	Holes       int `json:"holes"`
+	HoleBits    int `json:"hole_bits"`
+	PaddingBits int `json:"padding_bits"`
+	Cachelines  int `json:"cachelines"`
+}
+
+// Lays out this type in cachelines of this many bytes, or of
+// DefaultCachelineSize if it is 0
+//
+// Members are listed in declaration order, with a hole wherever a member
+// starts past the end of those before it and trailing padding up to the
+// size of the type. A nested struct is one row; lay out its type to see
+// inside it. Arrays are laid out by element, as BitSize gives their size.
+//
+// Alignment is taken from DW_AT_alignment, or else is that of the type's
+// natural alignment. DWARF does not say whether a struct is packed, so a
+// struct whose size or member offsets are not multiples of the alignment
+// of its members is taken to be packed, with alignment 1.
+func (p TypeDefProxy) Layout(cachelineSize int) (*Layout, error) {
+	if cachelineSize <= 0 {
+		cachelineSize = DefaultCachelineSize
+	}
+	l := &Layout{Name: p.name, BitSize: p.bitSize, CachelineSize: cachelineSize}
+	var members []LayoutRow
+	var err error
+	if p.graph == nil {
+		members, err = p.proxyRows()
+	} else {
+		members, err = p.entryRows(l)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line := -1
+	add := func(row LayoutRow) {
+		if n := row.BitOffset / (cachelineSize * 8); n > line {
+			if n > 0 {
+				l.Rows = append(l.Rows, LayoutRow{Kind: LayoutCacheline, BitOffset: n * cachelineSize * 8})
+			}
+			line = n
+		}
+		l.Rows = append(l.Rows, row)
+	}
+	end := 0
+	for _, row := range members {
+		if row.BitOffset > end {
+			add(LayoutRow{Kind: LayoutHole, BitOffset: end, BitSize: row.BitOffset - end})
+			l.Holes++
+			l.HoleBits += row.BitOffset - end
+		}
+		add(row)
+		l.Members++
+		// Members of a union overlap, so the end is the furthest any reaches
+		if e := row.BitOffset + row.BitSize; e > end {
+			end = e
+		}
+	}
+	if len(members) > 0 && l.BitSize > end {
+		add(LayoutRow{Kind: LayoutPadding, BitOffset: end, BitSize: l.BitSize - end})
+		l.PaddingBits = l.BitSize - end
+	}
+	l.Cachelines = (l.BitSize/8 + cachelineSize - 1) / cachelineSize
+	return l, nil
+}
+
+// Returns a row for each member of a proxy that only knows its members'
+// names, offsets and sizes, as when decoded from JSON
+func (p TypeDefProxy) proxyRows() ([]LayoutRow, error) {
+	children, err := p.children()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]LayoutRow, 0, len(children))
+	for _, c := range children {
+		rows = append(rows, LayoutRow{
+			Kind:      LayoutMember,
+			BitOffset: c.structOffset,
+			BitSize:   c.bitSize * numElements(c.dims()),
+			Name:      c.name,
+		})
+	}
+	return rows, nil
+}
+
+// Returns a row for each member and base class of the type's entry, filling
+// in the type and alignment of the layout itself
+func (p TypeDefProxy) entryRows(l *Layout) ([]LayoutRow, error) {
+	r := p.graph.data.Reader()
+	e, err := entryAt(r, p.entryOffset)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Tag {
+	case dwarf.TagVariable, dwarf.TagMember, dwarf.TagFormalParameter:
+		l.Type = TypeName(r, e)
+	default:
+		l.Type = EntryName(e)
+	}
+	t, err := entryAt(r, p.typeOffset)
+	if err != nil {
+		return nil, err
+	}
+	l.Align = alignOf(r, t, 0)
+	rows, _, err := p.graph.memberRows(r, t)
+	return rows, err
+}
+
+// Returns a row for each member and base class of a type entry, along with
+// the entry of each
+func (g *TypeGraph) memberRows(r *dwarf.Reader, t *dwarf.Entry) ([]LayoutRow, []*dwarf.Entry, error) {
+	entries, err := layoutMembers(r, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := make([]LayoutRow, 0, len(entries))
+	for _, m := range entries {
+		mp, err := g.typeDefProxy(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		row := LayoutRow{
+			Kind:      LayoutMember,
+			BitOffset: mp.structOffset,
+			BitSize:   mp.bitSize * numElements(mp.dims()),
+			Align:     alignOf(r, m, 0),
+			Name:      EntryName(m),
+			Type:      TypeName(r, m),
+		}
+		row.BitOffset, row.BitSize = bitField(m, row.BitOffset, row.BitSize, r.ByteOrder())
+		rows = append(rows, row)
+	}
+	return rows, entries, nil
+}
+
+// Returns the entry at this offset
+func entryAt(r *dwarf.Reader, offset dwarf.Offset) (*dwarf.Entry, error) {
+	r.Seek(offset)
+	e, err := r.Next()
+	if err == nil && e == nil {
+		err = fmt.Errorf("No entry at offset %#x: %w", offset, ErrNotFound)
+	}
+	return e, err
+}
+
+// Returns the children of a type entry that take space in it: its data
+// members and base classes, leaving out static members
+func layoutMembers(r *dwarf.Reader, t *dwarf.Entry) ([]*dwarf.Entry, error) {
+	members := make([]*dwarf.Entry, 0)
+	if !t.Children {
+		return members, nil
+	}
+	r.Seek(t.Offset)
+	if _, err := r.Next(); err != nil {
+		return nil, err
+	}
+	for {
+		child, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil || child.Tag == 0 {
+			return members, nil
+		}
+		r.SkipChildren()
+		switch {
+		case child.Tag == dwarf.TagInheritance:
+			members = append(members, child)
+		case child.Tag == dwarf.TagMember && !HasAttr(child, dwarf.AttrDeclaration) && !HasAttr(child, dwarf.AttrExternal):
+			members = append(members, child)
+		}
+	}
+}
+
+// Places a bit field, which DWARF 4 and later give by DW_AT_data_bit_offset
+// from the start of the struct and earlier versions by DW_AT_bit_offset from
+// the most significant bit of a storage unit at DW_AT_data_member_location.
+// Other members are returned unchanged.
+func bitField(m *dwarf.Entry, offset int, size int, order binary.ByteOrder) (int, int) {
+	bits, ok := m.Val(dwarf.AttrBitSize).(int64)
+	if !ok {
+		return offset, size
+	}
+	if off, ok := m.Val(dwarf.AttrDataBitOffset).(int64); ok {
+		return int(off), int(bits)
+	}
+	if off, ok := m.Val(dwarf.AttrBitOffset).(int64); ok {
+		if storage, ok := m.Val(dwarf.AttrByteSize).(int64); ok {
+			size = int(storage) * 8
+		}
+		if order == binary.LittleEndian {
+			return offset + size - int(off) - int(bits), int(bits)
+		}
+		return offset + int(off), int(bits)
+	}
+	return offset, int(bits)
+}
+
+// Returns the alignment in bytes of a type entry, or of the type of any
+// other entry
+func alignOf(r *dwarf.Reader, e *dwarf.Entry, depth int) int {
+	if depth > maxTypeDepth {
+		return 1
+	}
+	if a, ok := e.Val(dwarf.AttrAlignment).(int64); ok && a > 0 {
+		return int(a)
+	}
+	switch e.Tag {
+	case dwarf.TagStructType, dwarf.TagClassType, dwarf.TagUnionType:
+		return structAlign(r, e, depth)
+	case dwarf.TagBaseType, dwarf.TagPointerType, dwarf.TagReferenceType, dwarf.TagRvalueReferenceType, dwarf.TagPtrToMemberType:
+		size, _ := e.Val(dwarf.AttrByteSize).(int64)
+		if enc, _ := e.Val(dwarf.AttrEncoding).(int64); enc == encComplexFloat {
+			size /= 2
+		}
+		return naturalAlign(int(size))
+	case dwarf.TagEnumerationType:
+		if size, ok := e.Val(dwarf.AttrByteSize).(int64); ok {
+			return naturalAlign(int(size))
+		}
+	}
+	if !HasAttr(e, dwarf.AttrType) {
+		return 1
+	}
+	t, err := GetTypeEntry(r, e)
+	if err != nil {
+		return 1
+	}
+	return alignOf(r, t, depth+1)
+}
+
+// Returns the largest alignment of the members of a struct, class or
+// union, or 1 if they show it is packed
+func structAlign(r *dwarf.Reader, t *dwarf.Entry, depth int) int {
+	members, err := layoutMembers(r, t)
+	if err != nil {
+		return 1
+	}
+	align := 1
+	for _, m := range members {
+		a := alignOf(r, m, depth+1)
+		if HasAttr(m, dwarf.AttrDataMemberLoc) && !HasAttr(m, dwarf.AttrBitSize) {
+			if off, err := GetMemberOffset(m); err == nil && off%a != 0 {
+				return 1
+			}
+		}
+		if a > align {
+			align = a
+		}
+	}
+	if size, ok := t.Val(dwarf.AttrByteSize).(int64); ok && int(size)%align != 0 {
+		return 1
+	}
+	return align
+}
+
+// Returns the largest power of two dividing a size, which is the alignment
+// of scalars such as the 12 byte long double of i386
+func naturalAlign(size int) int {
+	if size <= 0 {
+		return 1
+	}
+	return size & -size
+}
+
+// Writes a number of bits as bytes, or as bytes:bits if it is not a whole
+// number of bytes
+func formatBits(bits int) string {
+	if bits%8 == 0 {
+		return fmt.Sprintf("%d", bits/8)
+	}
+	return fmt.Sprintf("%d:%d", bits/8, bits%8)
+}
+
+// Renders the layout as a table of the offset, size, alignment, name and
+// type of each row, with a summary of the totals
+//
+// Offsets and sizes are in bytes, written bytes:bits for bit fields.
+func (l Layout) String() string {
+	var sb strings.Builder
+	if l.Type != "" && l.Type != l.Name {
+		fmt.Fprintf(&sb, "%s %s", l.Type, l.Name)
+	} else {
+		sb.WriteString(l.Name)
+	}
+	fmt.Fprintf(&sb, ": size %s", formatBits(l.BitSize))
+	if l.Align > 0 {
+		fmt.Fprintf(&sb, ", align %d", l.Align)
+	}
+	sb.WriteString("\n")
+
+	// Cacheline boundaries are written over placeholder rows once the
+	// columns are aligned, so that they do not widen them
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "OFFSET\tSIZE\tALIGN\tNAME\tTYPE")
+	for _, row := range l.Rows {
+		switch row.Kind {
+		case LayoutMember:
+			align := ""
+			if row.Align > 0 {
+				align = fmt.Sprintf("%d", row.Align)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", formatBits(row.BitOffset), formatBits(row.BitSize), align, row.Name, row.Type)
+		case LayoutHole:
+			fmt.Fprintf(tw, "%s\t%s\t\t<hole>\t\n", formatBits(row.BitOffset), formatBits(row.BitSize))
+		case LayoutPadding:
+			fmt.Fprintf(tw, "%s\t%s\t\t<padding>\t\n", formatBits(row.BitOffset), formatBits(row.BitSize))
+		default:
+			fmt.Fprintln(tw, "\t\t\t\t")
+		}
+	}
+	tw.Flush()
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	indent := strings.Repeat(" ", strings.Index(lines[0], "NAME"))
+	for i, line := range lines {
+		if i > 0 && l.Rows[i-1].Kind == LayoutCacheline {
+			line = indent + l.boundary(i-1)
+		}
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	fmt.Fprintf(&sb, "size: %s, members: %d, holes: %d, sum holes: %s, padding: %s, cachelines: %d (%d bytes)\n",
+		formatBits(l.BitSize), l.Members, l.Holes, formatBits(l.HoleBits), formatBits(l.PaddingBits), l.Cachelines, l.CachelineSize)
+	return sb.String()
+}
+
+// Describes the cacheline boundary in this row, and how far back it fell
+// if the row before straddles it
+func (l Layout) boundary(i int) string {
+	row := l.Rows[i]
+	str := fmt.Sprintf("--- cacheline %d boundary (%d bytes)", row.BitOffset/8/l.CachelineSize, row.BitOffset/8)
+	if i+1 < len(l.Rows) && l.Rows[i+1].BitOffset > row.BitOffset {
+		str += fmt.Sprintf(" was %s bytes ago", formatBits(l.Rows[i+1].BitOffset-row.BitOffset))
+	}
+	return str + " ---"
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+func baseType(name string, size int) *dwarftest.DIE {
+	return dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: name},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: size})
+}
+
+func member(name string, t *dwarftest.DIE, offset int, attrs ...dwarftest.Attr) *dwarftest.DIE {
+	attrs = append([]dwarftest.Attr{
+		{Attr: dwarf.AttrName, Val: name},
+		{Attr: dwarf.AttrType, Val: t},
+	}, attrs...)
+	if offset >= 0 {
+		attrs = append(attrs, dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: offset})
+	}
+	return dwarftest.New(dwarf.TagMember, attrs...)
+}
+
+func structType(name string, size int, members ...*dwarftest.DIE) *dwarftest.DIE {
+	return dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: name},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: size},
+	).With(members...)
+}
+
+// Builds DWARF for:
+//
+//	struct Packet {
+//	  char tag;
+//	  int len;
+//	  unsigned flags : 3;
+//	  short id;
+//	  char data[50];
+//	  double crc;
+//	};
+//	struct Team {
+//	  int points;
+//	  unsigned short car;
+//	  char name[8];
+//	};
+//	struct __attribute__((packed)) Header {
+//	  char tag;
+//	  int len;
+//	};
+func buildLayouts(t *testing.T) (*dwarf.Data, *TypeGraph) {
+	charType := baseType("char", 1)
+	intType := baseType("int", 4)
+	unsignedType := baseType("unsigned int", 4)
+	shortType := baseType("short", 2)
+	ushortType := baseType("unsigned short", 2)
+	doubleType := baseType("double", 8)
+	data50 := dwarftest.New(dwarf.TagArrayType,
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: charType},
+	).With(dwarftest.New(dwarf.TagSubrangeType, dwarftest.Attr{Attr: dwarf.AttrCount, Val: 50}))
+	name8 := dwarftest.New(dwarf.TagArrayType,
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: charType},
+	).With(dwarftest.New(dwarf.TagSubrangeType, dwarftest.Attr{Attr: dwarf.AttrCount, Val: 8}))
+	packet := structType("Packet", 72,
+		member("tag", charType, 0),
+		member("len", intType, 4),
+		member("flags", unsignedType, -1,
+			dwarftest.Attr{Attr: dwarf.AttrBitSize, Val: 3},
+			dwarftest.Attr{Attr: dwarf.AttrDataBitOffset, Val: 64}),
+		member("id", shortType, 10),
+		member("data", data50, 12),
+		member("crc", doubleType, 64))
+	team := structType("Team", 16,
+		member("points", intType, 0),
+		member("car", ushortType, 4),
+		member("name", name8, 6))
+	header := structType("Header", 5,
+		member("tag", charType, 0),
+		member("len", intType, 1))
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "layout.c"},
+	).With(charType, intType, unsignedType, shortType, ushortType, doubleType, data50, name8, packet, team, header)
+	data, err := dwarftest.Build(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, NewTypeGraph(data)
+}
+
+func layoutOf(t *testing.T, data *dwarf.Data, g *TypeGraph, name string) *Layout {
+	e, _, err := GetEntry(data.Reader(), name)
+	assert.NoError(t, err)
+	p, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	l, err := p.Layout(0)
+	assert.NoError(t, err)
+	return l
+}
+
+func TestLayout(t *testing.T) {
+	data, g := buildLayouts(t)
+	l := layoutOf(t, data, g, "Packet")
+	assert.Equal(t, 8, l.Align)
+	assert.Equal(t, DefaultCachelineSize, l.CachelineSize)
+	assert.Equal(t, []LayoutRow{
+		{Kind: LayoutMember, BitOffset: 0, BitSize: 8, Align: 1, Name: "tag", Type: "char"},
+		{Kind: LayoutHole, BitOffset: 8, BitSize: 24},
+		{Kind: LayoutMember, BitOffset: 32, BitSize: 32, Align: 4, Name: "len", Type: "int"},
+		{Kind: LayoutMember, BitOffset: 64, BitSize: 3, Align: 4, Name: "flags", Type: "unsigned int"},
+		{Kind: LayoutHole, BitOffset: 67, BitSize: 13},
+		{Kind: LayoutMember, BitOffset: 80, BitSize: 16, Align: 2, Name: "id", Type: "short"},
+		{Kind: LayoutMember, BitOffset: 96, BitSize: 400, Align: 1, Name: "data", Type: "char[50]"},
+		{Kind: LayoutHole, BitOffset: 496, BitSize: 16},
+		{Kind: LayoutCacheline, BitOffset: 512},
+		{Kind: LayoutMember, BitOffset: 512, BitSize: 64, Align: 8, Name: "crc", Type: "double"},
+	}, l.Rows)
+	assert.Equal(t, 6, l.Members)
+	assert.Equal(t, 3, l.Holes)
+	assert.Equal(t, 24+13+16, l.HoleBits)
+	assert.Equal(t, 0, l.PaddingBits)
+	assert.Equal(t, 2, l.Cachelines)
+
+	s := l.String()
+	assert.True(t, strings.HasPrefix(s, "Packet: size 72, align 8\n"), s)
+	assert.Contains(t, s, "8:3")
+	assert.Contains(t, s, "<hole>")
+	assert.Contains(t, s, "--- cacheline 1 boundary (64 bytes) ---")
+	assert.Contains(t, s, "size: 72, members: 6, holes: 3, sum holes: 6:5, padding: 0, cachelines: 2 (64 bytes)")
+
+	l = layoutOf(t, data, g, "Team")
+	assert.Equal(t, 4, l.Align)
+	assert.Equal(t, LayoutRow{Kind: LayoutPadding, BitOffset: 112, BitSize: 16}, l.Rows[len(l.Rows)-1])
+	assert.Equal(t, 16, l.PaddingBits)
+	assert.Contains(t, l.String(), "<padding>")
+
+	// A member off its alignment shows the struct is packed
+	l = layoutOf(t, data, g, "Header")
+	assert.Equal(t, 1, l.Align)
+	assert.Equal(t, 0, l.Holes)
+}
+
+func TestLayoutCachelineStraddle(t *testing.T) {
+	data, g := buildLayouts(t)
+	e, _, err := GetEntry(data.Reader(), "Packet")
+	assert.NoError(t, err)
+	p, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	l, err := p.Layout(32)
+	assert.NoError(t, err)
+	// data spans bytes 12 to 62, so the boundary at 32 falls inside it
+	assert.Equal(t, LayoutRow{Kind: LayoutCacheline, BitOffset: 32 * 8}, l.Rows[7])
+	assert.Contains(t, l.String(), "--- cacheline 1 boundary (32 bytes) was 30 bytes ago ---")
+	assert.Equal(t, 3, l.Cachelines)
+}
+
+func TestLayoutFromJSON(t *testing.T) {
+	data, g := buildLayouts(t)
+	e, _, err := GetEntry(data.Reader(), "Team")
+	assert.NoError(t, err)
+	p, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	b, err := json.Marshal(p)
+	assert.NoError(t, err)
+	var decoded TypeDefProxy
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+
+	// Without the DWARF, types and alignments are unknown
+	l, err := decoded.Layout(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, l.Align)
+	assert.Equal(t, LayoutRow{Kind: LayoutMember, BitOffset: 48, BitSize: 64, Name: "name"}, l.Rows[2])
+	assert.Equal(t, 16, l.PaddingBits)
+}
@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A SourceLine is a position in the source along with the address of the
+// first instruction generated for it
+type SourceLine struct {
+	File    string
+	Line    int
+	Column  int
+	Address uint64
+}
+
+func (l SourceLine) String() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// The addresses covered by one compile unit
+type cuRanges struct {
+	offset dwarf.Offset
+	ranges [][2]uint64
+}
+
+// Returns the address ranges of every compile unit, reading them the first
+// time they are needed
+func (p *Program) cuRanges() ([]cuRanges, error) {
+	p.cuRangesOnce.Do(func() {
+		r := p.data.Reader()
+		for {
+			cu, err := r.Next()
+			if err != nil {
+				p.cuRangesErr = err
+				return
+			}
+			if cu == nil {
+				return
+			}
+			ranges, err := p.data.Ranges(cu)
+			if err != nil {
+				p.cuRangesErr = newEntryError(cu, err)
+				return
+			}
+			p.cuRangesList = append(p.cuRangesList, cuRanges{cu.Offset, ranges})
+			r.SkipChildren()
+		}
+	})
+	return p.cuRangesList, p.cuRangesErr
+}
+
+// Returns a reader over the line table of the compile unit at this offset
+func (p *Program) lineReader(cu dwarf.Offset) (*dwarf.LineReader, error) {
+	r := p.data.Reader()
+	r.Seek(cu)
+	entry, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || entry.Tag != dwarf.TagCompileUnit {
+		return nil, fmt.Errorf("No compile unit at offset %#x: %w", cu, ErrNotFound)
+	}
+	lr, err := p.data.LineReader(entry)
+	if err != nil {
+		return nil, newEntryError(entry, err)
+	}
+	if lr == nil {
+		return nil, newEntryError(entry, fmt.Errorf("line table: %w", ErrNotFound))
+	}
+	return lr, nil
+}
+
+// Returns the source files of the compile unit at this offset, in the order
+// of its line table
+//
+// Files are numbered from 1 before DWARF 5, so the first file of such a unit
+// is the empty string.
+func (p *Program) Files(cu dwarf.Offset) ([]string, error) {
+	lr, err := p.lineReader(cu)
+	if err != nil {
+		return nil, err
+	}
+	files := lr.Files()
+	ret := make([]string, len(files))
+	for i, f := range files {
+		if f != nil {
+			ret[i] = f.Name
+		}
+	}
+	return ret, nil
+}
+
+// Returns the source line containing the instruction at this address
+func (p *Program) AddrToLine(pc uint64) (SourceLine, error) {
+	cus, err := p.cuRanges()
+	if err != nil {
+		return SourceLine{}, err
+	}
+	for _, cu := range cus {
+		if !inRanges(cu.ranges, pc) {
+			continue
+		}
+		lr, err := p.lineReader(cu.offset)
+		if err != nil {
+			return SourceLine{}, err
+		}
+		var entry dwarf.LineEntry
+		if err := lr.SeekPC(pc, &entry); err != nil {
+			// Ranges of units may overlap, so another may hold the line
+			if errors.Is(err, dwarf.ErrUnknownPC) {
+				continue
+			}
+			return SourceLine{}, err
+		}
+		return toSourceLine(entry), nil
+	}
+	return SourceLine{}, fmt.Errorf("No source line for address %#x: %w", pc, ErrNotFound)
+}
+
+// Returns the addresses of the instructions marked as starting this line of
+// this file, in ascending order
+//
+// The file may be given as a full path or by a trailing part of its path,
+// so "main.c" and "src/main.c" both match "/home/me/src/main.c".
+func (p *Program) LineToAddrs(file string, line int) ([]uint64, error) {
+	cus, err := p.cuRanges()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uint64]bool)
+	ret := make([]uint64, 0)
+	for _, cu := range cus {
+		lr, err := p.lineReader(cu.offset)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var entry dwarf.LineEntry
+		for {
+			if err := lr.Next(&entry); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, err
+			}
+			if entry.EndSequence || !entry.IsStmt || entry.Line != line || entry.File == nil {
+				continue
+			}
+			if !matchesFile(entry.File.Name, file) || seen[entry.Address] {
+				continue
+			}
+			seen[entry.Address] = true
+			ret = append(ret, entry.Address)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("No instructions for %s:%d: %w", file, line, ErrNotFound)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret, nil
+}
+
+func toSourceLine(entry dwarf.LineEntry) SourceLine {
+	l := SourceLine{
+		Line:    entry.Line,
+		Column:  entry.Column,
+		Address: entry.Address,
+	}
+	if entry.File != nil {
+		l.File = entry.File.Name
+	}
+	return l
+}
+
+// Returns true if name is file or ends with file following a slash
+func matchesFile(name string, file string) bool {
+	return name == file || strings.HasSuffix(name, "/"+strings.TrimPrefix(file, "/"))
+}
+
+func inRanges(ranges [][2]uint64, pc uint64) bool {
+	for _, r := range ranges {
+		if pc >= r[0] && pc < r[1] {
+			return true
+		}
+	}
+	return false
+}
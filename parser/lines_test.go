@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+func TestLineTable(t *testing.T) {
+	data, err := getDataFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	prog, err := NewProgram(data)
+	assert.NoError(t, err)
+
+	l, err := prog.AddrToLine(0x401114)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(l.File, "testcase.cpp"), l.File)
+	assert.Equal(t, 30, l.Line)
+	assert.Equal(t, uint64(0x401110), l.Address)
+	_, err = prog.AddrToLine(0x401118)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = prog.AddrToLine(0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	addrs, err := prog.LineToAddrs("testcase.cpp", 29)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0x401110}, addrs)
+	addrs, err = prog.LineToAddrs(l.File, 30)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0x401110}, addrs)
+	_, err = prog.LineToAddrs("testcase.cpp", 31)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = prog.LineToAddrs("case.cpp", 29)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	cu := prog.Index().CUs()[0]
+	files, err := prog.Files(cu.Offset)
+	assert.NoError(t, err)
+	assert.Contains(t, files, l.File)
+	_, err = prog.Files(cu.Offset + 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAddrToLineOverlappingUnits(t *testing.T) {
+	// a.c claims all of 0x1000-0x1100, but its lines stop at 0x1020
+	a := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "a.c"},
+		dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x1000)},
+		dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x100},
+	).Lines("a.c", 0x1020, dwarftest.Row{Address: 0x1000, Line: 3})
+	b := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "b.c"},
+		dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x1040)},
+		dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x20},
+	).Lines("b.c", 0x1060, dwarftest.Row{Address: 0x1040, Line: 7}, dwarftest.Row{Address: 0x1048, Line: 8})
+	data, err := dwarftest.Build(a, b)
+	assert.NoError(t, err)
+	prog, err := NewProgram(data)
+	assert.NoError(t, err)
+
+	l, err := prog.AddrToLine(0x1010)
+	assert.NoError(t, err)
+	assert.Equal(t, "a.c", l.File)
+	assert.Equal(t, 3, l.Line)
+	l, err = prog.AddrToLine(0x104c)
+	assert.NoError(t, err)
+	assert.Equal(t, "b.c", l.File)
+	assert.Equal(t, 8, l.Line)
+	_, err = prog.AddrToLine(0x1030)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,318 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// Registers holds the values of a thread's registers, keyed by their DWARF
+// register number, which is defined by the ABI of each architecture. For
+// example on x86-64 register 6 is rbp and 7 is rsp.
+type Registers map[int]uint64
+
+// The key under which callers that have unwound the stack may supply the
+// canonical frame address, for frame bases given as DW_OP_call_frame_cfa
+const RegCFA = -1
+
+// Returns the value of register n
+func (r Registers) get(n int) (uint64, error) {
+	v, ok := r[n]
+	if !ok {
+		if n == RegCFA {
+			return 0, fmt.Errorf("Canonical frame address: %w", ErrNoRegister)
+		}
+		return 0, fmt.Errorf("Register %d: %w", n, ErrNoRegister)
+	}
+	return v, nil
+}
+
+// More DWARF expression operations, used by locations relative to
+// registers and frames
+const (
+	opConst1u      = 0x08
+	opConst1s      = 0x09
+	opConst2u      = 0x0a
+	opConst2s      = 0x0b
+	opConst4u      = 0x0c
+	opConst4s      = 0x0d
+	opConst8u      = 0x0e
+	opConst8s      = 0x0f
+	opConstu       = 0x10
+	opConsts       = 0x11
+	opPlus         = 0x22
+	opBra          = 0x28
+	opSkip         = 0x2f
+	opReg0         = 0x50
+	opReg31        = 0x6f
+	opBreg0        = 0x70
+	opBreg31       = 0x8f
+	opRegx         = 0x90
+	opFbreg        = 0x91
+	opBregx        = 0x92
+	opCallFrameCFA = 0x9c
+)
+
+// Operations that index the address table of a split unit's skeleton
+const (
+	opAddrx         = 0xa1
+	opConstx        = 0xa2
+	opGNUAddrIndex  = 0xfb
+	opGNUConstIndex = 0xfc
+)
+
+// A Location is where a value lives while a function runs: either memory at
+// Address, or the register numbered Register if InRegister is set
+type Location struct {
+	Address    uint64
+	InRegister bool
+	Register   int
+}
+
+// Evaluates a DWARF location expression against a frame
+//
+// Addresses, constants, register-relative (DW_OP_bregN, DW_OP_bregx) and
+// frame-relative (DW_OP_fbreg) operations are supported, along with
+// DW_OP_plus and DW_OP_plus_uconst to combine them. A location consisting
+// of a single DW_OP_regN or DW_OP_regx names a register rather than an
+// address. addrSize is the size of an address in the expression's unit, the
+// operand of DW_OP_addr, and frameBase is only consulted by DW_OP_fbreg.
+func EvalLocation(expr []byte, addrSize int, frameBase uint64, regs Registers) (Location, error) {
+	return evalLocation(expr, addrSize, &frameBase, regs)
+}
+
+// Behaves like EvalLocation, but reports DW_OP_fbreg as ErrNoLocation when
+// frameBase is nil, as in a function with no DW_AT_frame_base
+func evalLocation(expr []byte, addrSize int, frameBase *uint64, regs Registers) (Location, error) {
+	if len(expr) == 0 {
+		return Location{}, fmt.Errorf("Cannot evaluate an empty location: %w", ErrNoLocation)
+	}
+	if reg, n, ok := decodeRegOp(expr); ok {
+		if n != len(expr) {
+			return Location{}, fmt.Errorf("Register location followed by further operations: %w", ErrUnsupportedForm)
+		}
+		return Location{InRegister: true, Register: reg}, nil
+	}
+
+	stack := make([]uint64, 0, 4)
+	for i := 0; i < len(expr); {
+		op := expr[i]
+		i++
+		switch {
+		case op == opAddr:
+			if len(expr) < i+addrSize {
+				return Location{}, errTruncated(op)
+			}
+			stack = append(stack, decodeConst(expr[i:i+addrSize], false))
+			i += addrSize
+		case op >= opConst1u && op <= opConst8s:
+			size := 1 << ((op - opConst1u) / 2)
+			if len(expr) < i+size {
+				return Location{}, errTruncated(op)
+			}
+			stack = append(stack, decodeConst(expr[i:i+size], (op-opConst1u)%2 == 1))
+			i += size
+		case op == opConstu:
+			v, n := decodeULEB128(expr[i:])
+			if n == 0 {
+				return Location{}, errTruncated(op)
+			}
+			stack = append(stack, v)
+			i += n
+		case op == opConsts:
+			v, n := decodeSLEB128(expr[i:])
+			if n == 0 {
+				return Location{}, errTruncated(op)
+			}
+			stack = append(stack, uint64(v))
+			i += n
+		case op == opFbreg:
+			off, n := decodeSLEB128(expr[i:])
+			if n == 0 {
+				return Location{}, errTruncated(op)
+			}
+			if frameBase == nil {
+				return Location{}, fmt.Errorf("DW_OP_fbreg without a frame base: %w", ErrNoLocation)
+			}
+			stack = append(stack, *frameBase+uint64(off))
+			i += n
+		case op >= opBreg0 && op <= opBreg31, op == opBregx:
+			reg := int(op - opBreg0)
+			if op == opBregx {
+				r, n := decodeULEB128(expr[i:])
+				if n == 0 {
+					return Location{}, errTruncated(op)
+				}
+				reg = int(r)
+				i += n
+			}
+			off, n := decodeSLEB128(expr[i:])
+			if n == 0 {
+				return Location{}, errTruncated(op)
+			}
+			i += n
+			v, err := regs.get(reg)
+			if err != nil {
+				return Location{}, err
+			}
+			stack = append(stack, v+uint64(off))
+		case op == opCallFrameCFA:
+			v, err := regs.get(RegCFA)
+			if err != nil {
+				return Location{}, err
+			}
+			stack = append(stack, v)
+		case op == opPlusUconst:
+			v, n := decodeULEB128(expr[i:])
+			if n == 0 || len(stack) == 0 {
+				return Location{}, errTruncated(op)
+			}
+			stack[len(stack)-1] += v
+			i += n
+		case op == opPlus:
+			if len(stack) < 2 {
+				return Location{}, errTruncated(op)
+			}
+			stack[len(stack)-2] += stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		default:
+			return Location{}, fmt.Errorf("Location operation %#x: %w", op, ErrUnsupportedForm)
+		}
+	}
+	if len(stack) == 0 {
+		return Location{}, fmt.Errorf("Location expression left no address: %w", ErrNoLocation)
+	}
+	return Location{Address: stack[len(stack)-1]}, nil
+}
+
+// Evaluates a DW_AT_frame_base expression
+//
+// Unlike other locations, a frame base naming a register means the value
+// held in that register rather than the register itself.
+func EvalFrameBase(expr []byte, addrSize int, regs Registers) (uint64, error) {
+	// A frame base cannot be relative to itself
+	loc, err := evalLocation(expr, addrSize, nil, regs)
+	if err != nil {
+		return 0, err
+	}
+	if loc.InRegister {
+		return regs.get(loc.Register)
+	}
+	return loc.Address, nil
+}
+
+// Decodes a DW_OP_regN or DW_OP_regx operation at the start of expr,
+// returning the register and the number of bytes consumed
+func decodeRegOp(expr []byte) (int, int, bool) {
+	switch op := expr[0]; {
+	case op >= opReg0 && op <= opReg31:
+		return int(op - opReg0), 1, true
+	case op == opRegx:
+		r, n := decodeULEB128(expr[1:])
+		if n == 0 {
+			return 0, 0, false
+		}
+		return int(r), n + 1, true
+	}
+	return 0, 0, false
+}
+
+// Decodes a little-endian constant of 1, 2, 4 or 8 bytes
+func decodeConst(b []byte, signed bool) uint64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	if signed && len(b) < 8 && b[len(b)-1]&0x80 != 0 {
+		v |= ^uint64(0) << (8 * len(b))
+	}
+	return v
+}
+
+// Decodes a signed LEB128 number, returning it along with the number of
+// bytes consumed. Returns 0 bytes consumed if the encoding is truncated.
+func decodeSLEB128(b []byte) (int64, int) {
+	var val int64
+	var shift uint
+	for i, c := range b {
+		val |= int64(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			if shift < 64 && c&0x40 != 0 {
+				val |= -1 << shift
+			}
+			return val, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func errTruncated(op byte) error {
+	return fmt.Errorf("Truncated operands for location operation %#x: %w", op, ErrUnsupportedForm)
+}
+
+// Returns the length of the operands following an operation in an
+// expression, or false if the operation is not known or its operands are
+// truncated
+func exprOperandLen(b []byte, op byte, addrSize int) (int, bool) {
+	leb := func(at int) (int, bool) {
+		if at > len(b) {
+			return 0, false
+		}
+		_, n := decodeULEB128(b[at:])
+		return n, n > 0
+	}
+	switch {
+	case op == opAddr:
+		return addrSize, true
+	case op >= opConst1u && op <= opConst8s:
+		return 1 << ((op - opConst1u) / 2), true
+	// pick, deref_size, xderef_size
+	case op == 0x15, op == 0x94, op == 0x95:
+		return 1, true
+	// bra, skip, call2
+	case op == opBra, op == opSkip, op == 0x98:
+		return 2, true
+	// call4, call_ref, GNU_parameter_ref
+	case op == 0x99, op == 0x9a, op == 0xfa:
+		return 4, true
+	case op == opConstu, op == opConsts, op == opPlusUconst, op >= opBreg0 && op <= opBreg31,
+		op == opRegx, op == opFbreg, op == 0x93, op == opAddrx, op == opConstx,
+		op == 0xa8, op == 0xa9, op == opGNUAddrIndex, op == opGNUConstIndex, op == 0xf7, op == 0xf9:
+		return leb(0)
+	// bregx, bit_piece, regval_type
+	case op == opBregx, op == 0x9d, op == 0xa5, op == 0xf5:
+		n, ok := leb(0)
+		if !ok {
+			return 0, false
+		}
+		m, ok := leb(n)
+		return n + m, ok
+	// implicit_value, entry_value, GNU_entry_value
+	case op == 0x9e, op == 0xa3, op == 0xf3:
+		size, n := decodeULEB128(b)
+		if n == 0 || size > uint64(len(b)-n) {
+			return 0, false
+		}
+		return n + int(size), true
+	// implicit_pointer, GNU_implicit_pointer
+	case op == 0xa0, op == 0xf2:
+		n, ok := leb(4)
+		return 4 + n, ok
+	// const_type, GNU_const_type
+	case op == 0xa4, op == 0xf4:
+		n, ok := leb(0)
+		if !ok || n >= len(b) {
+			return 0, false
+		}
+		return n + 1 + int(b[n]), true
+	// deref_type, xderef_type, GNU_deref_type
+	case op == 0xa6, op == 0xa7, op == 0xf6:
+		n, ok := leb(1)
+		return 1 + n, ok
+	// Operations with no operands
+	case op == 0x06, op >= 0x12 && op <= 0x14, op >= 0x16 && op <= 0x22,
+		op >= 0x24 && op <= 0x27, op >= 0x29 && op <= 0x2e, op >= 0x30 && op <= 0x6f,
+		op == 0x96, op == 0x97, op == 0x9b, op == opCallFrameCFA, op == 0x9f, op == 0xe0, op == 0xf0:
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalLocation(t *testing.T) {
+	regs := Registers{6: 0x7000, 7: 0x6f00, 17: 0x100, RegCFA: 0x7010}
+	tests := []struct {
+		expr []byte
+		want Location
+	}{
+		{[]byte{opAddr, 0x00, 0x10, 0, 0, 0, 0, 0, 0}, Location{Address: 0x1000}},
+		{[]byte{opAddr, 0x00, 0x10, 0, 0, 0, 0, 0, 0, opPlusUconst, 0x08}, Location{Address: 0x1008}},
+		// DW_OP_fbreg -20
+		{[]byte{opFbreg, 0x6c}, Location{Address: 0x8000 - 20}},
+		// DW_OP_breg7 +8
+		{[]byte{opBreg0 + 7, 0x08}, Location{Address: 0x6f08}},
+		// DW_OP_bregx 17 -1
+		{[]byte{opBregx, 17, 0x7f}, Location{Address: 0xff}},
+		{[]byte{opBreg0 + 6, 0x00, opPlusUconst, 0x10}, Location{Address: 0x7010}},
+		{[]byte{opConst1s, 0xff, opConst2u, 0x00, 0x01, opPlus}, Location{Address: 0xff}},
+		{[]byte{opCallFrameCFA}, Location{Address: 0x7010}},
+		{[]byte{opReg0 + 3}, Location{InRegister: true, Register: 3}},
+		{[]byte{opRegx, 17}, Location{InRegister: true, Register: 17}},
+	}
+	for _, tt := range tests {
+		got, err := EvalLocation(tt.expr, 8, 0x8000, regs)
+		assert.NoError(t, err, "%x", tt.expr)
+		assert.Equal(t, tt.want, got, "%x", tt.expr)
+	}
+
+	_, err := EvalLocation([]byte{opBreg0 + 5, 0x00}, 8, 0, regs)
+	assert.ErrorIs(t, err, ErrNoRegister)
+	_, err = EvalLocation([]byte{opCallFrameCFA}, 8, 0, Registers{})
+	assert.ErrorIs(t, err, ErrNoRegister)
+	_, err = EvalLocation([]byte{opFbreg}, 8, 0, regs)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	_, err = EvalLocation([]byte{0x96}, 8, 0, regs)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+	_, err = EvalLocation([]byte{}, 8, 0, regs)
+	assert.ErrorIs(t, err, ErrNoLocation)
+	// Four bytes are left after the address, but 64-bit addresses take eight
+	_, err = EvalLocation([]byte{opConst1u, 0x01, opAddr, 0x00, 0x10, 0, 0}, 8, 0, regs)
+	assert.ErrorIs(t, err, ErrUnsupportedForm)
+}
+
+func TestEvalLocation32(t *testing.T) {
+	tests := []struct {
+		expr []byte
+		want Location
+	}{
+		{[]byte{opAddr, 0x00, 0x10, 0, 0}, Location{Address: 0x1000}},
+		// DW_OP_addr 0x1000; DW_OP_plus_uconst 8
+		{[]byte{opAddr, 0x00, 0x10, 0, 0, opPlusUconst, 0x08}, Location{Address: 0x1008}},
+		// DW_OP_addr 0x2000; DW_OP_addr 0x10; DW_OP_plus
+		{[]byte{opAddr, 0x00, 0x20, 0, 0, opAddr, 0x10, 0, 0, 0, opPlus}, Location{Address: 0x2010}},
+	}
+	for _, tt := range tests {
+		got, err := EvalLocation(tt.expr, 4, 0, Registers{})
+		assert.NoError(t, err, "%x", tt.expr)
+		assert.Equal(t, tt.want, got, "%x", tt.expr)
+	}
+}
+
+func TestEvalFrameBase(t *testing.T) {
+	regs := Registers{6: 0x7000, RegCFA: 0x7010}
+	base, err := EvalFrameBase([]byte{opReg0 + 6}, 8, regs)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0x7000), base)
+	base, err = EvalFrameBase([]byte{opCallFrameCFA}, 8, regs)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0x7010), base)
+	_, err = EvalFrameBase([]byte{opReg0 + 7}, 8, regs)
+	assert.ErrorIs(t, err, ErrNoRegister)
+}
@@ -2,7 +2,7 @@ package parser
 
 import (
 	"debug/dwarf"
-	"errors"
+	"encoding/binary"
 	"fmt"
 )
 
@@ -14,10 +14,14 @@ type DebugFile interface {
 func GetReader[T DebugFile](fh T) (*dwarf.Reader, error) {
 	dwarfData, err := fh.DWARF()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	entryReader := dwarfData.Reader()
-	return entryReader, err
+	return dwarfData.Reader(), nil
+}
+
+// Returns the DWARF data of a debug file
+func GetData[T DebugFile](fh T) (*dwarf.Data, error) {
+	return fh.DWARF()
 }
 
 func GetCUs(r *dwarf.Reader) ([]*dwarf.Entry, error) {
@@ -93,21 +97,70 @@ func GetEntry(r *dwarf.Reader, name string) (*dwarf.Entry, *dwarf.Entry, error)
 		r.Seek(0)
 		e, lastCU, ok, err = getFromRemaining(r, name)
 	}
-	if !ok {
-		err = fmt.Errorf("Could not find entry %v", name)
+	if !ok && err == nil {
+		err = fmt.Errorf("Could not find entry %v: %w", name, ErrNotFound)
 	}
 	return e, lastCU, err
 }
 
+// Returns the name of this entry, or the empty string for unnamed entries
+// such as anonymous unions
+func EntryName(entry *dwarf.Entry) string {
+	name, _ := entry.Val(dwarf.AttrName).(string)
+	return name
+}
+
 // Finds the size of the type defined by this entry, in bits
 func GetBitSize(entry *dwarf.Entry) (int, error) {
 	if HasAttr(entry, dwarf.AttrBitSize) {
-		return entry.Val(dwarf.AttrBitSize).(int), nil
+		size, ok := entry.Val(dwarf.AttrBitSize).(int64)
+		if !ok {
+			return 0, newEntryError(entry, fmt.Errorf("DW_AT_bit_size: %w", ErrUnsupportedForm))
+		}
+		return int(size), nil
 	} else if HasAttr(entry, dwarf.AttrByteSize) {
-		return int(entry.Val(dwarf.AttrByteSize).(int64) * 8), nil
+		size, ok := entry.Val(dwarf.AttrByteSize).(int64)
+		if !ok {
+			return 0, newEntryError(entry, fmt.Errorf("DW_AT_byte_size: %w", ErrUnsupportedForm))
+		}
+		return int(size * 8), nil
 	} else {
-		return 0, errors.New(fmt.Sprintf("Could not get bit size of entry:\n%v", FormatEntryInfo(entry)))
+		return 0, newEntryError(entry, ErrNoSize)
+	}
+}
+
+// Returns the offset in bytes of a member from the start of its struct
+//
+// Older compilers encode the offset as a DW_OP_plus_uconst location
+// expression rather than a constant; both are understood.
+func GetMemberOffset(entry *dwarf.Entry) (int, error) {
+	switch loc := entry.Val(dwarf.AttrDataMemberLoc).(type) {
+	case int64:
+		return int(loc), nil
+	case []byte:
+		if len(loc) > 1 && loc[0] == opPlusUconst {
+			offset, n := decodeULEB128(loc[1:])
+			if n > 0 {
+				return int(offset), nil
+			}
+		}
+	}
+	return 0, newEntryError(entry, fmt.Errorf("DW_AT_data_member_location: %w", ErrUnsupportedForm))
+}
+
+// Decodes an unsigned LEB128 number, returning it along with the number of
+// bytes consumed. Returns 0 bytes consumed if the encoding is truncated.
+func decodeULEB128(b []byte) (uint64, int) {
+	var val uint64
+	var shift uint
+	for i, c := range b {
+		val |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return val, i + 1
+		}
+		shift += 7
 	}
+	return 0, 0
 }
 
 // Returns a slice with en entry for the range of each array dimension
@@ -116,13 +169,15 @@ func GetBitSize(entry *dwarf.Entry) (int, error) {
 // the dimension of the array.
 func GetArrayRanges(r *dwarf.Reader, entry *dwarf.Entry) ([]int, error) {
 	_, err := GetTypeEntry(r, entry)
+	if err != nil {
+		return nil, err
+	}
 	ranges := make([]int, 0)
-	// typeEntry, err := GetTypeEntry(reader, entry)
-	// var err error
 	for {
-		// fmt.Println("Stepping through a subrange:")
-		subrange, _ := r.Next()
-		// fmt.Println(FormatEntryInfo(subrange))
+		subrange, err := r.Next()
+		if err != nil {
+			return ranges, err
+		}
 
 		// When we've finished iterating over members, we are done with the meaningful
 		// children of this typedef. We are also finished if we reach the end of the DWARF
@@ -131,17 +186,30 @@ func GetArrayRanges(r *dwarf.Reader, entry *dwarf.Entry) ([]int, error) {
 			break
 		}
 
+		// Some compilers describe a dimension by its count and others by its
+		// upper bound. Bounds that are not constants, as for variable length
+		// arrays, cannot be known statically.
 		if HasAttr(subrange, dwarf.AttrCount) {
-			ranges = append(ranges, int(subrange.Val(dwarf.AttrCount).(int64)))
+			count, ok := subrange.Val(dwarf.AttrCount).(int64)
+			if !ok {
+				return ranges, newEntryError(subrange, fmt.Errorf("DW_AT_count: %w", ErrUnsupportedForm))
+			}
+			ranges = append(ranges, int(count))
+		} else if HasAttr(subrange, dwarf.AttrUpperBound) {
+			bound, ok := subrange.Val(dwarf.AttrUpperBound).(int64)
+			if !ok {
+				return ranges, newEntryError(subrange, fmt.Errorf("DW_AT_upper_bound: %w", ErrUnsupportedForm))
+			}
+			ranges = append(ranges, int(bound)+1)
 		}
 	}
-	return ranges, err
+	return ranges, nil
 }
 
 // Formats key information about this entry as a string; strives to be easily readable.
 func FormatEntryInfo(entry *dwarf.Entry) string {
 	if entry == nil {
-		fmt.Println("ERROR: nil entry passed")
+		return "ERROR: nil entry passed\n"
 	}
 	// JDG TODO: make sure I'm using the right DW_AT names here
 	var str string
@@ -151,16 +219,28 @@ func FormatEntryInfo(entry *dwarf.Entry) string {
 	for _, field := range entry.Field {
 		// str += fmt.Sprintf("  %s: %v", field.Val)
 		if field.Attr == dwarf.AttrName {
-			name := field.Val.(string)
-			str += fmt.Sprintf("  DW_AT_name: %s\n", name)
+			str += fmt.Sprintf("  DW_AT_name: %v\n", field.Val)
+		}
+		if field.Attr == dwarf.AttrLinkageName || field.Attr == attrMIPSLinkageName {
+			str += fmt.Sprintf("  DW_AT_linkage_name: %v\n", field.Val)
+			if name, ok := field.Val.(string); ok && Demangle(name) != name {
+				str += fmt.Sprintf("  Demangled: %s\n", Demangle(name))
+			}
 		}
 		if field.Attr == dwarf.AttrByteSize {
 			byte_size := field.Val
 			str += fmt.Sprintf("  DW_AT_byte_size: %d\n", byte_size)
 		}
 		if field.Attr == dwarf.AttrLocation {
-			location, _ := GetLocation(entry)
-			str += fmt.Sprintf("  DW_AT_location: %x\n", ParseLocation(location))
+			location, err := GetLocation(entry)
+			if err == nil {
+				var addr int
+				addr, err = ParseLocation(location)
+				str += fmt.Sprintf("  DW_AT_location: %x\n", addr)
+			}
+			if err != nil {
+				str += fmt.Sprintf("  DW_AT_location: %v\n", err)
+			}
 		}
 		if field.Attr == dwarf.AttrDataMemberLoc {
 			location := field.Val
@@ -208,31 +288,60 @@ func HasAttr(entry *dwarf.Entry, attr dwarf.Attr) bool {
 }
 
 // Returns the location of an entry in memory
+//
+// Only single location expressions are supported; location lists, which
+// describe values that move around as a program runs, are not.
 func GetLocation(entry *dwarf.Entry) ([]uint8, error) {
-	var err error
 	loc := entry.Val(dwarf.AttrLocation)
 	if loc == nil {
-		err = errors.New(fmt.Sprintf("Could not find data location for %v", entry))
-		return nil, err
+		return nil, newEntryError(entry, ErrNoLocation)
+	}
+	location, ok := loc.([]uint8)
+	if !ok {
+		return nil, newEntryError(entry, fmt.Errorf("DW_AT_location: %w", ErrUnsupportedForm))
 	}
-	return entry.Val(dwarf.AttrLocation).([]uint8), nil
+	return location, nil
 }
 
+// DWARF expression operations
+const (
+	opAddr       = 0x03
+	opPlusUconst = 0x23
+)
+
 // Translates a DW_AT_locationn attribute into an address
-func ParseLocation(location []uint8) int {
-	if location == nil {
-		panic("Cannot parse location for an empty slice!")
+//
+// The location must be a single DW_OP_addr operation, as is the case for
+// static variables, whose operand is a little-endian address of 4 or 8
+// bytes. Use ParseLocationIn where the unit of the location is known.
+func ParseLocation(location []uint8) (int, error) {
+	return ParseLocationIn(location, len(location)-1, binary.LittleEndian)
+}
+
+// Translates a DW_AT_location attribute of a unit with addresses of this
+// size and byte order into an address
+//
+// The location must be a single DW_OP_addr operation; any other
+// operations, such as those of a location relative to a register, are
+// reported as ErrUnsupportedForm.
+func ParseLocationIn(location []uint8, addrSize int, order binary.ByteOrder) (int, error) {
+	if len(location) == 0 {
+		return 0, fmt.Errorf("Cannot parse location for an empty slice: %w", ErrNoLocation)
+	}
+	if location[0] != opAddr {
+		return 0, fmt.Errorf("Location operation %#x: %w", location[0], ErrUnsupportedForm)
 	}
-	// Ignore the first entry in the slice
-	// --> This somehow communicates a format?
-	// Build the last slice from right to left
-	location = location[1:]
-	var locationAsInt int
-	locationAsInt = 0
-	for i := 0; i < len(location); i++ {
-		locationAsInt += int(location[i]) << (8 * i)
+	if len(location) != 1+addrSize {
+		return 0, fmt.Errorf("Location of %d bytes is not a single address of %d: %w", len(location), addrSize, ErrUnsupportedForm)
+	}
+	switch addrSize {
+	case 4:
+		return int(order.Uint32(location[1:])), nil
+	case 8:
+		return int(order.Uint64(location[1:])), nil
+	default:
+		return 0, fmt.Errorf("Address of %d bytes: %w", addrSize, ErrUnsupportedForm)
 	}
-	return locationAsInt
 }
 
 // Returns the entry defining the type for a given entry. Returns self if
@@ -247,11 +356,17 @@ func GetTypeEntry(reader *dwarf.Reader, entry *dwarf.Entry) (*dwarf.Entry, error
 	var typeDie *dwarf.Entry
 	for _, field := range entry.Field {
 		if field.Attr == dwarf.AttrType {
-			typeDieOffset := field.Val.(dwarf.Offset)
+			typeDieOffset, ok := field.Val.(dwarf.Offset)
+			if !ok {
+				return nil, newEntryError(entry, fmt.Errorf("DW_AT_type: %w", ErrUnsupportedForm))
+			}
 			reader.Seek(typeDieOffset)
 			typeDie, err = reader.Next()
 		}
 	}
+	if err == nil && typeDie == nil {
+		err = newEntryError(entry, fmt.Errorf("type entry: %w", ErrNotFound))
+	}
 	return typeDie, err
 }
 
@@ -260,6 +375,9 @@ func GetTypeEntry(reader *dwarf.Reader, entry *dwarf.Entry) (*dwarf.Entry, error
 // entries
 func ResolveTypeEntry(reader *dwarf.Reader, entry *dwarf.Entry) (*dwarf.Entry, error) {
 	typeEntry, err := GetTypeEntry(reader, entry)
+	if err != nil {
+		return nil, err
+	}
 	switch typeEntry.Tag {
 	case dwarf.TagArrayType:
 		return ResolveTypeEntry(reader, typeEntry)
@@ -267,3 +385,68 @@ func ResolveTypeEntry(reader *dwarf.Reader, entry *dwarf.Entry) (*dwarf.Entry, e
 		return typeEntry, err
 	}
 }
+
+// Returns the type of this entry written as in C, for example "const char*"
+// or "Driver[2]", or "void" if the entry has no type
+func TypeName(reader *dwarf.Reader, entry *dwarf.Entry) string {
+	return typeName(reader, entry, 0, false)
+}
+
+// Returns the type of this entry as TypeName does, but with each typedef
+// replaced by the type it names, so that types spelled differently can be
+// compared
+func canonicalTypeName(reader *dwarf.Reader, entry *dwarf.Entry) string {
+	return typeName(reader, entry, 0, true)
+}
+
+// Limits how deeply typeName follows types, in case of malformed DWARF
+// that refers back to itself
+const maxTypeDepth = 32
+
+func typeName(reader *dwarf.Reader, entry *dwarf.Entry, depth int, canonical bool) string {
+	if !HasAttr(entry, dwarf.AttrType) {
+		return "void"
+	}
+	typeEntry, err := GetTypeEntry(reader, entry)
+	if err != nil || depth > maxTypeDepth {
+		return "?"
+	}
+	switch typeEntry.Tag {
+	case dwarf.TagPointerType:
+		return typeName(reader, typeEntry, depth+1, canonical) + "*"
+	case dwarf.TagReferenceType:
+		return typeName(reader, typeEntry, depth+1, canonical) + "&"
+	case dwarf.TagRvalueReferenceType:
+		return typeName(reader, typeEntry, depth+1, canonical) + "&&"
+	case dwarf.TagConstType:
+		return "const " + typeName(reader, typeEntry, depth+1, canonical)
+	case dwarf.TagVolatileType:
+		return "volatile " + typeName(reader, typeEntry, depth+1, canonical)
+	case dwarf.TagArrayType:
+		offset := typeEntry.Offset
+		ranges, err := GetArrayRanges(reader, entry)
+		if err != nil {
+			return "?"
+		}
+		reader.Seek(offset)
+		arrayEntry, err := reader.Next()
+		if err != nil || arrayEntry == nil {
+			return "?"
+		}
+		name := typeName(reader, arrayEntry, depth+1, canonical)
+		for _, r := range ranges {
+			name += fmt.Sprintf("[%d]", r)
+		}
+		return name
+	case dwarf.TagSubroutineType:
+		return "func"
+	case dwarf.TagTypedef:
+		if canonical {
+			return typeName(reader, typeEntry, depth+1, canonical)
+		}
+	}
+	if name := EntryName(typeEntry); name != "" {
+		return name
+	}
+	return "<anonymous>"
+}
@@ -7,8 +7,6 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-
-	"github.com/jdginn/durins-door/explorer/plat"
 )
 
 // For now, this functionality all relies upon having a reader object. The only good
@@ -19,7 +17,7 @@ import (
 var testcaseFilename = "../testcase-compiler/testcase.dwarf"
 
 func getReaderFromFile(fileName string) (*dwarf.Reader, error) {
-	fh, err := plat.GetReaderFromFile(fileName)
+	fh, err := OpenELF(fileName)
 	if err != nil {
 		wd, _ := os.Getwd()
 		panic(fmt.Errorf("Could not open file %s:\n\ncwd:  %s\n\nerror message:\n\t%s", fileName, wd, err))
@@ -27,6 +25,15 @@ func getReaderFromFile(fileName string) (*dwarf.Reader, error) {
 	return GetReader(fh)
 }
 
+func getDataFromFile(fileName string) (*dwarf.Data, error) {
+	fh, err := OpenELF(fileName)
+	if err != nil {
+		wd, _ := os.Getwd()
+		panic(fmt.Errorf("Could not open file %s:\n\ncwd:  %s\n\nerror message:\n\t%s", fileName, wd, err))
+	}
+	return GetData(fh)
+}
+
 func TestGetReaderFromFile(t *testing.T) {
 	// For now, just assume testcase is always located in the right place
 	_, err := getReaderFromFile(testcaseFilename)
@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// One step along a path into a variable: a member name followed by any
+// number of array indices. The name is empty when indexing into the variable
+// itself.
+type PathElem struct {
+	Name    string
+	Indices []int
+}
+
+// Splits a path into its elements
+//
+// Paths are written as they would be in C: members are delimited by dots and
+// array indices by brackets, for example "drivers[1].initials[0]".
+func ParsePath(path string) ([]PathElem, error) {
+	elems := make([]PathElem, 0)
+	if path == "" {
+		return elems, nil
+	}
+	for i, part := range strings.Split(path, ".") {
+		name := part
+		indices := make([]int, 0)
+		if open := strings.Index(part, "["); open >= 0 {
+			name = part[:open]
+			rest := part[open:]
+			for rest != "" {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("Malformed indices %q in path %q: %w", part[open:], path, ErrBadPath)
+				}
+				index, err := strconv.Atoi(rest[1:end])
+				if err != nil {
+					return nil, fmt.Errorf("Bad index %q in path %q: %w", rest[1:end], path, ErrBadPath)
+				}
+				indices = append(indices, index)
+				rest = rest[end+1:]
+			}
+		}
+		// Only the first element may omit its name, to index into the
+		// variable itself
+		if name == "" && (i > 0 || len(indices) == 0) {
+			return nil, fmt.Errorf("Empty member name in path %q: %w", path, ErrBadPath)
+		}
+		elems = append(elems, PathElem{name, indices})
+	}
+	return elems, nil
+}
+
+// Splits a path starting with a variable name into that name and the path
+// to a field within the variable
+//
+// For example "teams[1].drivers" is split into "teams" and "[1].drivers".
+func SplitVariablePath(path string) (string, string) {
+	end := strings.IndexAny(path, ".[")
+	if end < 0 {
+		return path, ""
+	}
+	if path[end] == '.' {
+		return path[:end], path[end+1:]
+	}
+	return path[:end], path[end:]
+}
+
+// A Field describes where a part of a variable is found in memory
+type Field struct {
+	// Type of the field. For array elements this is the type of the
+	// element, with the dimensions that were indexed removed.
+	Type TypeDefProxy
+	// Address of the first byte of the field
+	Address int
+	// Size of the whole field, including all array elements, in bits
+	BitSize int
+}
+
+// Returns the dimensions of this proxy, which are empty for scalars
+func (p TypeDefProxy) dims() []int {
+	if len(p.arrayRanges) == 1 && p.arrayRanges[0] == 0 {
+		return []int{}
+	}
+	return p.arrayRanges
+}
+
+// Returns the number of elements described by these dimensions
+func numElements(dims []int) int {
+	n := 1
+	for _, d := range dims {
+		n *= d
+	}
+	return n
+}
+
+// Returns the proxy for one element of this array, named after the array
+// with the index appended, for example "drivers[1]"
+func (p TypeDefProxy) Index(i int) (*TypeDefProxy, error) {
+	dims := p.dims()
+	if len(dims) == 0 {
+		return nil, fmt.Errorf("Cannot index %s, which is not an array: %w", p.name, ErrBadPath)
+	}
+	if i < 0 || i >= dims[0] {
+		return nil, fmt.Errorf("Index %d out of range for dimension of length %d of %s: %w", i, dims[0], p.name, ErrOutOfRange)
+	}
+	elem := p
+	elem.name = fmt.Sprintf("%s[%d]", p.name, i)
+	elem.arrayRanges = append([]int{}, dims[1:]...)
+	if len(elem.arrayRanges) == 0 {
+		elem.arrayRanges = []int{0}
+	}
+	elem.structOffset = p.structOffset + i*numElements(dims[1:])*p.bitSize
+	return &elem, nil
+}
+
+// Locates a field of this variable by its path
+//
+// The path is relative to the variable, so "[1].drivers[0].car_number"
+// locates a member of the second element of an array variable. The empty
+// path locates the whole variable.
+//
+// NOTE: at present, fields must be byte-aligned
+func (p VariableProxy) Field(path string) (Field, error) {
+	elems, err := ParsePath(path)
+	if err != nil {
+		return Field{}, err
+	}
+	cur := p.Type
+	dims := cur.dims()
+	bitOffset := 0
+	for _, elem := range elems {
+		if elem.Name != "" {
+			if len(dims) > 0 {
+				return Field{}, fmt.Errorf("Cannot access member %s of array %s without an index: %w", elem.Name, cur.name, ErrBadPath)
+			}
+			child, err := cur.GetChild(elem.Name)
+			if err != nil {
+				return Field{}, err
+			}
+			cur = *child
+			dims = cur.dims()
+			bitOffset += cur.structOffset
+		}
+		if len(elem.Indices) > len(dims) {
+			return Field{}, fmt.Errorf("Too many indices for %s with dimensions %v: %w", cur.name, dims, ErrOutOfRange)
+		}
+		for i, index := range elem.Indices {
+			if index < 0 || index >= dims[i] {
+				return Field{}, fmt.Errorf("Index %d out of range for dimension of length %d of %s: %w", index, dims[i], cur.name, ErrOutOfRange)
+			}
+			bitOffset += index * numElements(dims[i+1:]) * cur.bitSize
+		}
+		dims = dims[len(elem.Indices):]
+	}
+	cur.arrayRanges = append([]int{}, dims...)
+	if len(dims) == 0 {
+		cur.arrayRanges = []int{0}
+	}
+	if bitOffset%8 != 0 {
+		return Field{}, fmt.Errorf("Field %s is not byte-aligned: %w", path, ErrUnsupportedForm)
+	}
+	return Field{
+		Type:    cur,
+		Address: p.Address + bitOffset/8,
+		BitSize: cur.bitSize * numElements(dims),
+	}, nil
+}
+
+// Reads the current value of a field through the client
+func (p *VariableProxy) ReadField(path string) ([]byte, error) {
+	if p.client == nil {
+		return nil, fmt.Errorf("Cannot read %s of proxy %s: %w", path, p.name, ErrNoClient)
+	}
+	f, err := p.Field(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.Read(f.Address, (f.BitSize+7)/8)
+}
+
+// Writes a new value to a field through the client
+//
+// The value must be exactly the size of the field.
+func (p *VariableProxy) WriteField(path string, value []byte) error {
+	if p.client == nil {
+		return fmt.Errorf("Cannot write %s of proxy %s: %w", path, p.name, ErrNoClient)
+	}
+	f, err := p.Field(path)
+	if err != nil {
+		return err
+	}
+	if len(value) != (f.BitSize+7)/8 {
+		return fmt.Errorf("Attempted to write %d bytes to %s of size %d bits: %w", len(value), path, f.BitSize, ErrOutOfRange)
+	}
+	return p.client.Write(f.Address, value)
+}
+
+// Decodes up to 8 bytes as an unsigned integer in the given byte order
+func DecodeUint(b []byte, order binary.ByteOrder) (uint64, error) {
+	if len(b) > 8 {
+		return 0, fmt.Errorf("Cannot decode %d bytes as an integer: %w", len(b), ErrOutOfRange)
+	}
+	var buf [8]byte
+	if order == binary.BigEndian {
+		copy(buf[8-len(b):], b)
+		return binary.BigEndian.Uint64(buf[:]), nil
+	}
+	copy(buf[:], b)
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+// Encodes an unsigned integer in size bytes in the given byte order
+func EncodeUint(v uint64, size int, order binary.ByteOrder) ([]byte, error) {
+	if size > 8 || (size < 8 && v>>(8*uint(size)) != 0) {
+		return nil, fmt.Errorf("Value %d does not fit in %d bytes: %w", v, size, ErrOutOfRange)
+	}
+	var buf [8]byte
+	if order == binary.BigEndian {
+		binary.BigEndian.PutUint64(buf[:], v)
+		return buf[8-size:], nil
+	}
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return buf[:size], nil
+}
+
+// Encodes a signed integer in two's complement in size bytes in the given
+// byte order
+func EncodeInt(v int64, size int, order binary.ByteOrder) ([]byte, error) {
+	if size > 8 || (size < 8 && (v < -(1<<(8*uint(size)-1)) || v >= 1<<(8*uint(size)-1))) {
+		return nil, fmt.Errorf("Value %d does not fit in %d bytes: %w", v, size, ErrOutOfRange)
+	}
+	u := uint64(v)
+	if size < 8 {
+		u &= 1<<(8*uint(size)) - 1
+	}
+	return EncodeUint(u, size, order)
+}
+
+// Parses an integer written in Go syntax, such as "44", "0x2c" or "-1", and
+// encodes it in size bytes in the given byte order. Negative values are
+// encoded in two's complement.
+func ParseInt(s string, size int, order binary.ByteOrder) ([]byte, error) {
+	if strings.HasPrefix(s, "-") {
+		v, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad integer %q: %w", s, ErrBadValue)
+		}
+		return EncodeInt(v, size, order)
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Bad integer %q: %w", s, ErrBadValue)
+	}
+	return EncodeUint(v, size, order)
+}
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/client/file"
+)
+
+func TestParsePath(t *testing.T) {
+	elems, err := ParsePath("drivers[1].initials[0]")
+	assert.NoError(t, err)
+	assert.Equal(t, []PathElem{{"drivers", []int{1}}, {"initials", []int{0}}}, elems)
+
+	elems, err = ParsePath("[1][2].car_number")
+	assert.NoError(t, err)
+	assert.Equal(t, []PathElem{{"", []int{1, 2}}, {"car_number", []int{}}}, elems)
+
+	elems, err = ParsePath("")
+	assert.NoError(t, err)
+	assert.Equal(t, []PathElem{}, elems)
+
+	for _, bad := range []string{"drivers[", "drivers[x]", "drivers[1]x", "a..b", ".a", "a.[1]"} {
+		_, err = ParsePath(bad)
+		assert.ErrorIs(t, err, ErrBadPath, bad)
+	}
+}
+
+func TestSplitVariablePath(t *testing.T) {
+	for path, expected := range map[string][2]string{
+		"teams":                {"teams", ""},
+		"teams.drivers":        {"teams", "drivers"},
+		"teams[1].drivers[0]":  {"teams", "[1].drivers[0]"},
+		"teams.drivers[0].car": {"teams", "drivers[0].car"},
+	} {
+		name, rest := SplitVariablePath(path)
+		assert.Equal(t, expected, [2]string{name, rest})
+	}
+}
+
+func TestVariableProxyField(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	g := NewTypeGraph(data)
+	e, _, err := GetEntry(data.Reader(), "formula_1_teams")
+	assert.NoError(t, err)
+	teams, err := NewVariableProxy(g, e)
+	assert.NoError(t, err)
+
+	f, err := teams.Field("")
+	assert.NoError(t, err)
+	assert.Equal(t, teams.Address, f.Address)
+	assert.Equal(t, 2*384, f.BitSize)
+
+	f, err = teams.Field("[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, teams.Address+48, f.Address)
+	assert.Equal(t, 384, f.BitSize)
+	assert.Equal(t, "Team", f.Type.Name())
+
+	f, err = teams.Field("[1].drivers[1].car_number")
+	assert.NoError(t, err)
+	assert.Equal(t, teams.Address+48+12+4, f.Address)
+	assert.Equal(t, 32, f.BitSize)
+
+	f, err = teams.Field("[0].sponsors")
+	assert.NoError(t, err)
+	assert.Equal(t, teams.Address+24, f.Address)
+	assert.Equal(t, 64, f.BitSize)
+	assert.Equal(t, []int{4}, f.Type.arrayRanges)
+
+	_, err = teams.Field("[2]")
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	_, err = teams.Field("[0][0]")
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	_, err = teams.Field("drivers")
+	assert.ErrorIs(t, err, ErrBadPath)
+	_, err = teams.Field("[0].pit_crew")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestReadWriteField(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "memory.bin"))
+	assert.NoError(t, err)
+	c, err := file.New(f)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Write(0, make([]byte, 16)))
+	c.SetOffset(0x1000)
+
+	vp := &VariableProxy{
+		name: "points",
+		Type: TypeDefProxy{
+			name:        "Point",
+			bitSize:     32,
+			arrayRanges: []int{2},
+			ahildren: []TypeDefProxy{
+				{name: "x", bitSize: 16, structOffset: 0, arrayRanges: []int{0}},
+				{name: "y", bitSize: 16, structOffset: 16, arrayRanges: []int{0}},
+			},
+		},
+		Address: 0x1000,
+	}
+	_, err = vp.ReadField("[1].y")
+	assert.ErrorIs(t, err, ErrNoClient)
+	vp.SetClient(c)
+
+	assert.NoError(t, vp.WriteField("[1].y", []byte{0xbe, 0xef}))
+	assert.ErrorIs(t, vp.WriteField("[1].y", []byte{0xbe}), ErrOutOfRange)
+	val, err := vp.ReadField("[1].y")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xbe, 0xef}, val)
+	val, err = vp.ReadField("")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0xbe, 0xef}, val)
+}
+
+func TestEncodeDecodeUint(t *testing.T) {
+	v, err := DecodeUint([]byte{0xbe, 0xef}, binary.LittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xefbe), v)
+	v, err = DecodeUint([]byte{0xbe, 0xef}, binary.BigEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xbeef), v)
+	_, err = DecodeUint(make([]byte, 9), binary.LittleEndian)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+
+	b, err := EncodeUint(0xbeef, 2, binary.BigEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xbe, 0xef}, b)
+	b, err = EncodeUint(0xbeef, 4, binary.LittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xef, 0xbe, 0, 0}, b)
+	_, err = EncodeUint(0x1ff, 1, binary.LittleEndian)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+}
+
+func TestEncodeInt(t *testing.T) {
+	b, err := EncodeInt(-1, 2, binary.LittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff}, b)
+	b, err = EncodeInt(-2, 8, binary.BigEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, b)
+	_, err = EncodeInt(-129, 1, binary.LittleEndian)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	_, err = EncodeInt(128, 1, binary.LittleEndian)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+
+	b, err = ParseInt("0x2c", 4, binary.LittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x2c, 0, 0, 0}, b)
+	b, err = ParseInt("-128", 1, binary.LittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x80}, b)
+	_, err = ParseInt("256", 1, binary.LittleEndian)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	_, err = ParseInt("four", 1, binary.LittleEndian)
+	assert.ErrorIs(t, err, ErrBadValue)
+}
+
+func TestTypeDefProxyIndex(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
+	e, _, err := GetEntry(reader, "Team")
+	assert.NoError(t, err)
+	team, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	sponsors, err := team.GetChild("sponsors")
+	assert.NoError(t, err)
+
+	elem, err := sponsors.Index(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "sponsors[3]", elem.Name())
+	assert.Equal(t, []int{}, elem.ArrayRanges())
+	assert.Equal(t, (24+6)*8, elem.BitOffset())
+	assert.Equal(t, 16, elem.BitSize())
+
+	_, err = sponsors.Index(4)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	_, err = elem.Index(0)
+	assert.ErrorIs(t, err, ErrBadPath)
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"sync"
+)
+
+// A Program bundles the DWARF data of a single binary with the index and type
+// graph built over it.
+//
+// A Program is safe for concurrent use: the index guards the units it
+// reads as names are looked up, the type graph guards its memo, the address ranges of CUs and variables
+// are each read once under a sync.Once, and every lookup reads the DWARF
+// through a reader of its own rather than sharing one.
+type Program struct {
+	data    *dwarf.Data
+	index   *Index
+	types   *TypeGraph
+	symbols SymbolTable
+	// The split DWARF of skeleton units that could not be found
+	missingDwo []error
+
+	cuRangesOnce sync.Once
+	cuRangesList []cuRanges
+	cuRangesErr  error
+
+	addrRangesOnce sync.Once
+	addrRangesList []addrRange
+	addrRangesErr  error
+}
+
+// Indexes this DWARF data and returns a Program ready for lookups
+func NewProgram(data *dwarf.Data) (*Program, error) {
+	return newProgram(data, nil, nil)
+}
+
+// Returns a Program whose index is rebuilt from saved, if not nil, or else
+// uses this accelerator table, if not nil
+func newProgram(data *dwarf.Data, saved *IndexData, accel *accelTable) (*Program, error) {
+	var index *Index
+	var err error
+	switch {
+	case saved != nil:
+		index, err = NewIndexFromData(data, saved)
+	case accel != nil:
+		index, err = newAccelIndex(data, accel)
+	default:
+		index, err = NewIndex(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Program{
+		data:  data,
+		index: index,
+		types: NewTypeGraph(data),
+	}, nil
+}
+
+// Indexes the DWARF data of a debug file and returns a Program ready for
+// lookups, which finds variables with no DWARF location in the file's
+// symbol table when it is an ELF, Mach-O or PE file
+//
+// The DWARF of an ELF file built with -gsplit-dwarf is read from the .dwp
+// package or .dwo files its skeleton units name, found through paths. The
+// index of an ELF file uses its .debug_names or .gdb_index when it has one.
+func NewProgramFromFile[T DebugFile](fh T, paths SearchPaths) (*Program, error) {
+	return NewProgramFromFileWithIndex(fh, paths, nil)
+}
+
+// Behaves like NewProgramFromFile, but rebuilds the index from saved rather
+// than walking the DWARF, as by NewIndexFromData
+func NewProgramFromFileWithIndex[T DebugFile](fh T, paths SearchPaths, saved *IndexData) (*Program, error) {
+	data, err := GetData(fh)
+	if err != nil {
+		return nil, err
+	}
+	var missing []error
+	var accel *accelTable
+	if f := asELF(fh); f != nil {
+		unsplit := data
+		if data, missing, err = loadSplitDWARF(f, data, paths); err != nil {
+			return nil, err
+		}
+		// The units of split DWARF are reassembled at new offsets
+		if data == unsplit && saved == nil {
+			if accel, err = readAccelTable(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p, err := newProgram(data, saved, accel)
+	if err != nil {
+		return nil, err
+	}
+	p.missingDwo = missing
+	if p.symbols, err = fileSymbols(fh); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Returns the ELF file behind a debug file, or nil if it is not one
+func asELF(fh interface{}) *ELFFile {
+	switch f := fh.(type) {
+	case *ELFFile:
+		return f
+	case *elf.File:
+		return &ELFFile{File: f}
+	default:
+		return nil
+	}
+}
+
+// Returns an error wrapping ErrNotFound for each .dwo file of the program
+// that could not be found, leaving the types and variables of its unit out
+// of the program
+func (p *Program) MissingDwo() []error {
+	return p.missingDwo
+}
+
+// Sets the symbols used to find variables with no DWARF location
+func (p *Program) SetSymbols(symbols SymbolTable) {
+	p.symbols = symbols
+}
+
+// Returns the DWARF data of this program
+func (p *Program) Data() *dwarf.Data {
+	return p.data
+}
+
+// Returns the name index of this program
+func (p *Program) Index() *Index {
+	return p.index
+}
+
+// Returns the type graph of this program
+func (p *Program) Types() *TypeGraph {
+	return p.types
+}
+
+// Returns a new reader positioned at the start of the DWARF
+//
+// Readers are cheap and must not be shared between goroutines.
+func (p *Program) Reader() *dwarf.Reader {
+	return p.data.Reader()
+}
+
+// Returns the byte order of the target this program was compiled for
+func (p *Program) ByteOrder() binary.ByteOrder {
+	return p.data.Reader().ByteOrder()
+}
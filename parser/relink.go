@@ -0,0 +1,458 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+)
+
+// Attribute forms written by the relinker, or rewritten in the abbreviations
+// of split units
+const (
+	formAddr          = 0x01
+	formString        = 0x08
+	formBlock         = 0x09
+	formFlag          = 0x0c
+	formSdata         = 0x0d
+	formUdata         = 0x0f
+	formRefAddr       = 0x10
+	formSecOffset     = 0x17
+	formExprloc       = 0x18
+	formStrx          = 0x1a
+	formAddrx         = 0x1b
+	formData16        = 0x1e
+	formImplicitConst = 0x21
+	formGNUAddrIndex  = 0x1f01
+	formGNUStrIndex   = 0x1f02
+)
+
+// Returns an abbreviation table with the GNU forms for indexed addresses and
+// strings replaced by their DWARF 5 equivalents, which debug/dwarf decodes
+func rewriteGNUForms(abbrev []byte) ([]byte, error) {
+	c := &cursor{b: abbrev}
+	var out []byte
+	for {
+		code := c.uleb()
+		out = appendULEB128(out, code)
+		if code == 0 || c.err != nil {
+			return out, c.err
+		}
+		// Tag and children flag
+		out = appendULEB128(out, c.uleb())
+		out = append(out, c.u8())
+		for {
+			attr, form := c.uleb(), c.uleb()
+			switch form {
+			case formGNUAddrIndex:
+				form = formAddrx
+			case formGNUStrIndex:
+				form = formStrx
+			}
+			out = appendULEB128(appendULEB128(out, attr), form)
+			if form == formImplicitConst {
+				n := c.lebLen()
+				out = append(out, c.bytes(n)...)
+			}
+			if attr == 0 && form == 0 {
+				break
+			}
+			if c.err != nil {
+				return nil, c.err
+			}
+		}
+	}
+}
+
+// Identifies an entry by the data it was read from and its offset there
+type relinkKey struct {
+	data *dwarf.Data
+	off  dwarf.Offset
+}
+
+// A reference to be patched once every entry has been placed
+type relinkFixup struct {
+	at     int
+	target relinkKey
+}
+
+// A relinker re-encodes units decoded by debug/dwarf, possibly from several
+// files, into the sections of a single DWARF 4 image
+//
+// Every unit shares one abbreviation table. Strings are written inline,
+// references as offsets into the new .debug_info, and range lists as
+// absolute addresses in a new .debug_ranges. Attributes pointing into
+// sections that are not carried over, such as location lists, are dropped.
+type relinker struct {
+	order   binary.ByteOrder
+	abbrev  []byte
+	info    []byte
+	ranges  []byte
+	codes   map[string]uint64
+	offsets map[relinkKey]uint32
+	fixups  []relinkFixup
+}
+
+func newRelinker(order binary.ByteOrder) *relinker {
+	return &relinker{
+		order:   order,
+		codes:   make(map[string]uint64),
+		offsets: make(map[relinkKey]uint32),
+	}
+}
+
+// Appends the unit whose DIE is at this offset in data
+//
+// dwo is the split unit being copied, or nil if the unit was not split.
+func (rl *relinker) unit(data *dwarf.Data, cu dwarf.Offset, dwo *dwoUnit) error {
+	r := data.Reader()
+	r.Seek(cu)
+	start := len(rl.info)
+	// The unit length is patched once the unit has been written
+	rl.info = append(rl.info, 0, 0, 0, 0)
+	rl.info = appendUint(rl.order, rl.info, 2, 4)
+	rl.info = appendUint(rl.order, rl.info, 4, 0)
+	rl.info = append(rl.info, byte(r.AddressSize()))
+
+	for depth := 0; ; {
+		e, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			return fmt.Errorf("Unit at %#x ends early: %w", cu, ErrUnsupportedForm)
+		}
+		if e.Tag == 0 {
+			rl.info = append(rl.info, 0)
+			depth--
+		} else {
+			if err := rl.entry(e, data, dwo, r.AddressSize()); err != nil {
+				return newEntryError(e, err)
+			}
+			if e.Children {
+				depth++
+			}
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	rl.order.PutUint32(rl.info[start:], uint32(len(rl.info)-start-4))
+	return nil
+}
+
+// Attributes of a skeleton unit that only describe how the unit was split
+var skeletonOnly = map[dwarf.Attr]bool{
+	dwarf.AttrDwoName:  true,
+	attrGNUDwoName:     true,
+	attrGNUDwoID:       true,
+	attrGNURangesBase:  true,
+	attrGNUAddrBase:    true,
+	dwarf.AttrAddrBase: true,
+}
+
+// Returns the attributes of a split unit's DIE combined with those of its
+// skeleton, which take precedence since they alone describe the binary
+func mergeSkeleton(cu, skel *dwarf.Entry) []dwarf.Field {
+	var fields []dwarf.Field
+	seen := make(map[dwarf.Attr]bool)
+	for _, list := range [][]dwarf.Field{skel.Field, cu.Field} {
+		for _, f := range list {
+			if skeletonOnly[f.Attr] || seen[f.Attr] {
+				continue
+			}
+			seen[f.Attr] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func (rl *relinker) entry(e *dwarf.Entry, data *dwarf.Data, dwo *dwoUnit, addrSize int) error {
+	rl.offsets[relinkKey{data, e.Offset}] = uint32(len(rl.info))
+	tag, fields := e.Tag, e.Field
+	var ranges [][2]uint64
+	var err error
+	switch {
+	case dwo != nil && e.Offset == dwo.cu:
+		tag = dwarf.TagCompileUnit
+		fields = mergeSkeleton(e, dwo.skeleton.entry)
+		if HasAttr(dwo.skeleton.entry, dwarf.AttrRanges) {
+			ranges = dwo.skeleton.ranges
+		} else if HasAttr(e, dwarf.AttrRanges) {
+			ranges, err = dwo.entryRanges(e)
+		}
+	case e.Tag == dwarf.TagSkeletonUnit:
+		// A skeleton kept without its split unit stands in for it
+		tag = dwarf.TagCompileUnit
+		fields = mergeSkeleton(e, e)
+		if HasAttr(e, dwarf.AttrRanges) {
+			ranges, err = data.Ranges(e)
+		}
+	case !HasAttr(e, dwarf.AttrRanges):
+	case dwo != nil:
+		ranges, err = dwo.entryRanges(e)
+	default:
+		ranges, err = data.Ranges(e)
+	}
+	if err != nil {
+		return err
+	}
+
+	spec := appendULEB128(nil, uint64(tag))
+	if e.Children {
+		spec = append(spec, 1)
+	} else {
+		spec = append(spec, 0)
+	}
+	var body []byte
+	var refs []relinkFixup
+	for _, f := range fields {
+		if f.Attr == dwarf.AttrSibling {
+			continue
+		}
+		var form uint64
+		switch f.Class {
+		case dwarf.ClassAddress:
+			v, ok := f.Val.(uint64)
+			if !ok {
+				continue
+			}
+			form = formAddr
+			body = appendUint(rl.order, body, addrSize, v)
+		case dwarf.ClassBlock, dwarf.ClassExprLoc:
+			v, ok := f.Val.([]byte)
+			if !ok {
+				continue
+			}
+			if dwo != nil {
+				v = dwo.rewriteExpr(v)
+			}
+			form = formExprloc
+			if f.Class == dwarf.ClassBlock {
+				form = formBlock
+			}
+			body = append(appendULEB128(body, uint64(len(v))), v...)
+		case dwarf.ClassConstant:
+			switch v := f.Val.(type) {
+			case int64:
+				form = formSdata
+				body = appendSLEB128(body, v)
+			case uint64:
+				form = formUdata
+				body = appendULEB128(body, v)
+			case []byte:
+				if len(v) != 16 {
+					continue
+				}
+				form = formData16
+				body = append(body, v...)
+			default:
+				continue
+			}
+		case dwarf.ClassFlag:
+			v, _ := f.Val.(bool)
+			form = formFlag
+			if v {
+				body = append(body, 1)
+			} else {
+				body = append(body, 0)
+			}
+		case dwarf.ClassLinePtr:
+			v, ok := f.Val.(int64)
+			if !ok {
+				continue
+			}
+			form = formSecOffset
+			body = appendUint(rl.order, body, 4, uint64(v))
+		case dwarf.ClassReference:
+			v, ok := f.Val.(dwarf.Offset)
+			if !ok {
+				continue
+			}
+			form = formRefAddr
+			refs = append(refs, relinkFixup{len(body), relinkKey{data, v}})
+			body = append(body, 0, 0, 0, 0)
+		case dwarf.ClassString:
+			v, ok := f.Val.(string)
+			if !ok {
+				continue
+			}
+			form = formString
+			body = append(append(body, v...), 0)
+		case dwarf.ClassRangeListPtr, dwarf.ClassRngList:
+			if f.Attr != dwarf.AttrRanges {
+				continue
+			}
+			form = formSecOffset
+			body = appendUint(rl.order, body, 4, uint64(rl.addRanges(ranges, addrSize)))
+		default:
+			continue
+		}
+		spec = appendULEB128(appendULEB128(spec, uint64(f.Attr)), form)
+	}
+	spec = append(spec, 0, 0)
+
+	code, ok := rl.codes[string(spec)]
+	if !ok {
+		code = uint64(len(rl.codes) + 1)
+		rl.codes[string(spec)] = code
+		rl.abbrev = append(appendULEB128(rl.abbrev, code), spec...)
+	}
+	rl.info = appendULEB128(rl.info, code)
+	for _, ref := range refs {
+		rl.fixups = append(rl.fixups, relinkFixup{len(rl.info) + ref.at, ref.target})
+	}
+	rl.info = append(rl.info, body...)
+	return nil
+}
+
+// Writes a range list to .debug_ranges and returns its offset
+//
+// The list opens by selecting a base address of zero, so that its ranges
+// hold whatever the base of the unit it ends up in.
+func (rl *relinker) addRanges(ranges [][2]uint64, addrSize int) int {
+	off := len(rl.ranges)
+	rl.ranges = appendUint(rl.order, rl.ranges, addrSize, ^uint64(0))
+	rl.ranges = appendUint(rl.order, rl.ranges, addrSize, 0)
+	for _, r := range ranges {
+		// An empty range would end the list
+		if r[0] == r[1] {
+			continue
+		}
+		rl.ranges = appendUint(rl.order, rl.ranges, addrSize, r[0])
+		rl.ranges = appendUint(rl.order, rl.ranges, addrSize, r[1])
+	}
+	rl.ranges = appendUint(rl.order, rl.ranges, addrSize, 0)
+	rl.ranges = appendUint(rl.order, rl.ranges, addrSize, 0)
+	return off
+}
+
+// Patches references and returns the relinked DWARF, reading line tables and
+// the strings they use from the sections of the binary
+func (rl *relinker) finish(line, str, lineStr []byte) (*dwarf.Data, error) {
+	for _, f := range rl.fixups {
+		off, ok := rl.offsets[f.target]
+		if !ok {
+			return nil, fmt.Errorf("Reference to %#x outside its unit: %w", f.target.off, ErrUnsupportedForm)
+		}
+		rl.order.PutUint32(rl.info[f.at:], off)
+	}
+	abbrev := append(rl.abbrev, 0)
+	data, err := dwarf.New(abbrev, nil, nil, rl.info, line, nil, rl.ranges, str)
+	if err != nil {
+		return nil, err
+	}
+	if err := data.AddSection(".debug_line_str", lineStr); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Appends an unsigned integer of 1, 2, 4 or 8 bytes
+func appendUint(order binary.ByteOrder, b []byte, size int, v uint64) []byte {
+	var buf [8]byte
+	switch size {
+	case 1:
+		buf[0] = byte(v)
+	case 2:
+		order.PutUint16(buf[:], uint16(v))
+	case 4:
+		order.PutUint32(buf[:], uint32(v))
+	default:
+		size = 8
+		order.PutUint64(buf[:], v)
+	}
+	return append(b, buf[:size]...)
+}
+
+func appendULEB128(b []byte, v uint64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func appendSLEB128(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		done := (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0)
+		if !done {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if done {
+			return b
+		}
+	}
+}
+
+// A cursor reads through a section, remembering the first read that runs
+// past its end
+type cursor struct {
+	b     []byte
+	off   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (c *cursor) bytes(n int) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if n < 0 || len(c.b)-c.off < n {
+		c.err = fmt.Errorf("Read past the end of a section at %#x: %w", c.off, ErrUnsupportedForm)
+		return nil
+	}
+	c.off += n
+	return c.b[c.off-n : c.off]
+}
+
+func (c *cursor) u8() byte {
+	b := c.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// Returns the number of bytes in the LEB128 number at the cursor, which is
+// left in place
+func (c *cursor) lebLen() int {
+	if c.err != nil || c.off > len(c.b) {
+		return 0
+	}
+	_, n := decodeULEB128(c.b[c.off:])
+	if n == 0 {
+		c.err = fmt.Errorf("Truncated LEB128 at %#x: %w", c.off, ErrUnsupportedForm)
+	}
+	return n
+}
+
+func (c *cursor) uleb() uint64 {
+	n := c.lebLen()
+	v, _ := decodeULEB128(c.bytes(n))
+	return v
+}
+
+func (c *cursor) addr(size int) uint64 {
+	b := c.bytes(size)
+	if b == nil {
+		return 0
+	}
+	switch size {
+	case 4:
+		return uint64(c.order.Uint32(b))
+	case 8:
+		return c.order.Uint64(b)
+	}
+	c.err = fmt.Errorf("Address size %d: %w", size, ErrUnsupportedForm)
+	return 0
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"fmt"
+)
+
+// How many DW_AT_specification and DW_AT_abstract_origin links are followed
+// from one entry, which guards against cycles in malformed DWARF
+const maxOriginDepth = 8
+
+// Returns the entry this one completes, through DW_AT_specification, or is
+// a concrete instance of, through DW_AT_abstract_origin
+func origin(entry *dwarf.Entry) (dwarf.Offset, bool) {
+	if off, ok := entry.Val(dwarf.AttrSpecification).(dwarf.Offset); ok {
+		return off, true
+	}
+	off, ok := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+	return off, ok
+}
+
+// Returns true if the entry places its variable or function in memory, or
+// is of a kind that is never completed elsewhere
+func isDefinition(entry *dwarf.Entry) bool {
+	switch entry.Tag {
+	case dwarf.TagMember:
+		// Static members are declared as members before DWARF 5
+		return !HasAttr(entry, dwarf.AttrDeclaration)
+	case dwarf.TagVariable, dwarf.TagFormalParameter:
+		return HasAttr(entry, dwarf.AttrLocation)
+	case dwarf.TagSubprogram:
+		return HasAttr(entry, dwarf.AttrLowpc) || HasAttr(entry, dwarf.AttrRanges)
+	default:
+		return true
+	}
+}
+
+// Returns a copy of this entry carrying the attributes it leaves to the
+// declaration it completes or the abstract instance it was made from
+//
+// C++ definitions of static members and out-of-class functions usually
+// have neither name nor type of their own, only a DW_AT_specification
+// pointing at the declaration; out-of-line copies of inlined functions do
+// the same through DW_AT_abstract_origin. Attributes of the entry itself
+// win over those it inherits. Entries linking to nothing are returned as
+// they are.
+func (idx *Index) Merge(entry *dwarf.Entry) (*dwarf.Entry, error) {
+	off, ok := origin(entry)
+	if !ok {
+		return entry, nil
+	}
+	merged := *entry
+	merged.Field = append([]dwarf.Field{}, entry.Field...)
+	r := idx.data.Reader()
+	for depth := 0; ok; depth++ {
+		if depth == maxOriginDepth {
+			return nil, newEntryError(entry, fmt.Errorf("DW_AT_specification nested too deeply: %w", ErrUnsupportedForm))
+		}
+		r.Seek(off)
+		target, err := r.Next()
+		if err != nil {
+			return nil, newEntryError(entry, err)
+		}
+		if target == nil {
+			return nil, newEntryError(entry, fmt.Errorf("No entry at offset %#x: %w", off, ErrNotFound))
+		}
+		for _, f := range target.Field {
+			switch f.Attr {
+			case dwarf.AttrDeclaration, dwarf.AttrSibling, dwarf.AttrSpecification, dwarf.AttrAbstractOrigin:
+				continue
+			}
+			if !HasAttr(&merged, f.Attr) {
+				merged.Field = append(merged.Field, f)
+			}
+		}
+		off, ok = origin(target)
+	}
+	return &merged, nil
+}
+
+// Returns the entry a lookup by name should give for this one: the
+// definition of a declaration, merged with the declaration, or the entry
+// merged with whatever it completes
+//
+// Declarations of variables and functions that are defined nowhere in the
+// DWARF are returned unchanged.
+func (idx *Index) Complete(entry *dwarf.Entry) (*dwarf.Entry, error) {
+	if _, ok := origin(entry); ok {
+		return idx.Merge(entry)
+	}
+	if isDefinition(entry) {
+		return entry, nil
+	}
+	def, err := idx.findDefinition(entry.Offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	if def == nil {
+		return entry, nil
+	}
+	return def, nil
+}
+
+// Searches the entries completing the one at this offset, and those
+// completing them in turn, for one that is a definition
+func (idx *Index) findDefinition(decl dwarf.Offset, depth int) (*dwarf.Entry, error) {
+	if depth == maxOriginDepth {
+		return nil, nil
+	}
+	for _, ie := range idx.completing(decl) {
+		e, err := idx.Entry(ie)
+		if err != nil {
+			return nil, err
+		}
+		if isDefinition(e) {
+			return idx.Merge(e)
+		}
+		if def, err := idx.findDefinition(e.Offset, depth+1); err != nil || def != nil {
+			return def, err
+		}
+	}
+	return nil, nil
+}
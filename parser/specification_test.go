@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+// Builds DWARF for:
+//
+//	struct Counter {
+//	  static int count;     // declared as a member, as before DWARF 5
+//	  static int limit;     // declared as a variable, as in DWARF 5
+//	  int next();
+//	};
+//	int Counter::count;     // at 0x5000
+//	int Counter::limit;     // at 0x5004
+//	int Counter::next() {}  // at [0x1000, 0x1010)
+//	inline int sq(int x) {} // inlined, with a copy at [0x2000, 0x2008)
+func buildSpecifications(t *testing.T) *Program {
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	countDecl := dwarftest.New(dwarf.TagMember,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "count"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrDeclaration, Val: true})
+	limitDecl := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "limit"},
+		dwarftest.Attr{Attr: dwarf.AttrLinkageName, Val: "_ZN7Counter5limitE"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrDeclaration, Val: true})
+	nextDecl := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "next"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrDeclaration, Val: true})
+	counter := dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "Counter"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 1},
+	).With(countDecl, limitDecl, nextDecl)
+	// DW_OP_addr 0x5000 and 0x5004
+	countDef := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrSpecification, Val: countDecl},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x03, 0x00, 0x50, 0, 0, 0, 0, 0, 0}})
+	limitDef := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrSpecification, Val: limitDecl},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x03, 0x04, 0x50, 0, 0, 0, 0, 0, 0}})
+	nextDef := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrSpecification, Val: nextDecl},
+		dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x1000)},
+		dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x10})
+	x := dwarftest.New(dwarf.TagFormalParameter,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "x"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType})
+	sq := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "sq"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrInline, Val: 3},
+	).With(x)
+	sqCopy := dwarftest.New(dwarf.TagSubprogram,
+		dwarftest.Attr{Attr: dwarf.AttrAbstractOrigin, Val: sq},
+		dwarftest.Attr{Attr: dwarf.AttrLowpc, Val: uint64(0x2000)},
+		dwarftest.Attr{Attr: dwarf.AttrHighpc, Val: 0x8},
+	).With(
+		// DW_OP_fbreg -4
+		dwarftest.New(dwarf.TagFormalParameter,
+			dwarftest.Attr{Attr: dwarf.AttrAbstractOrigin, Val: x},
+			dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x91, 0x7c}}),
+	)
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "counter.cpp"},
+	).With(intType, counter, countDef, limitDef, nextDef, sq, sqCopy)
+	data, err := dwarftest.Build(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := NewProgram(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func TestSpecificationVariables(t *testing.T) {
+	prog := buildSpecifications(t)
+
+	for name, addr := range map[string]int{"count": 0x5000, "limit": 0x5004} {
+		e, _, err := prog.Index().GetEntry(name)
+		assert.NoError(t, err)
+		assert.Equal(t, dwarf.TagVariable, e.Tag, name)
+		v, err := prog.Variable(e)
+		assert.NoError(t, err, name)
+		assert.Equal(t, name, v.Name())
+		assert.Equal(t, addr, v.Address)
+		assert.Equal(t, 32, v.Type.BitSize())
+		assert.Equal(t, SourceDWARF, v.Source)
+	}
+
+	// The merged entry keeps attributes only the declaration has
+	e, _, err := prog.Index().GetEntry("limit")
+	assert.NoError(t, err)
+	assert.Equal(t, "_ZN7Counter5limitE", e.Val(dwarf.AttrLinkageName))
+	assert.False(t, HasAttr(e, dwarf.AttrDeclaration))
+
+	// Variables are found by symbolizing their addresses too
+	sym, err := prog.Symbolize(0x5004)
+	assert.NoError(t, err)
+	assert.Equal(t, "limit", sym.Variable)
+}
+
+func TestSpecificationFunctions(t *testing.T) {
+	prog := buildSpecifications(t)
+
+	e, _, err := prog.Index().GetEntry("next")
+	assert.NoError(t, err)
+	f, err := NewFunctionProxy(prog, e)
+	assert.NoError(t, err)
+	assert.Equal(t, "next", f.Name())
+	assert.Equal(t, uint64(0x1000), f.LowPC)
+	assert.Equal(t, "int next()", f.Signature())
+
+	// The out-of-line copy of an inlined function takes the names and types
+	// of its parameters from the abstract instance
+	e, _, err = prog.Index().GetEntry("sq")
+	assert.NoError(t, err)
+	f, err = NewFunctionProxy(prog, e)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0x2000), f.LowPC)
+	assert.Equal(t, "int sq(int x)", f.Signature())
+	assert.Equal(t, []byte{0x91, 0x7c}, f.Params[0].Location)
+}
+
+func TestMergeUnlinked(t *testing.T) {
+	prog := buildSpecifications(t)
+	e, _, err := prog.Index().GetEntry("int")
+	assert.NoError(t, err)
+	merged, err := prog.Index().Merge(e)
+	assert.NoError(t, err)
+	assert.Same(t, e, merged)
+}
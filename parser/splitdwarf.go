@@ -0,0 +1,629 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Where to look for the files holding the DWARF of a binary, such as the
+// .dwo files and .dwp package of a binary built with -gsplit-dwarf
+type SearchPaths struct {
+	// The path of the binary itself, whose directory is searched first
+	Binary string
+	// Further directories to search, in order
+	Dirs []string
+}
+
+// GNU extensions used by split DWARF before DWARF 5, which debug/dwarf does
+// not name
+const (
+	attrGNUDwoName    dwarf.Attr = 0x2130
+	attrGNUDwoID      dwarf.Attr = 0x2131
+	attrGNURangesBase dwarf.Attr = 0x2132
+	attrGNUAddrBase   dwarf.Attr = 0x2133
+)
+
+// Unit types from the DWARF 5 unit headers
+const (
+	utType         = 0x02
+	utSkeleton     = 0x04
+	utSplitCompile = 0x05
+	utSplitType    = 0x06
+)
+
+// The header of a unit in .debug_info
+type unitHeader struct {
+	// Offset of the header and of the unit's first DIE
+	offset int
+	die    dwarf.Offset
+	// Offset just past the end of the unit
+	end      int
+	version  int
+	unitType byte
+	dwarf64  bool
+	addrSize int
+	// Where in the header the abbreviation table offset is stored
+	abbrevAt int
+	abbrev   uint64
+	// The dwo_id of skeleton and split units in DWARF 5
+	id uint64
+}
+
+// Parses the headers of every unit in a .debug_info section
+func unitHeaders(info []byte, order binary.ByteOrder) ([]unitHeader, error) {
+	var units []unitHeader
+	for off := 0; off < len(info); {
+		h := unitHeader{offset: off}
+		if len(info)-off < 4 {
+			return nil, fmt.Errorf("Truncated unit header at %#x: %w", off, ErrUnsupportedForm)
+		}
+		size := uint64(order.Uint32(info[off:]))
+		at := off + 4
+		if size == 0xffffffff {
+			if len(info)-at < 8 {
+				return nil, fmt.Errorf("Truncated unit header at %#x: %w", off, ErrUnsupportedForm)
+			}
+			size = order.Uint64(info[at:])
+			at += 8
+			h.dwarf64 = true
+		}
+		if size > uint64(len(info)-at) {
+			return nil, fmt.Errorf("Unit at %#x runs past the end of .debug_info: %w", off, ErrUnsupportedForm)
+		}
+		h.end = at + int(size)
+		offSize := 4
+		if h.dwarf64 {
+			offSize = 8
+		}
+		if h.end-at < 4 {
+			return nil, fmt.Errorf("Truncated unit header at %#x: %w", off, ErrUnsupportedForm)
+		}
+		h.version = int(order.Uint16(info[at:]))
+		at += 2
+		// Address size and abbreviation offset, then the unit type and any
+		// id or type signature in DWARF 5
+		need := offSize + 1
+		if h.version >= 5 {
+			h.unitType = info[at]
+			need++
+			switch h.unitType {
+			case utSkeleton, utSplitCompile:
+				need += 8
+			case utType, utSplitType:
+				need += 8 + offSize
+			}
+		}
+		if h.end-at < need {
+			return nil, fmt.Errorf("Truncated unit header at %#x: %w", off, ErrUnsupportedForm)
+		}
+		if h.version >= 5 {
+			h.addrSize = int(info[at+1])
+			at += 2
+		}
+		h.abbrevAt = at
+		if h.dwarf64 {
+			h.abbrev = order.Uint64(info[at:])
+		} else {
+			h.abbrev = uint64(order.Uint32(info[at:]))
+		}
+		at += offSize
+		if h.version < 5 {
+			h.addrSize = int(info[at])
+			at++
+		}
+		switch h.unitType {
+		case utSkeleton, utSplitCompile:
+			h.id = order.Uint64(info[at:])
+			at += 8
+		case utType, utSplitType:
+			// Type signature and offset
+			at += 8 + offSize
+		}
+		h.die = dwarf.Offset(at)
+		units = append(units, h)
+		off = h.end
+	}
+	return units, nil
+}
+
+// Returns the contents of an ELF section, or nil if the file has none by
+// that name
+// A skeleton unit left in a binary by -gsplit-dwarf, which names the .dwo
+// file holding the rest of its DWARF
+type skeleton struct {
+	entry    *dwarf.Entry
+	header   unitHeader
+	dwoName  string
+	compDir  string
+	id       uint64
+	addrBase uint64
+	// Where the unit's range lists start in the binary's .debug_ranges,
+	// before DWARF 5
+	rangesBase uint64
+	lowPC      uint64
+	// The ranges of the whole unit, if it lists them
+	ranges [][2]uint64
+}
+
+// Returns the skeleton described by this unit entry, or false if the unit
+// is not split
+func newSkeleton(cu *dwarf.Entry, h unitHeader) (*skeleton, bool) {
+	s := &skeleton{entry: cu, header: h}
+	var ok bool
+	if s.dwoName, ok = cu.Val(dwarf.AttrDwoName).(string); ok {
+		s.id = h.id
+		if base, ok := cu.Val(dwarf.AttrAddrBase).(int64); ok {
+			s.addrBase = uint64(base)
+		}
+	} else if s.dwoName, ok = cu.Val(attrGNUDwoName).(string); ok {
+		if id, ok := cu.Val(attrGNUDwoID).(int64); ok {
+			s.id = uint64(id)
+		}
+		if base, ok := cu.Val(attrGNUAddrBase).(int64); ok {
+			s.addrBase = uint64(base)
+		}
+		if base, ok := cu.Val(attrGNURangesBase).(int64); ok {
+			s.rangesBase = uint64(base)
+		}
+	} else {
+		return nil, false
+	}
+	s.compDir, _ = cu.Val(dwarf.AttrCompDir).(string)
+	s.lowPC, _ = cu.Val(dwarf.AttrLowpc).(uint64)
+	return s, true
+}
+
+// Returns the paths a .dwo file may be found at, in the order they are
+// tried: as named by the skeleton, then by name and by base name in the
+// directory of the binary and in each search directory
+func (s *skeleton) candidates(paths SearchPaths) []string {
+	var out []string
+	if filepath.IsAbs(s.dwoName) {
+		out = append(out, s.dwoName)
+	} else if s.compDir != "" {
+		out = append(out, filepath.Join(s.compDir, s.dwoName))
+	}
+	dirs := paths.Dirs
+	if paths.Binary != "" {
+		dirs = append([]string{filepath.Dir(paths.Binary)}, dirs...)
+	}
+	for _, dir := range dirs {
+		if !filepath.IsAbs(s.dwoName) {
+			out = append(out, filepath.Join(dir, s.dwoName))
+		}
+		out = append(out, filepath.Join(dir, filepath.Base(s.dwoName)))
+	}
+	return out
+}
+
+// Returned when a .dwo file or .dwp package holds no unit with the id of a
+// skeleton
+var errDwoMismatch = errors.New("dwo id mismatch")
+
+// Replaces the skeleton units of a binary built with -gsplit-dwarf by the
+// full units held in its .dwp package or .dwo files, returning the data
+// unchanged if it has no skeletons
+//
+// The units are reassembled into a single DWARF 4 image, resolving the
+// indexed addresses, strings and range lists of the split units against
+// the skeleton's sections as they go, so that the rest of the parser sees
+// one program as if it had been built without -gsplit-dwarf.
+//
+// A skeleton whose .dwo file cannot be found is kept as it is, with its line
+// table but none of its types or variables, and reported in the returned
+// errors rather than failing the load.
+func loadSplitDWARF(f *ELFFile, data *dwarf.Data, paths SearchPaths) (*dwarf.Data, []error, error) {
+	var cus []*dwarf.Entry
+	split := false
+	r := data.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == dwarf.TagSkeletonUnit || HasAttr(e, dwarf.AttrDwoName) || HasAttr(e, attrGNUDwoName) {
+			split = true
+		}
+		cus = append(cus, e)
+		r.SkipChildren()
+	}
+	if !split {
+		return data, nil, nil
+	}
+
+	order := f.ByteOrder
+	sections := make(map[string][]byte)
+	for _, name := range []string{".debug_info", ".debug_addr", ".debug_ranges", ".debug_line", ".debug_str", ".debug_line_str"} {
+		b, err := f.SectionData(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		sections[name] = b
+	}
+	headers, err := unitHeaders(sections[".debug_info"], order)
+	if err != nil {
+		return nil, nil, err
+	}
+	byDIE := make(map[dwarf.Offset]unitHeader, len(headers))
+	for _, h := range headers {
+		byDIE[h.die] = h
+	}
+
+	pkg, err := openPackage(paths, order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rl := newRelinker(order)
+	var missing []error
+	for _, cu := range cus {
+		h, ok := byDIE[cu.Offset]
+		if !ok {
+			return nil, nil, fmt.Errorf("No unit header for the unit at %#x: %w", cu.Offset, ErrUnsupportedForm)
+		}
+		skel, ok := newSkeleton(cu, h)
+		if !ok {
+			if err := rl.unit(data, cu.Offset, nil); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if HasAttr(cu, dwarf.AttrRanges) {
+			if skel.ranges, err = data.Ranges(cu); err != nil {
+				return nil, nil, err
+			}
+		}
+		if skel.addrBase > uint64(len(sections[".debug_addr"])) {
+			return nil, nil, fmt.Errorf("Address base %#x of %s is past the end of .debug_addr: %w", skel.addrBase, skel.dwoName, ErrUnsupportedForm)
+		}
+		addrs := sections[".debug_addr"][skel.addrBase:]
+
+		var dwo *dwoUnit
+		if pkg != nil {
+			dwo, err = pkg.unit(skel, addrs)
+			if err != nil && !errors.Is(err, errDwoMismatch) {
+				return nil, nil, err
+			}
+		}
+		if dwo == nil {
+			dwo, err = findDwo(skel, paths, addrs, order)
+			if errors.Is(err, ErrNotFound) {
+				missing = append(missing, err)
+				if err := rl.unit(data, cu.Offset, nil); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		dwo.skeleton = skel
+		dwo.ranges = sections[".debug_ranges"]
+		if err := rl.unit(dwo.data, dwo.cu, dwo); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", skel.dwoName, err)
+		}
+	}
+	out, err := rl.finish(sections[".debug_line"], sections[".debug_str"], sections[".debug_line_str"])
+	return out, missing, err
+}
+
+// Searches for the .dwo file of a skeleton
+func findDwo(skel *skeleton, paths SearchPaths, addrs []byte, order binary.ByteOrder) (*dwoUnit, error) {
+	for _, path := range skel.candidates(paths) {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		dwo, err := openDwo(path, skel, addrs, order)
+		if errors.Is(err, errDwoMismatch) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return dwo, nil
+	}
+	return nil, fmt.Errorf("No .dwo file with id %#x for %s: %w", skel.id, skel.dwoName, ErrNotFound)
+}
+
+// Reads the unit of a skeleton from a .dwo file
+func openDwo(path string, skel *skeleton, addrs []byte, order binary.ByteOrder) (*dwoUnit, error) {
+	f, err := OpenELF(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var s dwoSections
+	for name, dst := range s.fields() {
+		if *dst, err = f.SectionData(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.unit(skel, addrs, order)
+}
+
+// The sections of a .dwo file, or the contributions of one unit to the
+// sections of a .dwp package
+type dwoSections struct {
+	info, abbrev, str, strOffsets, rnglists []byte
+}
+
+func (s *dwoSections) fields() map[string]*[]byte {
+	return map[string]*[]byte{
+		".debug_info.dwo":        &s.info,
+		".debug_abbrev.dwo":      &s.abbrev,
+		".debug_str.dwo":         &s.str,
+		".debug_str_offsets.dwo": &s.strOffsets,
+		".debug_rnglists.dwo":    &s.rnglists,
+	}
+}
+
+// The full unit of a skeleton, read from a .dwo file or .dwp package
+type dwoUnit struct {
+	data *dwarf.Data
+	cu   dwarf.Offset
+	// The skeleton's .debug_addr from its address base on, which indexed
+	// addresses are resolved against
+	addrs    []byte
+	skeleton *skeleton
+	// The binary's .debug_ranges, where range lists live before DWARF 5
+	ranges []byte
+	// The unit's .debug_rnglists past its header, in DWARF 5, and the length
+	// of that header
+	rnglists       []byte
+	rnglistsHeader int
+	version        int
+	addrSize       int
+	order          binary.ByteOrder
+}
+
+// Finds the unit matching a skeleton in these sections and decodes it
+//
+// debug/dwarf resolves DW_FORM_strx and DW_FORM_addrx against the bases
+// named by the unit DIE, which a split unit leaves out. Since each unit gets
+// a dwarf.Data of its own, the tables are instead sliced so that they start
+// at the unit's entries and the bases can be left at zero.
+func (s *dwoSections) unit(skel *skeleton, addrs []byte, order binary.ByteOrder) (*dwoUnit, error) {
+	headers, err := unitHeaders(s.info, order)
+	if err != nil {
+		return nil, err
+	}
+	var h *unitHeader
+	for i := range headers {
+		u := &headers[i]
+		// Before DWARF 5 the id is an attribute of the unit DIE, checked
+		// once the unit is decoded
+		if (u.version < 5 && u.unitType == 0) || (u.unitType == utSplitCompile && u.id == skel.id) {
+			h = u
+			break
+		}
+	}
+	if h == nil {
+		return nil, errDwoMismatch
+	}
+	if h.abbrev > uint64(len(s.abbrev)) {
+		return nil, fmt.Errorf("Abbreviation offset %#x is past the end of the table: %w", h.abbrev, ErrUnsupportedForm)
+	}
+	abbrev, err := rewriteGNUForms(s.abbrev[h.abbrev:])
+	if err != nil {
+		return nil, err
+	}
+	info := append([]byte(nil), s.info[h.offset:h.end]...)
+	if h.dwarf64 {
+		order.PutUint64(info[h.abbrevAt-h.offset:], 0)
+	} else {
+		order.PutUint32(info[h.abbrevAt-h.offset:], 0)
+	}
+	data, err := dwarf.New(abbrev, nil, nil, info, nil, nil, nil, s.str)
+	if err != nil {
+		return nil, err
+	}
+
+	// DWARF 5 string offset and range list tables begin with a header
+	strOffsets, rnglists := s.strOffsets, s.rnglists
+	if h.version >= 5 {
+		strOffsets = skipListHeader(strOffsets, 8, order)
+		rnglists = skipListHeader(rnglists, 12, order)
+	}
+	data.AddSection(".debug_str_offsets", strOffsets)
+	data.AddSection(".debug_addr", addrs)
+	data.AddSection(".debug_rnglists", rnglists)
+
+	cu := h.die - dwarf.Offset(h.offset)
+	if h.version < 5 {
+		r := data.Reader()
+		r.Seek(cu)
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, errDwoMismatch
+		}
+		if id, ok := entry.Val(attrGNUDwoID).(int64); !ok || uint64(id) != skel.id {
+			return nil, errDwoMismatch
+		}
+	}
+	return &dwoUnit{
+		data:           data,
+		cu:             cu,
+		addrs:          addrs,
+		rnglists:       rnglists,
+		rnglistsHeader: len(s.rnglists) - len(rnglists),
+		version:        h.version,
+		addrSize:       h.addrSize,
+		order:          order,
+	}, nil
+}
+
+// Returns a DWARF 5 table past its header, which is this long in the 32-bit
+// format and 8 bytes longer in the 64-bit one
+func skipListHeader(b []byte, size int, order binary.ByteOrder) []byte {
+	if len(b) >= 4 && order.Uint32(b) == 0xffffffff {
+		size += 8
+	}
+	if len(b) < size {
+		return nil
+	}
+	return b[size:]
+}
+
+// Returns the address at this index in the skeleton's address table
+func (d *dwoUnit) addr(idx uint64) (uint64, error) {
+	size := uint64(d.addrSize)
+	if idx >= uint64(len(d.addrs))/size {
+		return 0, fmt.Errorf("Address index %d is past the end of .debug_addr: %w", idx, ErrUnsupportedForm)
+	}
+	c := &cursor{b: d.addrs, off: int(idx * size), order: d.order}
+	return c.addr(d.addrSize), c.err
+}
+
+// Decodes the DW_AT_ranges of an entry in this unit
+//
+// debug/dwarf cannot be relied on for these, since it takes the base
+// address of a list from the unit, which only the skeleton records.
+func (d *dwoUnit) entryRanges(e *dwarf.Entry) ([][2]uint64, error) {
+	// debug/dwarf gives a DW_FORM_rnglistx as a uint64 and a
+	// DW_FORM_sec_offset as an int64
+	var off uint64
+	switch v := e.Val(dwarf.AttrRanges).(type) {
+	case uint64:
+		off = v
+	case int64:
+		off = uint64(v)
+		// which counts from the start of the table's header rather than
+		// from its first list
+		if d.version >= 5 {
+			off -= uint64(d.rnglistsHeader)
+		}
+	default:
+		return nil, fmt.Errorf("DW_AT_ranges: %w", ErrUnsupportedForm)
+	}
+	if d.version < 5 {
+		return d.rangeList(d.skeleton.rangesBase + off)
+	}
+	return d.rnglist(off)
+}
+
+// Decodes a DWARF 5 range list from the unit's .debug_rnglists
+func (d *dwoUnit) rnglist(off uint64) ([][2]uint64, error) {
+	if off > uint64(len(d.rnglists)) {
+		return nil, fmt.Errorf("Range list at %#x is past the end of .debug_rnglists: %w", off, ErrUnsupportedForm)
+	}
+	c := &cursor{b: d.rnglists, off: int(off), order: d.order}
+	base := d.skeleton.lowPC
+	var out [][2]uint64
+	for c.err == nil {
+		var start, end uint64
+		var err error
+		switch kind := c.u8(); kind {
+		case 0x00: // DW_RLE_end_of_list
+			return out, c.err
+		case 0x01: // DW_RLE_base_addressx
+			base, err = d.addr(c.uleb())
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case 0x02: // DW_RLE_startx_endx
+			if start, err = d.addr(c.uleb()); err == nil {
+				end, err = d.addr(c.uleb())
+			}
+		case 0x03: // DW_RLE_startx_length
+			if start, err = d.addr(c.uleb()); err == nil {
+				end = start + c.uleb()
+			}
+		case 0x04: // DW_RLE_offset_pair
+			start = base + c.uleb()
+			end = base + c.uleb()
+		case 0x05: // DW_RLE_base_address
+			base = c.addr(d.addrSize)
+			continue
+		case 0x06: // DW_RLE_start_end
+			start = c.addr(d.addrSize)
+			end = c.addr(d.addrSize)
+		case 0x07: // DW_RLE_start_length
+			start = c.addr(d.addrSize)
+			end = start + c.uleb()
+		default:
+			return nil, fmt.Errorf("Range list entry kind %#x: %w", kind, ErrUnsupportedForm)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, [2]uint64{start, end})
+	}
+	return nil, c.err
+}
+
+// Decodes a range list from the binary's .debug_ranges, as split units
+// before DWARF 5 refer to
+func (d *dwoUnit) rangeList(off uint64) ([][2]uint64, error) {
+	if off > uint64(len(d.ranges)) {
+		return nil, fmt.Errorf("Range list at %#x is past the end of .debug_ranges: %w", off, ErrUnsupportedForm)
+	}
+	c := &cursor{b: d.ranges, off: int(off), order: d.order}
+	base := d.skeleton.lowPC
+	maxAddr := ^uint64(0) >> (64 - 8*uint(d.addrSize))
+	var out [][2]uint64
+	for c.err == nil {
+		start, end := c.addr(d.addrSize), c.addr(d.addrSize)
+		switch {
+		case start == 0 && end == 0:
+			return out, c.err
+		case start == maxAddr:
+			base = end
+		default:
+			out = append(out, [2]uint64{base + start, base + end})
+		}
+	}
+	return nil, c.err
+}
+
+// Returns an expression with the operations that index the skeleton's
+// address table replaced by the addresses and constants they stand for
+//
+// The expression is returned as it is if it holds operations this cannot
+// step over, or branches whose targets would move.
+func (d *dwoUnit) rewriteExpr(expr []byte) []byte {
+	var out []byte
+	changed := false
+	for i := 0; i < len(expr); {
+		op := expr[i]
+		n, ok := exprOperandLen(expr[i+1:], op, d.addrSize)
+		if !ok || op == opBra || op == opSkip || i+1+n > len(expr) {
+			return expr
+		}
+		switch op {
+		case opAddrx, opGNUAddrIndex, opConstx, opGNUConstIndex:
+			idx, _ := decodeULEB128(expr[i+1:])
+			v, err := d.addr(idx)
+			if err != nil {
+				return expr
+			}
+			if op == opAddrx || op == opGNUAddrIndex {
+				out = append(out, opAddr)
+			} else if d.addrSize == 4 {
+				out = append(out, opConst4u)
+			} else {
+				out = append(out, opConst8u)
+			}
+			out = appendUint(d.order, out, d.addrSize, v)
+			changed = true
+		default:
+			out = append(out, expr[i:i+1+n]...)
+		}
+		i += 1 + n
+	}
+	if !changed {
+		return expr
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Built from testdata/split by its build.sh
+var splitFlavours = []string{"dwarf5", "dwarf5-dwp", "dwarf4", "dwarf4-dwp"}
+
+func loadSplit(t *testing.T, path string, dirs ...string) (*Program, *elf.File) {
+	f, err := elf.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	prog, err := NewProgramFromFile(f, SearchPaths{Binary: path, Dirs: dirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog, f
+}
+
+func elfFunctions(t *testing.T, f *elf.File) map[string]uint64 {
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	funcs := make(map[string]uint64)
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+			funcs[s.Name] = s.Value
+		}
+	}
+	return funcs
+}
+
+func TestSplitDWARF(t *testing.T) {
+	for _, flavour := range splitFlavours {
+		t.Run(flavour, func(t *testing.T) {
+			prog, f := loadSplit(t, filepath.Join("testdata/split", flavour, "split.out"))
+			syms, err := ELFSymbols(f)
+			assert.NoError(t, err)
+
+			// Addresses come from DW_OP_addrx, resolved against the skeleton
+			for name, size := range map[string]int{
+				"_ZN2f18championE": 12,
+				"_ZN4Team5countE":  4,
+				"redbull":          24,
+				"fastest":          16,
+				"laps_completed":   4,
+			} {
+				e, _, err := prog.Index().GetEntry(name)
+				if !assert.NoError(t, err, name) {
+					continue
+				}
+				v, err := prog.Variable(e)
+				if !assert.NoError(t, err, name) {
+					continue
+				}
+				assert.Equal(t, SourceDWARF, v.Source, name)
+				assert.Equal(t, syms[name], uint64(v.Address), name)
+				assert.Equal(t, 8*size, v.Type.BitSize(), name)
+			}
+
+			// Strings come from DW_FORM_strx
+			v, _, err := prog.Index().GetEntry("champion")
+			assert.NoError(t, err)
+			assert.Equal(t, "_ZN2f18championE", LinkageName(v))
+
+			funcs := elfFunctions(t, f)
+			e, _, err := prog.Index().GetEntry("points")
+			assert.NoError(t, err)
+			fn, err := NewFunctionProxy(prog, e)
+			assert.NoError(t, err)
+			assert.Equal(t, funcs["_Z6pointsRK6Driveri"], fn.LowPC)
+			assert.Equal(t, "int points(const Driver& d, int races)", fn.Signature())
+
+			// The hot and cold parts of complete_lap come from a range list
+			e, _, err = prog.Index().GetEntry("complete_lap")
+			assert.NoError(t, err)
+			fn, err = NewFunctionProxy(prog, e)
+			assert.NoError(t, err)
+			if assert.Len(t, fn.Ranges, 2) {
+				assert.Equal(t, funcs["_Z12complete_lapi"], fn.Ranges[0][0])
+				assert.Equal(t, funcs["_Z12complete_lapi.cold"], fn.Ranges[1][0])
+			}
+
+			// Line tables stay with the skeleton
+			l, err := prog.AddrToLine(funcs["main"])
+			assert.NoError(t, err)
+			assert.True(t, strings.HasSuffix(l.File, "main.cpp"), l.File)
+			sym, err := prog.Symbolize(syms["redbull"] + 12 + 4)
+			assert.NoError(t, err)
+			assert.Equal(t, "redbull", sym.Variable)
+		})
+	}
+}
+
+func TestSplitDWARFSearch(t *testing.T) {
+	// A binary moved away from its .dwo files finds them through the search
+	// directories
+	dir := t.TempDir()
+	b, err := os.ReadFile("testdata/split/dwarf5/split.out")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "split.out")
+	assert.NoError(t, os.WriteFile(path, b, 0o644))
+
+	// Without them, it still loads with only its skeleton units
+	prog, f := loadSplit(t, path)
+	missing := prog.MissingDwo()
+	assert.Len(t, missing, 3)
+	for _, err := range missing {
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	_, _, err = prog.Index().GetEntry("redbull")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = prog.Index().GetEntry("Team")
+	assert.ErrorIs(t, err, ErrNotFound)
+	l, err := prog.AddrToLine(elfFunctions(t, f)["main"])
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(l.File, "main.cpp"), l.File)
+
+	prog, _ = loadSplit(t, path, "testdata/split/dwarf4", "testdata/split/dwarf5")
+	assert.Empty(t, prog.MissingDwo())
+	_, _, err = prog.Index().GetEntry("redbull")
+	assert.NoError(t, err)
+}
+
+func TestSplitDWARFUnsplit(t *testing.T) {
+	// DWARF that was never split is handed back untouched
+	f, err := elf.Open(testcaseFilename)
+	assert.NoError(t, err)
+	defer f.Close()
+	data, err := f.DWARF()
+	assert.NoError(t, err)
+	out, missing, err := loadSplitDWARF(&ELFFile{File: f}, data, SearchPaths{})
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Same(t, data, out)
+}
+
+func TestRewriteExpr(t *testing.T) {
+	d := &dwoUnit{
+		addrs:    []byte{0x10, 0x40, 0, 0, 0, 0, 0, 0, 0x20, 0x40, 0, 0, 0, 0, 0, 0},
+		addrSize: 8,
+		order:    binary.LittleEndian,
+	}
+	// DW_OP_addrx 1; DW_OP_plus_uconst 4
+	assert.Equal(t,
+		[]byte{opAddr, 0x20, 0x40, 0, 0, 0, 0, 0, 0, opPlusUconst, 4},
+		d.rewriteExpr([]byte{opAddrx, 1, opPlusUconst, 4}))
+	// DW_OP_GNU_const_index 0; DW_OP_GNU_push_tls_address
+	assert.Equal(t,
+		[]byte{opConst8u, 0x10, 0x40, 0, 0, 0, 0, 0, 0, 0xe0},
+		d.rewriteExpr([]byte{opGNUConstIndex, 0, 0xe0}))
+	// Expressions that branch, or index past the table, are left alone
+	for _, expr := range [][]byte{
+		{opAddrx, 0, opBra, 0, 0},
+		{opAddrx, 2},
+		{opFbreg, 0x7c},
+	} {
+		assert.Equal(t, expr, d.rewriteExpr(expr))
+	}
+}
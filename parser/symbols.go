@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"sort"
+)
+
+// A Symbol names the part of a variable an address falls inside
+type Symbol struct {
+	// The variable containing the address
+	Variable string
+	// The address of the variable
+	Address uint64
+	// Path to the innermost member or element containing the address, as
+	// accepted by VariableProxy.Field, for example "[1].drivers[0].car_number"
+	Path string
+	// Bytes from the start of that member or element to the address
+	Offset int
+}
+
+// Returns the symbol written as in C, for example
+// "formula_1_teams[1].drivers[0].car_number + 2"
+func (s Symbol) String() string {
+	str := s.Variable
+	if s.Path != "" && s.Path[0] != '[' {
+		str += "."
+	}
+	str += s.Path
+	if s.Offset != 0 {
+		str += fmt.Sprintf(" + %d", s.Offset)
+	}
+	return str
+}
+
+// The extent of one variable in memory
+type addrRange struct {
+	start, end uint64
+	variable   dwarf.Offset
+	// The highest end of this and every range starting before it, so that
+	// lookups know when to stop searching backwards
+	maxEnd uint64
+}
+
+// Returns every variable with a static address, sorted by address, reading
+// them the first time they are needed
+//
+// Globals the DWARF gives no location are included when the symbol table
+// places them, as by Variable, once for each address.
+func (p *Program) addrRanges() ([]addrRange, error) {
+	p.addrRangesOnce.Do(func() {
+		r := p.data.Reader()
+		ranges := make([]addrRange, 0)
+		fromSymbols := make(map[uint64]bool)
+		// Whether each enclosing entry is inside a function, whose locals
+		// are never looked up in the symbol table
+		inFunction := []bool{false}
+		for {
+			entry, err := r.Next()
+			if err != nil {
+				p.addrRangesErr = err
+				return
+			}
+			if entry == nil {
+				break
+			}
+			if entry.Tag == 0 {
+				if len(inFunction) > 1 {
+					inFunction = inFunction[:len(inFunction)-1]
+				}
+				continue
+			}
+			local := inFunction[len(inFunction)-1]
+			if entry.Children {
+				inFunction = append(inFunction, local || entry.Tag == dwarf.TagSubprogram)
+			}
+			if entry.Tag != dwarf.TagVariable {
+				continue
+			}
+			loc, ok := entry.Val(dwarf.AttrLocation).([]byte)
+			static := ok && len(loc) > 0 && loc[0] == opAddr
+			if !static && (ok || local || len(p.symbols) == 0) {
+				continue
+			}
+			// Variables whose type or size cannot be parsed cannot be
+			// searched, but should not prevent searching the rest
+			v, err := p.Variable(entry)
+			if err != nil || v.Type.bitSize == 0 {
+				continue
+			}
+			if !static {
+				// Declarations completed by a located definition are
+				// found through the definition
+				if v.Source != SourceSymbolTable || fromSymbols[uint64(v.Address)] {
+					continue
+				}
+				fromSymbols[uint64(v.Address)] = true
+			}
+			size := uint64(v.Type.bitSize / 8 * numElements(v.Type.dims()))
+			ranges = append(ranges, addrRange{
+				start:    uint64(v.Address),
+				end:      uint64(v.Address) + size,
+				variable: entry.Offset,
+			})
+		}
+		sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+		var maxEnd uint64
+		for i := range ranges {
+			if ranges[i].end > maxEnd {
+				maxEnd = ranges[i].end
+			}
+			ranges[i].maxEnd = maxEnd
+		}
+		p.addrRangesList = ranges
+	})
+	return p.addrRangesList, p.addrRangesErr
+}
+
+// Finds the variable containing this address and the member or element
+// within it
+//
+// Where variables overlap, the smallest one containing the address is
+// chosen.
+func (p *Program) Symbolize(addr uint64) (Symbol, error) {
+	ranges, err := p.addrRanges()
+	if err != nil {
+		return Symbol{}, err
+	}
+	// The last variable starting at or before the address
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].start > addr }) - 1
+	best := -1
+	for ; i >= 0 && ranges[i].maxEnd > addr; i-- {
+		if addr >= ranges[i].end {
+			continue
+		}
+		if best < 0 || ranges[i].end-ranges[i].start < ranges[best].end-ranges[best].start {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Symbol{}, fmt.Errorf("No variable contains address %#x: %w", addr, ErrNotFound)
+	}
+
+	r := p.data.Reader()
+	r.Seek(ranges[best].variable)
+	entry, err := r.Next()
+	if err != nil {
+		return Symbol{}, err
+	}
+	v, err := p.Variable(entry)
+	if err != nil {
+		return Symbol{}, err
+	}
+	path, offset, err := locate(v.Type, int(addr-ranges[best].start))
+	if err != nil {
+		return Symbol{}, err
+	}
+	return Symbol{
+		Variable: v.Name(),
+		Address:  ranges[best].start,
+		Path:     path,
+		Offset:   offset,
+	}, nil
+}
+
+// Descends through the elements and members of a type to the innermost one
+// containing the byte at offset, returning the path to it and the offset
+// remaining within it
+func locate(t TypeDefProxy, offset int) (string, int, error) {
+	path := ""
+	dims := t.dims()
+	for {
+		if len(dims) > 0 {
+			elemSize := numElements(dims[1:]) * t.bitSize / 8
+			if elemSize == 0 {
+				return path, offset, nil
+			}
+			i := offset / elemSize
+			path += fmt.Sprintf("[%d]", i)
+			offset -= i * elemSize
+			dims = dims[1:]
+			continue
+		}
+		members, err := t.children()
+		if err != nil {
+			return "", 0, err
+		}
+		found := false
+		for _, m := range members {
+			start := m.structOffset / 8
+			size := m.bitSize / 8 * numElements(m.dims())
+			if offset >= start && offset < start+size {
+				if path != "" {
+					path += "."
+				}
+				path += m.name
+				offset -= start
+				t = m
+				dims = m.dims()
+				found = true
+				break
+			}
+		}
+		// Scalars and padding between members go no further
+		if !found {
+			return path, offset, nil
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+func TestSymbolize(t *testing.T) {
+	data, err := getDataFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	prog, err := NewProgram(data)
+	assert.NoError(t, err)
+
+	e, _, err := prog.Index().GetEntry("formula_1_teams")
+	assert.NoError(t, err)
+	teams, err := NewVariableProxy(prog.Types(), e)
+	assert.NoError(t, err)
+	base := uint64(teams.Address)
+
+	tests := []struct {
+		addr uint64
+		want string
+	}{
+		{base, "formula_1_teams[0].drivers[0].initials[0]"},
+		{base + 48 + 12 + 4 + 2, "formula_1_teams[1].drivers[1].car_number + 2"},
+		{base + 48 + 24 + 6, "formula_1_teams[1].sponsors[3]"},
+		{base + 48 + 24 + 7, "formula_1_teams[1].sponsors[3] + 1"},
+		// Padding after has_won_wdc belongs to no member
+		{base + 48 + 33, "formula_1_teams[1] + 33"},
+		{base + 95, "formula_1_teams[1].last_wcc + 3"},
+	}
+	for _, tt := range tests {
+		s, err := prog.Symbolize(tt.addr)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, s.String())
+		assert.Equal(t, base, s.Address)
+	}
+
+	s, err := prog.Symbolize(base + 48 + 16)
+	assert.NoError(t, err)
+	f, err := teams.Field(s.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, int(base)+48+16, f.Address)
+
+	e, _, err = prog.Index().GetEntry("hamilton")
+	assert.NoError(t, err)
+	hamilton, err := NewVariableProxy(prog.Types(), e)
+	assert.NoError(t, err)
+	s, err = prog.Symbolize(uint64(hamilton.Address) + 8)
+	assert.NoError(t, err)
+	assert.Equal(t, "hamilton.has_won_wdc", s.String())
+
+	_, err = prog.Symbolize(0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = prog.Symbolize(base + 96 + 1<<20)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSymbolizeOverlapping(t *testing.T) {
+	// int big[4] at 0x1000 and int alias at 0x1004, as a linker script
+	// might place them
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	arrayType := dwarftest.New(dwarf.TagArrayType,
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+	).With(dwarftest.New(dwarf.TagSubrangeType,
+		dwarftest.Attr{Attr: dwarf.AttrCount, Val: 4}))
+	big := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "big"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: arrayType},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{opAddr, 0x00, 0x10, 0, 0, 0, 0, 0, 0}})
+	alias := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "alias"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{opAddr, 0x04, 0x10, 0, 0, 0, 0, 0, 0}})
+	data, err := dwarftest.Build(dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "alias.c"},
+	).With(intType, arrayType, big, alias))
+	assert.NoError(t, err)
+	prog, err := NewProgram(data)
+	assert.NoError(t, err)
+
+	s, err := prog.Symbolize(0x1005)
+	assert.NoError(t, err)
+	assert.Equal(t, "alias + 1", s.String())
+	s, err = prog.Symbolize(0x1008)
+	assert.NoError(t, err)
+	assert.Equal(t, "big[2]", s.String())
+	_, err = prog.Symbolize(0x1010)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSymbolizeFromSymbols(t *testing.T) {
+	prog := buildSymtabVariables(t)
+
+	// ns::count has no location, so is placed by the symbol table, once
+	// for its declaration and definition
+	s, err := prog.Symbolize(0x5002)
+	assert.NoError(t, err)
+	assert.Equal(t, "count + 2", s.String())
+	assert.Equal(t, uint64(0x5000), s.Address)
+	ranges, err := prog.addrRanges()
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 2)
+
+	s, err = prog.Symbolize(0x6000)
+	assert.NoError(t, err)
+	assert.Equal(t, "located", s.String())
+	_, err = prog.Symbolize(0x7000)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
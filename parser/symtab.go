@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A SymbolTable maps the names of data symbols in a binary to their
+// addresses
+type SymbolTable map[string]uint64
+
+// Reads the data symbols of an ELF file
+//
+// Files without a symbol table, such as stripped binaries, give an empty
+// table rather than an error.
+func ELFSymbols(f *elf.File) (SymbolTable, error) {
+	syms, err := f.Symbols()
+	if errors.Is(err, elf.ErrNoSymbols) {
+		return SymbolTable{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := make(SymbolTable)
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) != elf.STT_OBJECT || s.Section == elf.SHN_UNDEF {
+			continue
+		}
+		t[s.Name] = s.Value
+	}
+	return t, nil
+}
+
+// Reads the data symbols of a Mach-O file
+//
+// Mach-O prefixes C symbol names with an underscore, which is removed so
+// that names match those of ELF files; debug/macho already removes it from
+// Go names. Symbols in __TEXT or in sections holding instructions are not
+// data symbols and are left out.
+func MachOSymbols(f *macho.File) (SymbolTable, error) {
+	t := make(SymbolTable)
+	if f.Symtab == nil {
+		return t, nil
+	}
+	const nTypeMask, nSect = 0x0e, 0x0e
+	const instructions = 0x80000400 // S_ATTR_PURE_INSTRUCTIONS | S_ATTR_SOME_INSTRUCTIONS
+	for _, s := range f.Symtab.Syms {
+		name := strings.TrimPrefix(s.Name, "_")
+		if s.Type&nTypeMask != nSect || name == "" {
+			continue
+		}
+		// Sections are numbered from 1 in the order of the load commands
+		if s.Sect == 0 || int(s.Sect) > len(f.Sections) {
+			continue
+		}
+		sect := f.Sections[s.Sect-1]
+		if sect.Seg == "__TEXT" || sect.Flags&instructions != 0 {
+			continue
+		}
+		t[name] = s.Value
+	}
+	return t, nil
+}
+
+// Reads the data symbols of a PE file
+//
+// COFF symbols are given relative to their section, so their addresses are
+// found from the image base and the section's virtual address.
+func PESymbols(f *pe.File) (SymbolTable, error) {
+	var base uint64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		base = uint64(h.ImageBase)
+	case *pe.OptionalHeader64:
+		base = h.ImageBase
+	}
+	const data = pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_CNT_UNINITIALIZED_DATA
+	t := make(SymbolTable)
+	for _, s := range f.Symbols {
+		if s.SectionNumber <= 0 || int(s.SectionNumber) > len(f.Sections) {
+			continue
+		}
+		sect := f.Sections[s.SectionNumber-1]
+		if sect.Characteristics&data == 0 || sect.Characteristics&(pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_DISCARDABLE) != 0 {
+			continue
+		}
+		t[s.Name] = base + uint64(sect.VirtualAddress) + uint64(s.Value)
+	}
+	return t, nil
+}
+
+// Returns the symbols of this debug file if it is an ELF, Mach-O or PE
+// file, or nil for any other kind of file
+func fileSymbols(fh interface{}) (SymbolTable, error) {
+	switch f := fh.(type) {
+	case *elf.File:
+		return ELFSymbols(f)
+	case *ELFFile:
+		return ELFSymbols(f.File)
+	case *macho.File:
+		return MachOSymbols(f)
+	case *pe.File:
+		return PESymbols(f)
+	default:
+		return nil, nil
+	}
+}
+
+// Where the address of a variable was found
+type AddressSource int
+
+const (
+	// The variable's DW_AT_location
+	SourceDWARF AddressSource = iota
+	// The symbol table, for variables the DWARF gives no location
+	SourceSymbolTable
+)
+
+func (s AddressSource) String() string {
+	switch s {
+	case SourceDWARF:
+		return "dwarf"
+	case SourceSymbolTable:
+		return "symtab"
+	default:
+		return fmt.Sprintf("AddressSource(%d)", int(s))
+	}
+}
+
+func (s AddressSource) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *AddressSource) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "dwarf":
+		*s = SourceDWARF
+	case "symtab":
+		*s = SourceSymbolTable
+	default:
+		return fmt.Errorf("Unknown address source %q: %w", b, ErrUnsupportedForm)
+	}
+	return nil
+}
+
+// Returns the names this variable may appear under in a symbol table: its
+// linkage name, then its plain name
+func symbolNames(entry *dwarf.Entry) []string {
+	names := make([]string, 0, 2)
+	if name := LinkageName(entry); name != "" {
+		names = append(names, name)
+	}
+	if name := EntryName(entry); name != "" {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Finds the address of a variable with no location in the symbol table
+func (p *Program) symbolAddress(entry *dwarf.Entry) (uint64, bool) {
+	for _, name := range symbolNames(entry) {
+		if addr, ok := p.symbols[name]; ok {
+			return addr, true
+		}
+	}
+	return 0, false
+}
+
+// Construct a VariableProxy for a DW_TAG_variable entry
+//
+// Declarations are completed with their definitions and definitions with
+// the declarations they complete, as by Index.Complete. Variables the
+// DWARF still gives no location, such as globals whose location the
+// compiler dropped, are looked up in the symbol table by their linkage name
+// or name. The Source of the proxy records which was used.
+func (p *Program) Variable(entry *dwarf.Entry) (*VariableProxy, error) {
+	entry, err := p.index.Complete(entry)
+	if err != nil {
+		return nil, err
+	}
+	v, err := NewVariableProxy(p.types, entry)
+	if err == nil || !errors.Is(err, ErrNoLocation) {
+		return v, err
+	}
+	addr, ok := p.symbolAddress(entry)
+	if !ok {
+		return nil, err
+	}
+	t, err := NewTypeDefProxy(p.types, entry)
+	if err != nil {
+		return nil, err
+	}
+	return &VariableProxy{
+		name:        EntryName(entry),
+		LinkageName: LinkageName(entry),
+		Type:        *t,
+		Address:     int(addr),
+		Source:      SourceSymbolTable,
+		value:       []byte{},
+	}, nil
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/macho"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+// Builds DWARF for:
+//
+//	namespace ns { extern int count; }
+//	int ns::count;     // the compiler dropped its location
+//	static int hidden; // likewise, and not in the symbol table
+//	int located = 1;   // at 0x6000
+func buildSymtabVariables(t *testing.T) *Program {
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	decl := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "count"},
+		dwarftest.Attr{Attr: dwarf.AttrLinkageName, Val: "_ZN2ns5countE"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrDeclaration, Val: true})
+	ns := dwarftest.New(dwarf.TagNamespace,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "ns"},
+	).With(decl)
+	def := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrSpecification, Val: decl})
+	hidden := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "hidden"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType})
+	// DW_OP_addr 0x6000
+	located := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "located"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x03, 0x00, 0x60, 0, 0, 0, 0, 0, 0}})
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "count.cpp"},
+	).With(intType, ns, def, hidden, located)
+	data, err := dwarftest.Build(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := NewProgram(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.SetSymbols(SymbolTable{
+		"_ZN2ns5countE": 0x5000,
+		"located":       0x7000,
+	})
+	return prog
+}
+
+func TestProgramVariableFallback(t *testing.T) {
+	prog := buildSymtabVariables(t)
+	entries := make([]*dwarf.Entry, 0)
+	r := prog.Reader()
+	for {
+		e, err := r.Next()
+		assert.NoError(t, err)
+		if e == nil {
+			break
+		}
+		if e.Tag == dwarf.TagVariable {
+			entries = append(entries, e)
+		}
+	}
+	if !assert.Len(t, entries, 4) {
+		return
+	}
+
+	// The declaration is found by its linkage name
+	v, err := prog.Variable(entries[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "count", v.Name())
+	assert.Equal(t, 0x5000, v.Address)
+	assert.Equal(t, SourceSymbolTable, v.Source)
+
+	// The definition takes its name, type and linkage name from the
+	// declaration
+	v, err = prog.Variable(entries[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "count", v.Name())
+	assert.Equal(t, 0x5000, v.Address)
+	assert.Equal(t, 32, v.Type.BitSize())
+	assert.Equal(t, SourceSymbolTable, v.Source)
+
+	_, err = prog.Variable(entries[2])
+	assert.ErrorIs(t, err, ErrNoLocation)
+
+	// The DWARF location is preferred to the symbol table
+	v, err = prog.Variable(entries[3])
+	assert.NoError(t, err)
+	assert.Equal(t, 0x6000, v.Address)
+	assert.Equal(t, SourceDWARF, v.Source)
+}
+
+func TestAddressSourceJSON(t *testing.T) {
+	prog := buildSymtabVariables(t)
+	e, _, err := prog.Index().GetEntry("count")
+	assert.NoError(t, err)
+	v, err := prog.Variable(e)
+	assert.NoError(t, err)
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"source":"symtab"`)
+
+	var decoded VariableProxy
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, SourceSymbolTable, decoded.Source)
+}
+
+func TestELFSymbols(t *testing.T) {
+	f, err := elf.Open("../testcase-compiler/testcase.out")
+	assert.NoError(t, err)
+	defer f.Close()
+	syms, err := ELFSymbols(f)
+	assert.NoError(t, err)
+
+	// Every symbol matches the DWARF location of its variable
+	prog, err := NewProgramFromFile(f, SearchPaths{})
+	assert.NoError(t, err)
+	for _, name := range []string{"formula_1_teams", "verstappen", "mercedes"} {
+		e, _, err := prog.Index().GetEntry(name)
+		assert.NoError(t, err)
+		v, err := prog.Variable(e)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(v.Address), syms[name], name)
+	}
+	// Functions are not data symbols
+	assert.NotContains(t, syms, "main")
+}
+
+func TestMachOSymbols(t *testing.T) {
+	// Generated by explorer/plat/testdata/formats/gen.go
+	fat, err := macho.OpenFat("../explorer/plat/testdata/formats/app.fat")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer fat.Close()
+	for _, arch := range fat.Arches {
+		syms, err := MachOSymbols(arch.File)
+		assert.NoError(t, err)
+		assert.Contains(t, syms, "main.config", arch.Cpu)
+		// main.main is in __TEXT
+		assert.NotContains(t, syms, "main.main", arch.Cpu)
+	}
+}
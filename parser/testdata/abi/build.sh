@@ -0,0 +1,9 @@
+#!/bin/sh
+# Rebuilds the objects v1.o and v2.o, two versions of the same protocol
+# whose ABI differs as described at the top of v2.c.
+set -e
+cd "$(dirname "$0")"
+
+for v in v1 v2; do
+    gcc -g -O0 -c -fdebug-prefix-map="$PWD"=. "$v.c" -o "$v.o"
+done
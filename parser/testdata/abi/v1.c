@@ -0,0 +1,35 @@
+/* The first version of a protocol shared by firmware and a host tool */
+#include <stdint.h>
+
+typedef uint16_t port_t;
+
+enum Mode {
+    MODE_IDLE = 0,
+    MODE_RUN = 1,
+    MODE_FAULT = 2,
+};
+
+struct Header {
+    uint8_t version;
+    uint8_t flags;
+    uint16_t length;
+};
+
+struct Config {
+    enum Mode mode;
+    port_t port;
+    uint8_t spare;
+    char name[8];
+    uint32_t crc;
+};
+
+typedef struct {
+    int32_t x;
+    int32_t y;
+} Point;
+
+struct Header header;
+struct Config config;
+Point origin;
+int retries;
+long uptime;
@@ -0,0 +1,44 @@
+/* The second version of the protocol in v1.c, with these changes:
+ *
+ *   port_t widened to 32 bits, moving the members of Config after port
+ *   Config.spare renamed reserved
+ *   MODE_RUN renamed MODE_ACTIVE, MODE_FAULT renumbered and MODE_SLEEP added
+ *   Point given a z coordinate
+ *   retries removed, timeout added and uptime made a long long
+ */
+#include <stdint.h>
+
+typedef uint32_t port_t;
+
+enum Mode {
+    MODE_IDLE = 0,
+    MODE_ACTIVE = 1,
+    MODE_FAULT = 3,
+    MODE_SLEEP = 4,
+};
+
+struct Header {
+    uint8_t version;
+    uint8_t flags;
+    uint16_t length;
+};
+
+struct Config {
+    enum Mode mode;
+    port_t port;
+    uint8_t reserved;
+    char name[8];
+    uint32_t crc;
+};
+
+typedef struct {
+    int32_t x;
+    int32_t y;
+    int32_t z;
+} Point;
+
+struct Header header;
+struct Config config;
+Point origin;
+long long uptime;
+int timeout;
@@ -0,0 +1,7 @@
+#!/bin/sh
+# Rebuilds cars.out, linked by gold with a .gdb_index built from the GNU
+# pubnames of its two units.
+set -e
+cd "$(dirname "$0")"
+
+gcc -g -O0 -ggnu-pubnames -fuse-ld=gold -Wl,--gdb-index -fdebug-prefix-map="$PWD"=. cars.c track.c -o cars.out
@@ -0,0 +1,14 @@
+/* A member sharing its name with a global in another unit */
+struct Car {
+    int speed;
+    int laps;
+};
+
+struct Car car = {300, 58};
+int laps = 3;
+
+int get(void);
+
+int main(void) {
+    return car.speed + laps + get();
+}
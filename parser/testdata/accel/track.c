@@ -0,0 +1,6 @@
+static int hidden = 1;
+int speed = 5;
+
+int get(void) {
+    return hidden + speed;
+}
@@ -0,0 +1,41 @@
+#!/bin/sh
+# Rebuilds the split DWARF fixtures from teams.cpp, laps.cpp and main.cpp.
+#
+# Each directory holds one flavour of split DWARF:
+#
+#   dwarf5      DWARF 5 with a .dwo file per object
+#   dwarf5-dwp  DWARF 5 with the .dwo files packaged as split.out.dwp
+#   dwarf4      GNU split DWARF 4 with a .dwo file per object
+#   dwarf4-dwp  GNU split DWARF 4 packaged by dwp
+#
+# laps.cpp is optimized so that complete_lap is split into hot and cold
+# parts, giving it a range list in the .dwo. The compile directory is
+# recorded as "." so that the skeletons name their .dwo files relative to
+# wherever the tests run from; the tests rely on finding them next to the
+# binary or in a search directory instead.
+#
+# dwp from binutils only packages DWARF 4 and llvm-dwp 14 hangs on the
+# DWARF 5 objects gcc 12 writes, so the DWARF 5 package is assembled by
+# mkdwp.py.
+set -e
+cd "$(dirname "$0")"
+
+build() {
+    version=$1
+    flags="-g -gdwarf-$version -gsplit-dwarf -fdebug-prefix-map=$PWD=."
+    dir=dwarf$version
+    rm -rf "$dir" "$dir-dwp"
+    mkdir -p "$dir" "$dir-dwp"
+    g++ $flags -O0 -c teams.cpp -o "$dir/teams.o"
+    g++ $flags -O2 -c laps.cpp -o "$dir/laps.o"
+    g++ $flags -O0 -c main.cpp -o "$dir/main.o"
+    g++ "$dir/teams.o" "$dir/laps.o" "$dir/main.o" -o "$dir/split.out"
+    rm "$dir"/*.o
+    cp "$dir/split.out" "$dir-dwp/split.out"
+}
+
+build 5
+python3 mkdwp.py dwarf5-dwp/split.out.dwp dwarf5/teams.dwo dwarf5/laps.dwo dwarf5/main.dwo
+
+build 4
+dwp -o dwarf4-dwp/split.out.dwp dwarf4/teams.dwo dwarf4/laps.dwo dwarf4/main.dwo
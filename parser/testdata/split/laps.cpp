@@ -0,0 +1,16 @@
+[[gnu::cold, gnu::noinline]] void retire(int lap);
+
+int laps_completed = 0;
+
+int complete_lap(int lap) {
+    if (__builtin_expect(lap < 0, 0)) {
+        retire(lap);
+        return -1;
+    }
+    laps_completed++;
+    return laps_completed;
+}
+
+void retire(int lap) {
+    laps_completed = lap;
+}
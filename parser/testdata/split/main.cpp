@@ -0,0 +1,16 @@
+struct Driver;
+
+struct Lap {
+    int number;
+    long time_ms;
+};
+
+Lap fastest = {42, 81234};
+
+int points(const Driver &d, int races);
+int complete_lap(int lap);
+
+int main() {
+    complete_lap(fastest.number);
+    return fastest.number;
+}
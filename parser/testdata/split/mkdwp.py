@@ -0,0 +1,115 @@
+#!/usr/bin/env python3
+"""Packages DWARF 5 .dwo files into a .dwp
+
+    mkdwp.py OUTPUT DWO...
+
+Only handles what gcc writes for a single compile unit per .dwo: the
+sections are concatenated, the string tables merged, and a version 5
+.debug_cu_index written to find each unit's contributions. Sections are
+read and written with objcopy.
+"""
+import os
+import struct
+import subprocess
+import sys
+import tempfile
+
+# Section identifiers from DWARF 5 section 7.3.5.3
+COLUMNS = [
+    (1, ".debug_info.dwo"),
+    (3, ".debug_abbrev.dwo"),
+    (4, ".debug_line.dwo"),
+    (5, ".debug_loclists.dwo"),
+    (6, ".debug_str_offsets.dwo"),
+    (8, ".debug_rnglists.dwo"),
+]
+
+
+def sections(path, tmp):
+    out = {}
+    for _, name in COLUMNS + [(0, ".debug_str.dwo")]:
+        dump = os.path.join(tmp, name.strip("."))
+        r = subprocess.run(
+            ["objcopy", "--dump-section", name + "=" + dump, path, os.path.join(tmp, "scratch")],
+            stderr=subprocess.DEVNULL)
+        if r.returncode == 0 and os.path.exists(dump):
+            with open(dump, "rb") as f:
+                out[name] = f.read()
+            os.remove(dump)
+        else:
+            out[name] = b""
+    return out
+
+
+def dwo_id(info):
+    length, version, unit_type = struct.unpack_from("<IHB", info)
+    if length == 0xFFFFFFFF or version != 5 or unit_type != 5:
+        raise SystemExit("not a 32-bit DWARF 5 split compile unit")
+    return struct.unpack_from("<Q", info, 12)[0]
+
+
+def main():
+    if len(sys.argv) < 3:
+        raise SystemExit(__doc__)
+    output, dwos = sys.argv[1], sys.argv[2:]
+
+    packed = {name: bytearray() for _, name in COLUMNS}
+    strings = bytearray()
+    units = []
+    with tempfile.TemporaryDirectory() as tmp:
+        for path in dwos:
+            secs = sections(path, tmp)
+            offsets, sizes = [], []
+            for _, name in COLUMNS:
+                data = secs[name]
+                if name == ".debug_str_offsets.dwo" and data:
+                    # Rebase the string offsets onto the merged string table,
+                    # keeping the 8-byte header
+                    n = (len(data) - 8) // 4
+                    entries = [e + len(strings) for e in struct.unpack_from("<%dI" % n, data, 8)]
+                    data = data[:8] + struct.pack("<%dI" % n, *entries)
+                offsets.append(len(packed[name]))
+                sizes.append(len(data))
+                packed[name] += data
+            strings += secs[".debug_str.dwo"]
+            units.append((dwo_id(secs[".debug_info.dwo"]), offsets, sizes))
+
+        slots = 1
+        while slots < 2 * len(units):
+            slots *= 2
+        signatures = [0] * slots
+        indices = [0] * slots
+        for row, (sig, _, _) in enumerate(units, 1):
+            mask = slots - 1
+            h = sig & mask
+            step = ((sig >> 32) & mask) | 1
+            while indices[h]:
+                h = (h + step) & mask
+            signatures[h] = sig
+            indices[h] = row
+
+        index = struct.pack("<HHIII", 5, 0, len(COLUMNS), len(units), slots)
+        index += struct.pack("<%dQ" % slots, *signatures)
+        index += struct.pack("<%dI" % slots, *indices)
+        index += struct.pack("<%dI" % len(COLUMNS), *[ident for ident, _ in COLUMNS])
+        for _, offsets, _ in units:
+            index += struct.pack("<%dI" % len(offsets), *offsets)
+        for _, _, sizes in units:
+            index += struct.pack("<%dI" % len(sizes), *sizes)
+
+        args = ["objcopy", "--remove-section=.debug_*"]
+        contents = dict(packed)
+        contents[".debug_str.dwo"] = strings
+        contents[".debug_cu_index"] = index
+        for name, data in contents.items():
+            if not data:
+                continue
+            path = os.path.join(tmp, name.strip("."))
+            with open(path, "wb") as f:
+                f.write(data)
+            args += ["--add-section", name + "=" + path]
+        subprocess.run(args + [dwos[0], output], check=True)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,23 @@
+struct Driver {
+    char initials[2];
+    int car_number;
+    bool has_won_wdc;
+};
+
+namespace f1 {
+Driver champion = {{'M', 'V'}, 1, true};
+}
+
+struct Team {
+    static int count;
+    Driver drivers[2];
+};
+
+int Team::count = 10;
+
+Team redbull = {{{{'M', 'V'}, 1, true}, {{'S', 'P'}, 11, false}}};
+
+int points(const Driver &d, int races) {
+    int total = d.car_number * races;
+    return total;
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"debug/dwarf"
+	"encoding/json"
 	"fmt"
 	// "strings"
 )
@@ -13,22 +14,43 @@ import (
 // language (most immediately relevant is Go but this should be generic enough to
 // apply to other languages through Go bindings or a socket server using json or rpc).
 //
-// All relevant DWARF parsing is handled when this proxy is created and no intermediate
-// DWARF data is included here. The proxy should be ready to hand off as-is to a user.
+// No intermediate DWARF data is included here. The proxy should be ready to hand off
+// as-is to a user. Members are parsed the first time they are needed, through the
+// TypeGraph that created this proxy, and are shared with every other proxy of the
+// same underlying type.
 type TypeDefProxy struct {
 	name         string
 	bitSize      int
 	structOffset int
 	arrayRanges  []int
 	ahildren     []TypeDefProxy
+
+	// The graph this proxy was built from, the offset of the entry it was
+	// built from and the offset of the entry describing its underlying type.
+	// Proxies that were not built from a graph keep their children in
+	// ahildren instead.
+	graph       *TypeGraph
+	entryOffset dwarf.Offset
+	typeOffset  dwarf.Offset
 }
 
 // Construct a new TypeDefProxy
-func NewTypeDefProxy(reader *dwarf.Reader, e *dwarf.Entry) (*TypeDefProxy, error) {
+//
+// Only this entry and its type are parsed; members are parsed on demand.
+func NewTypeDefProxy(g *TypeGraph, e *dwarf.Entry) (*TypeDefProxy, error) {
+	return g.typeDefProxy(e)
+}
+
+// Parses the proxy for an entry without consulting the graph's memo
+func newTypeDefProxy(g *TypeGraph, e *dwarf.Entry) (*TypeDefProxy, error) {
 	var arrayRanges = []int{0}
 	var name string
 	var err error
+	reader := g.data.Reader()
 	typeEntry, err := GetTypeEntry(reader, e)
+	if err != nil {
+		return nil, err
+	}
 
 	// Need to handle traversing through array entries to get to the underlying typedefs.
 	if typeEntry.Tag == dwarf.TagArrayType {
@@ -49,33 +71,43 @@ func NewTypeDefProxy(reader *dwarf.Reader, e *dwarf.Entry) (*TypeDefProxy, error
 		if err != nil {
 			return nil, err
 		}
-		name = typeEntry.Val(dwarf.AttrName).(string)
+		name = EntryName(typeEntry)
 		typeEntry, err = GetTypeEntry(reader, typeEntry)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		name = e.Val(dwarf.AttrName).(string)
+		name = EntryName(e)
 	}
 
 	// Arrays and Consts may still have a typedef entry behind them. We need to step
-	// through it to find the underlying struct or base type
-	if typeEntry.Tag == dwarf.TagTypedef {
+	// through it, and through any typedefs it names in turn as uint8_t does, to
+	// find the underlying struct or base type
+	for depth := 0; typeEntry.Tag == dwarf.TagTypedef && depth < maxTypeDepth; depth++ {
 		typeEntry, err = GetTypeEntry(reader, typeEntry)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// TODO: handle the situation where we have no AttrName
 	proxy := &TypeDefProxy{
 		name:         name,
 		bitSize:      0,
 		structOffset: 0,
 		arrayRanges:  arrayRanges,
 		ahildren:     make([]TypeDefProxy, 0),
+		graph:        g,
+		entryOffset:  e.Offset,
+		typeOffset:   typeEntry.Offset,
 	}
 
 	// The offset into the struct is defined by the member, not its type
 	if HasAttr(e, dwarf.AttrDataMemberLoc) {
-		proxy.structOffset = int(e.Val(dwarf.AttrDataMemberLoc).(int64)) * 8
+		offset, err := GetMemberOffset(e)
+		if err != nil {
+			return nil, err
+		}
+		proxy.structOffset = offset * 8
 	}
 
 	// TODO: this probably needs an else case where we compute size from walking
@@ -83,55 +115,51 @@ func NewTypeDefProxy(reader *dwarf.Reader, e *dwarf.Entry) (*TypeDefProxy, error
 	if HasAttr(typeEntry, dwarf.AttrByteSize) || HasAttr(typeEntry, dwarf.AttrBitSize) {
 		var bitSize int
 		bitSize, err = GetBitSize(typeEntry)
+		if err != nil {
+			return nil, err
+		}
 		proxy.bitSize = bitSize
 	}
+	return proxy, nil
+}
 
-	// TODO: split this into its own method
-	if typeEntry.Children {
-		for {
-			child, err := reader.Next()
-			if err != nil {
-				panic("Error iterating children; **this error handling needs to be improved!**")
-			}
-
-			// When we've finished iterating over members, we are done with the meaningful
-			// children of this typedef. We are also finished if we reach the end of the DWARF
-			// section during this iteration.
-			if child == nil {
-				// fmt.Println("Bailing from populating children because we saw the final entry in the DWARF")
-				break
-			}
-			if child.Tag == 0 {
-				break
-			}
-
-			// Note that constructing proxies for all children makes this constructor
-			// itself recursive.
-			childProxy, err := NewTypeDefProxy(reader, child)
-			if err != nil {
-				panic(err)
-			}
-			// TODO: is this the right way to do this in go?
-			proxy.ahildren = append(proxy.ahildren, *childProxy)
-			// How do we appropriately parse this stuff without having to jump around a bunch in the reader?
-			// ^ Is jumping around in the reader expensive?
-			reader.Seek(child.Offset)
-			_, err = reader.Next()
-			if err != nil {
-				panic(err)
-			}
-		}
+// Returns the members of this TypeDef, parsing them if this is the first
+// time any proxy of this type has needed them
+func (p TypeDefProxy) children() ([]TypeDefProxy, error) {
+	if p.graph == nil {
+		return p.ahildren, nil
 	}
-	return proxy, err
+	return p.graph.members(p.typeOffset)
 }
 
 func (p TypeDefProxy) Name() string {
 	return p.name
 }
 
+// Returns the size in bits of one element of this type
+func (p TypeDefProxy) BitSize() int {
+	return p.bitSize
+}
+
+// Returns the offset in bits of this member from the start of the enclosing
+// type, or 0 if this is not a member
+func (p TypeDefProxy) BitOffset() int {
+	return p.structOffset
+}
+
+// Returns the number of elements in each dimension of this type, which is
+// empty for scalars
+func (p TypeDefProxy) ArrayRanges() []int {
+	return p.dims()
+}
+
+// Returns the members of this TypeDef in declaration order
+func (p TypeDefProxy) Members() ([]TypeDefProxy, error) {
+	return p.children()
+}
+
 func (p *TypeDefProxy) string() string {
-	// TODO: for now, we don't print children
-	var str string = fmt.Sprintf("Typedef %s\n  BitSize: %d\n  ArrayRanges %v\n  Children %#v\n", p.name, p.bitSize, p.arrayRanges, p.ahildren)
+	var str string = fmt.Sprintf("Typedef %s\n  BitSize: %d\n  ArrayRanges %v\n  Children %v\n", p.name, p.bitSize, p.arrayRanges, p.ListChildren())
 	return str
 }
 
@@ -139,10 +167,57 @@ func (p *TypeDefProxy) GoString() string {
 	return p.string()
 }
 
+// The serialized form of a TypeDefProxy
+type typeDefProxyJSON struct {
+	Name         string         `json:"name"`
+	BitSize      int            `json:"bit_size"`
+	StructOffset int            `json:"struct_offset"`
+	ArrayRanges  []int          `json:"array_ranges"`
+	Children     []TypeDefProxy `json:"children"`
+}
+
+// Encodes this TypeDef, including all of its members, as JSON
+func (p TypeDefProxy) MarshalJSON() ([]byte, error) {
+	children, err := p.children()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(typeDefProxyJSON{
+		Name:         p.name,
+		BitSize:      p.bitSize,
+		StructOffset: p.structOffset,
+		ArrayRanges:  p.arrayRanges,
+		Children:     children,
+	})
+}
+
+// Decodes a TypeDef previously encoded by MarshalJSON
+func (p *TypeDefProxy) UnmarshalJSON(b []byte) error {
+	var j typeDefProxyJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	p.name = j.Name
+	p.bitSize = j.BitSize
+	p.structOffset = j.StructOffset
+	p.arrayRanges = j.ArrayRanges
+	p.ahildren = j.Children
+	if p.arrayRanges == nil {
+		p.arrayRanges = []int{}
+	}
+	if p.ahildren == nil {
+		p.ahildren = make([]TypeDefProxy, 0)
+	}
+	return nil
+}
+
 // Retuns a slice of strings containing the name of each member of this TypeDef
+//
+// Members that could not be parsed are left out.
 func (p TypeDefProxy) ListChildren() []string {
-	names := make([]string, len(p.ahildren))
-	for i, c := range p.ahildren {
+	children, _ := p.children()
+	names := make([]string, len(children))
+	for i, c := range children {
 		names[i] = c.name
 	}
 	return names
@@ -150,10 +225,14 @@ func (p TypeDefProxy) ListChildren() []string {
 
 // Returns the TypeDefProxy for a member of this TypeDef by name
 func (p TypeDefProxy) GetChild(childName string) (*TypeDefProxy, error) {
-	for _, c := range p.ahildren {
+	children, err := p.children()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range children {
 		if c.name == childName {
 			return &c, nil
 		}
 	}
-	return nil, fmt.Errorf("Could not find child %s for %s", childName, p.GoString())
+	return nil, fmt.Errorf("Could not find child %s for %s: %w", childName, p.GoString(), ErrNotFound)
 }
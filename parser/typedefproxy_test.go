@@ -2,12 +2,31 @@ package parser
 
 import (
 	"debug/dwarf"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
+// Returns a copy of this proxy with all of its children parsed and no
+// reference to the graph it was built from, so that it can be compared
+// against literals
+func detach(t *testing.T, p TypeDefProxy) TypeDefProxy {
+	children, err := p.children()
+	assert.NoError(t, err)
+	p.ahildren = make([]TypeDefProxy, len(children))
+	for i, c := range children {
+		p.ahildren[i] = detach(t, c)
+	}
+	p.graph = nil
+	p.entryOffset = 0
+	p.typeOffset = 0
+	return p
+}
+
 func TestNewTypeDefProxy(t *testing.T) {
-	reader, _ := getReaderFromFile(testcaseFilename)
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
 	// var driverProxy *TypeDefProxy
 	var e *dwarf.Entry
 	var err error
@@ -15,16 +34,16 @@ func TestNewTypeDefProxy(t *testing.T) {
 	// Start with a few trivial cases
 	e, _, err = GetEntry(reader, "char")
 	assert.Equal(t, nil, err)
-	driverProxy, err := NewTypeDefProxy(reader, e)
+	driverProxy, err := NewTypeDefProxy(g, e)
 	assert.NoError(t, err)
 	assert.Equal(t, "char", driverProxy.name)
 	assert.Equal(t, int(8), driverProxy.bitSize)
-	assert.Equal(t, make([]TypeDefProxy, 0), driverProxy.ahildren)
+	assert.Equal(t, make([]TypeDefProxy, 0), detach(t, *driverProxy).ahildren)
 
 	// Move on to non-trivial cases in which Children must actually be populated
 	e, _, err = GetEntry(reader, "Driver")
 	assert.NoError(t, err)
-	driverProxy, err = NewTypeDefProxy(reader, e)
+	driverProxy, err = NewTypeDefProxy(g, e)
 	assert.NoError(t, err)
 	// NOTE: clang chooses to pad bools out to 4 bytes despite the typical implementation
 	// being only 1 byte
@@ -53,12 +72,12 @@ func TestNewTypeDefProxy(t *testing.T) {
 	}
 	assert.Equal(t, "Driver", driverProxy.name)
 	assert.Equal(t, int(12*8), driverProxy.bitSize)
-	assert.Equal(t, driverChildren, driverProxy.ahildren)
+	assert.Equal(t, driverChildren, detach(t, *driverProxy).ahildren)
 
 	// A type that includes the type from the previous test
 	e, _, err = GetEntry(reader, "Team")
 	assert.NoError(t, err)
-	teamProxy, err := NewTypeDefProxy(reader, e)
+	teamProxy, err := NewTypeDefProxy(g, e)
 	assert.NoError(t, err)
 
 	var teamChildren = []TypeDefProxy{
@@ -67,7 +86,7 @@ func TestNewTypeDefProxy(t *testing.T) {
 			bitSize:      96,
 			structOffset: 0,
 			arrayRanges:  []int{2},
-			ahildren:     driverProxy.ahildren,
+			ahildren:     driverChildren,
 		},
 		{
 			name:         "sponsors",
@@ -108,16 +127,18 @@ func TestNewTypeDefProxy(t *testing.T) {
 
 	assert.Equal(t, "Team", teamProxy.name)
 	assert.Equal(t, int(384), teamProxy.bitSize)
-	assert.Equal(t, teamChildren, teamProxy.ahildren)
+	assert.Equal(t, teamChildren, detach(t, *teamProxy).ahildren)
 }
 
 func TestTypeDefProxyGetChild(t *testing.T) {
-	reader, _ := getReaderFromFile(testcaseFilename)
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
 
 	// Navigate one level down
 	e, _, err := GetEntry(reader, "Driver")
 	assert.NoError(t, err)
-	driverProxy, err := NewTypeDefProxy(reader, e)
+	driverProxy, err := NewTypeDefProxy(g, e)
 	assert.NoError(t, err)
 	initialsProxy, err := driverProxy.GetChild("initials")
 	assert.NoError(t, err)
@@ -129,7 +150,7 @@ func TestTypeDefProxyGetChild(t *testing.T) {
 	// Navigate two levels down
 	e, _, err = GetEntry(reader, "Team")
 	assert.NoError(t, err)
-	teamProxy, err := NewTypeDefProxy(reader, e)
+	teamProxy, err := NewTypeDefProxy(g, e)
 	assert.NoError(t, err)
 	driverProxy, err = teamProxy.GetChild("drivers")
 	assert.NoError(t, err)
@@ -144,3 +165,39 @@ func TestTypeDefProxyGetChild(t *testing.T) {
 	assert.Equal(t, int(0), initialsProxy.structOffset)
 	assert.Equal(t, []int{2}, initialsProxy.arrayRanges)
 }
+
+func TestTypeDefProxyJSON(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
+	e, _, err := GetEntry(reader, "Team")
+	assert.NoError(t, err)
+	teamProxy, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(teamProxy)
+	assert.NoError(t, err)
+	var decoded TypeDefProxy
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, detach(t, *teamProxy), decoded)
+}
+
+func TestTypeDefProxyAccessors(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
+	e, _, err := GetEntry(reader, "Team")
+	assert.NoError(t, err)
+	teamProxy, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	assert.Equal(t, 48*8, teamProxy.BitSize())
+	assert.Equal(t, []int{}, teamProxy.ArrayRanges())
+
+	members, err := teamProxy.Members()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(members))
+	assert.Equal(t, "sponsors", members[1].Name())
+	assert.Equal(t, 24*8, members[1].BitOffset())
+	assert.Equal(t, 16, members[1].BitSize())
+	assert.Equal(t, []int{4}, members[1].ArrayRanges())
+}
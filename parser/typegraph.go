@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"sync"
+)
+
+// A TypeGraph creates TypeDefProxies on demand and memoizes them by the
+// offset of the entry they describe.
+//
+// Proxies built from the same graph share their members: the members of a
+// type are parsed once, the first time any proxy of that type needs them.
+// Because members are only parsed when asked for, recursive types such as
+// linked-list nodes never cause the graph to walk in circles.
+//
+// A TypeGraph is safe for concurrent use.
+type TypeGraph struct {
+	mux     sync.Mutex
+	data    *dwarf.Data
+	proxies map[dwarf.Offset]*TypeDefProxy
+	// The members of each type, keyed by the offset of the type entry
+	typeMembers map[dwarf.Offset][]TypeDefProxy
+}
+
+// Returns an empty graph over this DWARF data
+func NewTypeGraph(data *dwarf.Data) *TypeGraph {
+	return &TypeGraph{
+		data:        data,
+		proxies:     make(map[dwarf.Offset]*TypeDefProxy),
+		typeMembers: make(map[dwarf.Offset][]TypeDefProxy),
+	}
+}
+
+// Returns the DWARF data this graph was built over
+func (g *TypeGraph) Data() *dwarf.Data {
+	return g.data
+}
+
+// Returns the proxy for this entry, building it if necessary
+//
+// The lock is not held while parsing, since parsing members builds further
+// proxies. If two goroutines race to build the same proxy, the first one
+// stored wins so that every caller sees the same proxy.
+func (g *TypeGraph) typeDefProxy(e *dwarf.Entry) (*TypeDefProxy, error) {
+	g.mux.Lock()
+	p, ok := g.proxies[e.Offset]
+	g.mux.Unlock()
+	if ok {
+		return p, nil
+	}
+	p, err := newTypeDefProxy(g, e)
+	if err != nil {
+		return nil, err
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if existing, ok := g.proxies[e.Offset]; ok {
+		return existing, nil
+	}
+	g.proxies[e.Offset] = p
+	return p, nil
+}
+
+// Returns the members of the type described by the entry at this offset
+func (g *TypeGraph) members(typeOffset dwarf.Offset) ([]TypeDefProxy, error) {
+	g.mux.Lock()
+	m, ok := g.typeMembers[typeOffset]
+	g.mux.Unlock()
+	if ok {
+		return m, nil
+	}
+	members := make([]TypeDefProxy, 0)
+	reader := g.data.Reader()
+	reader.Seek(typeOffset)
+	typeEntry, err := reader.Next()
+	if err != nil {
+		return nil, err
+	}
+	if typeEntry == nil || !typeEntry.Children {
+		return g.storeMembers(typeOffset, members), nil
+	}
+	for {
+		child, err := reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		// When we've finished iterating over members, we are done with the meaningful
+		// children of this typedef. We are also finished if we reach the end of the DWARF
+		// section during this iteration.
+		if child == nil || child.Tag == 0 {
+			break
+		}
+		// Members never have children of their own that we care about; their
+		// layout is described by their type.
+		reader.SkipChildren()
+		if !isMember(child) {
+			continue
+		}
+		p, err := g.typeDefProxy(child)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *p)
+	}
+	return g.storeMembers(typeOffset, members), nil
+}
+
+// Memoizes the members of a type, returning whichever members were stored
+// first
+func (g *TypeGraph) storeMembers(typeOffset dwarf.Offset, members []TypeDefProxy) []TypeDefProxy {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if existing, ok := g.typeMembers[typeOffset]; ok {
+		return existing
+	}
+	g.typeMembers[typeOffset] = members
+	return members
+}
+
+// Returns true if this child of a type entry describes part of the type's
+// layout or values, as opposed to, for example, a method
+func isMember(e *dwarf.Entry) bool {
+	switch e.Tag {
+	case dwarf.TagMember, dwarf.TagEnumerator:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"debug/dwarf"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+)
+
+// Builds DWARF for:
+//
+//	struct Node {
+//	  int value;
+//	  struct Node *next;
+//	};
+//	struct Node head;
+func buildLinkedList(t *testing.T) *dwarf.Data {
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	node := dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "Node"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 16})
+	nodePtr := dwarftest.New(dwarf.TagPointerType,
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 8},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: node})
+	node.With(
+		dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "value"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+			dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: 0}),
+		dwarftest.New(dwarf.TagMember,
+			dwarftest.Attr{Attr: dwarf.AttrName, Val: "next"},
+			dwarftest.Attr{Attr: dwarf.AttrType, Val: nodePtr},
+			dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: 8}),
+	)
+	head := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "head"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: node},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x03, 0x00, 0x10, 0, 0, 0, 0, 0, 0}})
+	cu := dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "list.c"},
+	).With(intType, node, nodePtr, head)
+	data, err := dwarftest.Build(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestTypeGraphRecursiveType(t *testing.T) {
+	data := buildLinkedList(t)
+	g := NewTypeGraph(data)
+	e, _, err := GetEntry(data.Reader(), "head")
+	assert.NoError(t, err)
+
+	head, err := NewVariableProxy(g, e)
+	assert.NoError(t, err)
+	assert.Equal(t, 0x1000, head.Address)
+	assert.Equal(t, 128, head.Type.bitSize)
+	assert.Equal(t, []string{"value", "next"}, head.ListChildren())
+
+	next, err := head.GetChild("next")
+	assert.NoError(t, err)
+	assert.Equal(t, 64, next.bitSize)
+	assert.Equal(t, 64, next.structOffset)
+	assert.Equal(t, []string{}, next.ListChildren())
+}
+
+func TestTypeGraphIsLazy(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
+
+	e, _, err := GetEntry(reader, "Team")
+	assert.NoError(t, err)
+	team, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(g.typeMembers))
+
+	drivers, err := team.GetChild("drivers")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(g.typeMembers))
+	assert.Equal(t, []string{"initials", "car_number", "has_won_wdc"}, drivers.ListChildren())
+	assert.Equal(t, 2, len(g.typeMembers))
+}
+
+func TestTypeGraphSharesProxies(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
+
+	e, _, err := GetEntry(reader, "Driver")
+	assert.NoError(t, err)
+	driver, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	again, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	assert.Same(t, driver, again)
+
+	// Team.drivers is an array of Driver, so both share Driver's members
+	e, _, err = GetEntry(reader, "Team")
+	assert.NoError(t, err)
+	team, err := NewTypeDefProxy(g, e)
+	assert.NoError(t, err)
+	drivers, err := team.GetChild("drivers")
+	assert.NoError(t, err)
+	driverMembers, err := driver.children()
+	assert.NoError(t, err)
+	driversMembers, err := drivers.children()
+	assert.NoError(t, err)
+	assert.Same(t, &driverMembers[0], &driversMembers[0])
+}
+
+func TestTypeGraphConcurrentUse(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	program, err := NewProgram(data)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	proxies := make([]*TypeDefProxy, 8)
+	for i := range proxies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e, _, err := program.Index().GetEntry("Team")
+			assert.NoError(t, err)
+			p, err := NewTypeDefProxy(program.Types(), e)
+			assert.NoError(t, err)
+			drivers, err := p.GetChild("drivers")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"initials", "car_number", "has_won_wdc"}, drivers.ListChildren())
+			proxies[i] = p
+		}(i)
+	}
+	wg.Wait()
+	for _, p := range proxies {
+		assert.Same(t, proxies[0], p)
+	}
+}
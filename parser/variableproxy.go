@@ -2,6 +2,7 @@ package parser
 
 import (
 	"debug/dwarf"
+	"encoding/json"
 	"fmt"
 	// "strings"
 
@@ -21,66 +22,96 @@ import (
 // a client which addresses to read and provides a writeable stream
 // of bytes to allow the client to write the variable back to memory.
 type VariableProxy struct {
-	name    string
-	Type    TypeDefProxy
-	Address int
-	value   []byte
-	client  client.Client
+	name string
+	// The symbol name of the variable, such as "_ZN2ns5countE", or empty if
+	// the compiler did not record one
+	LinkageName string
+	Type        TypeDefProxy
+	Address     int
+	// Where the address was found; the symbol table is only consulted for
+	// variables the DWARF gives no location
+	Source AddressSource
+	value  []byte
+	client client.Client
 }
 
 // Construct a new VariableProxy for a variable known to the DWARF
 // debug info.
 //
 // To create a variable from scratch , use *some other method*
-func NewVariableProxy(reader *dwarf.Reader, entry *dwarf.Entry) (*VariableProxy, error) {
-	typeDefProxy, err := NewTypeDefProxy(reader, entry)
-	if err != nil {
-		return nil, err
-	}
-	loc, err := GetLocation(entry)
+func NewVariableProxy(g *TypeGraph, entry *dwarf.Entry) (*VariableProxy, error) {
 	proxy := &VariableProxy{
-		name:    entry.Val(dwarf.AttrName).(string),
-		Type:    *typeDefProxy,
-		Address: ParseLocation(loc),
-		value:   []byte{},
-		client:  nil,
+		value:  []byte{},
+		client: nil,
 	}
-	return proxy, err
+	if err := proxy.Init(g, entry); err != nil {
+		return nil, err
+	}
+	return proxy, nil
 }
 
 func (p VariableProxy) Name() string {
 	return p.name
 }
 
-func (p *VariableProxy) Init(reader *dwarf.Reader, entry *dwarf.Entry) error {
-	typeDefProxy, err := NewTypeDefProxy(reader, entry)
+// Returns the demangled linkage name of this variable, which qualifies its
+// name with its namespaces and classes, or its name if it has no linkage
+// name
+func (p VariableProxy) DemangledName() string {
+	if p.LinkageName == "" {
+		return p.name
+	}
+	return Demangle(p.LinkageName)
+}
+
+// Returns the proxy for one element of this array variable, named after
+// the variable with the index appended, for example "teams[1]"
+func (p VariableProxy) Index(i int) (*TypeDefProxy, error) {
+	t := p.Type
+	t.name = p.name
+	return t.Index(i)
+}
+
+func (p *VariableProxy) Init(g *TypeGraph, entry *dwarf.Entry) error {
+	typeDefProxy, err := NewTypeDefProxy(g, entry)
 	if err != nil {
 		return err
 	}
 	loc, err := GetLocation(entry)
-	p.name = entry.Val(dwarf.AttrName).(string)
+	if err != nil {
+		return err
+	}
+	// The operand of DW_OP_addr is sized and ordered as in its unit
+	r := g.data.Reader()
+	r.Seek(entry.Offset)
+	address, err := ParseLocationIn(loc, r.AddressSize(), r.ByteOrder())
+	if err != nil {
+		return newEntryError(entry, err)
+	}
+	p.name = EntryName(entry)
+	p.LinkageName = LinkageName(entry)
 	p.Type = *typeDefProxy
-	p.Address = ParseLocation(loc)
-	return err
+	p.Address = address
+	return nil
 }
 
 // Retuns a slice of strings containing the name of each member of this TypeDef
 func (p VariableProxy) ListChildren() []string {
-	names := make([]string, len(p.Type.ahildren))
-	for i, c := range p.Type.ahildren {
-		names[i] = c.name
-	}
-	return names
+	return p.Type.ListChildren()
 }
 
 // TODO: change the child hierarchy to use ordered maps not slices for lookup speed?
 func (p VariableProxy) GetChild(childName string) (*TypeDefProxy, error) {
-	for _, child := range p.Type.ahildren {
+	children, err := p.Type.children()
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
 		if child.name == childName {
 			return &child, nil
 		}
 	}
-	return nil, fmt.Errorf("Could not find child %s for %s", childName, p.GoString())
+	return nil, fmt.Errorf("Could not find child %s for %s: %w", childName, p.GoString(), ErrNotFound)
 }
 
 func (p *VariableProxy) string() string {
@@ -92,6 +123,44 @@ func (p *VariableProxy) GoString() string {
 	return p.string()
 }
 
+// The serialized form of a VariableProxy
+//
+// Only the metadata describing the variable is serialized; its value and
+// client belong to a particular session and are left out.
+type variableProxyJSON struct {
+	Name        string        `json:"name"`
+	LinkageName string        `json:"linkage_name,omitempty"`
+	Type        TypeDefProxy  `json:"type"`
+	Address     int           `json:"address"`
+	Source      AddressSource `json:"source,omitempty"`
+}
+
+// Encodes the name, type and address of this variable as JSON
+func (p VariableProxy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(variableProxyJSON{
+		Name:        p.name,
+		LinkageName: p.LinkageName,
+		Type:        p.Type,
+		Address:     p.Address,
+		Source:      p.Source,
+	})
+}
+
+// Decodes a variable previously encoded by MarshalJSON
+func (p *VariableProxy) UnmarshalJSON(b []byte) error {
+	var j variableProxyJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	p.name = j.Name
+	p.LinkageName = j.LinkageName
+	p.Type = j.Type
+	p.Address = j.Address
+	p.Source = j.Source
+	p.value = []byte{}
+	return nil
+}
+
 // Set the value of this entire variable
 //
 // In the case of a multi-field struct, this is most useful for
@@ -101,7 +170,7 @@ func (p *VariableProxy) GoString() string {
 func (p *VariableProxy) Set(value []byte) error {
 	var err error = nil
 	if len(value)*8 > p.Type.bitSize {
-		err = fmt.Errorf("Attempted to set value size %d bits, larger than type with size %d bits", len(value)*8, p.Type.bitSize)
+		err = fmt.Errorf("Attempted to set value size %d bits, larger than type with size %d bits: %w", len(value)*8, p.Type.bitSize, ErrOutOfRange)
 	}
 	p.value = value
 	return err
@@ -123,6 +192,9 @@ func (p *VariableProxy) SetField(field string, value int) error {
 	if fieldEntry.bitSize%8 != 0 {
 		n += 1
 	}
+	if len(p.value) < startIndex+n {
+		return fmt.Errorf("Internal data len %d bytes is smaller than the requested field %s at bytes %d:%d: %w", len(p.value), fieldEntry.name, startIndex, startIndex+n-1, ErrOutOfRange)
+	}
 	for i := 0; i < n; i++ {
 		p.value[startIndex+i] = byte(value >> ((n - i - 1) * 8) & 0xff)
 	}
@@ -182,7 +254,7 @@ func (p *VariableProxy) GetField(field string) (int, error) {
 	byteLen := fieldEntry.bitSize / 8
 	endByte := startByte + byteLen - 1
 	if len(p.value) < (startByte + byteLen) {
-		err = fmt.Errorf("Internal data len %d bytes is smaller than the requested field %s at bytes %d:%d", len(p.value), fieldEntry.name, startByte, endByte)
+		err = fmt.Errorf("Internal data len %d bytes is smaller than the requested field %s at bytes %d:%d: %w", len(p.value), fieldEntry.name, startByte, endByte, ErrOutOfRange)
 		return 0, err
 	}
 	valInt := 0
@@ -200,7 +272,7 @@ func (p *VariableProxy) SetClient(c client.Client) {
 
 func (p *VariableProxy) Read() error {
 	if p.client == nil {
-		return fmt.Errorf("Cannot read proxy %s: no client is set!", p.string())
+		return fmt.Errorf("Cannot read proxy %s: %w", p.string(), ErrNoClient)
 	}
 	// TODO: what if this isn't byte-aligned?
 	data, err := p.client.Read(p.Address, p.Type.bitSize/8)
@@ -213,7 +285,7 @@ func (p *VariableProxy) Read() error {
 
 func (p *VariableProxy) Write() error {
 	if p.client == nil {
-		return fmt.Errorf("Cannot write proxy %s: no client is set!", p.string())
+		return fmt.Errorf("Cannot write proxy %s: %w", p.string(), ErrNoClient)
 	}
 	return p.client.Write(p.Address, p.value)
 }
@@ -2,12 +2,15 @@ package parser
 
 import (
 	"debug/dwarf"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestNewVariableProxy(t *testing.T) {
-	reader, _ := getReaderFromFile(testcaseFilename)
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
 	var teamsProxy *VariableProxy
 	var e *dwarf.Entry
 	var err error
@@ -15,7 +18,7 @@ func TestNewVariableProxy(t *testing.T) {
 	// Move on to non-trivial cases in which Children must actually be populated
 	e, _, err = GetEntry(reader, "formula_1_teams")
 	assert.NoError(t, err)
-	teamsProxy, err = NewVariableProxy(reader, e)
+	teamsProxy, err = NewVariableProxy(g, e)
 	assert.NoError(t, err)
 	// First we confirm that this variable includes the same type we found in
 	// TestNewTypeDefProxy
@@ -89,7 +92,7 @@ func TestNewVariableProxy(t *testing.T) {
 	assert.Equal(t, "formula_1_teams", teamsProxy.name)
 	assert.Equal(t, "Team", teamsProxy.Type.name)
 	assert.Equal(t, int(384), teamsProxy.Type.bitSize)
-	assert.Equal(t, teamChildren, teamsProxy.Type.ahildren)
+	assert.Equal(t, teamChildren, detach(t, teamsProxy.Type).ahildren)
 }
 
 func TestGetSetVariableProxy(t *testing.T) {
@@ -289,3 +292,21 @@ func TestGetSetMultilevelVariableProxy(t *testing.T) {
 }
 
 func TestGetAccessMetadata(t *testing.T) {}
+
+func TestVariableProxyJSON(t *testing.T) {
+	data, _ := getDataFromFile(testcaseFilename)
+	reader := data.Reader()
+	g := NewTypeGraph(data)
+	e, _, err := GetEntry(reader, "formula_1_teams")
+	assert.NoError(t, err)
+	teamsProxy, err := NewVariableProxy(g, e)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(teamsProxy)
+	assert.NoError(t, err)
+	var decoded VariableProxy
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, teamsProxy.name, decoded.name)
+	assert.Equal(t, teamsProxy.Address, decoded.Address)
+	assert.Equal(t, detach(t, teamsProxy.Type), decoded.Type)
+}
@@ -0,0 +1,442 @@
+// Package repl is a line-oriented shell for exploring a binary with an
+// Explorer, as an alternative to the dwarf-explore TUI.
+//
+// Commands:
+//
+//	cd <name>[/<name>...]  step into a child; ".." moves up, "/" or no name returns to the CUs
+//	cd /<path>             go to an absolute path as printed by pwd
+//	back                   return to where the last command moved from
+//	pwd                    print the absolute path of the current item
+//	ls                     list the children of the current item
+//	info                   describe the current item
+//	type                   show the type of the current item and its members, or the signature of a function
+//	read [path]            read the current variable or field, or a path relative to it
+//	set [path] <value>     write an integer to the current variable or field
+//	regs [<n>=<value>...]  show or set the registers of the frame locals are read in
+//	history                list the commands entered so far
+//	help                   list commands
+//	exit, quit             leave the shell
+//
+// Names given to cd may include indices, as in "cd drivers[1]". Paths given
+// to read and set continue from the current item as in C, for example
+// "[1].drivers[0]" from an array of structs.
+//
+// Parameters and locals of functions are read in the frame given by the
+// registers set with regs, or supplied by the client. Registers are
+// numbered as in DWARF, so on x86-64 "regs 6=0x7ffc0000" sets rbp.
+package repl
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/jdginn/durins-door/explorer"
+	"github.com/jdginn/durins-door/parser"
+)
+
+// Returned by Execute when the user asks to leave the shell
+var ErrExit = errors.New("exit")
+
+// Returned by Execute for commands it does not understand
+var ErrUnknownCommand = errors.New("unknown command")
+
+// The usage of each command, as printed by help
+var usage = []string{
+	"cd <name>[/<name>...]  step into a child; \"..\" moves up, \"/\" or no name returns to the CUs",
+	"cd /<path>             go to an absolute path as printed by pwd",
+	"back                   return to where the last command moved from",
+	"pwd                    print the absolute path of the current item",
+	"ls                     list the children of the current item",
+	"info                   describe the current item",
+	"type                   show the type of the current item and its members, or the signature of a function",
+	"read [path]            read the current variable or field, or a path relative to it",
+	"set [path] <value>     write an integer to the current variable or field",
+	"regs [<n>=<value>...]  show or set the registers of the frame locals are read in",
+	"history                list the commands entered so far",
+	"help                   list commands",
+	"exit, quit             leave the shell",
+}
+
+// The name of every command, sorted, for completion
+var commands = []string{"back", "cd", "exit", "help", "history", "info", "ls", "pwd", "quit", "read", "regs", "set", "type"}
+
+// REPL runs commands against an explorer and prints their results
+type REPL struct {
+	ex      *explorer.Explorer
+	out     io.Writer
+	history []string
+}
+
+// Returns a shell over this explorer that prints to out
+func New(ex *explorer.Explorer, out io.Writer) *REPL {
+	return &REPL{
+		ex:  ex,
+		out: out,
+	}
+}
+
+// Returns the prompt describing the current item
+func (r *REPL) Prompt() string {
+	return r.ex.CurrName() + "> "
+}
+
+// Returns every command executed so far, oldest first
+func (r *REPL) History() []string {
+	ret := make([]string, len(r.history))
+	copy(ret, r.history)
+	return ret
+}
+
+// Runs one line of input
+func (r *REPL) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	r.history = append(r.history, strings.TrimSpace(line))
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "cd":
+		return r.cd(args)
+	case "back":
+		return r.ex.Back()
+	case "pwd":
+		fmt.Fprintln(r.out, "/"+r.ex.Path())
+		return nil
+	case "ls":
+		for _, c := range r.ex.ListChildren() {
+			fmt.Fprintln(r.out, c)
+		}
+		return nil
+	case "info":
+		return r.info()
+	case "type":
+		return r.typeDef()
+	case "read":
+		return r.read(args)
+	case "set":
+		return r.set(args)
+	case "regs":
+		return r.regs(args)
+	case "history":
+		for i, h := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, h)
+		}
+		return nil
+	case "help":
+		for _, u := range usage {
+			fmt.Fprintln(r.out, u)
+		}
+		return nil
+	case "exit", "quit":
+		return ErrExit
+	default:
+		return fmt.Errorf("%s: %w", cmd, ErrUnknownCommand)
+	}
+}
+
+func (r *REPL) cd(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: cd <name>[/<name>...]")
+	}
+	path := "/"
+	if len(args) == 1 {
+		path = args[0]
+	}
+	// CU names may contain slashes, so paths starting from the list of CUs
+	// are handed to Goto whole; single names there may be of any entry
+	if strings.HasPrefix(path, "/") || r.ex.Path() == "" && !strings.HasPrefix(path, ".") && strings.Contains(path, "/") {
+		return r.ex.Goto(path)
+	}
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		var err error
+		switch name {
+		case "", ".":
+		case "..":
+			err = r.ex.Up()
+		default:
+			err = r.ex.StepIntoChild(name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *REPL) info() error {
+	if info := r.ex.Info(); info != "" {
+		fmt.Fprintln(r.out, info)
+		return nil
+	}
+	switch p := r.ex.CurrProxy().(type) {
+	case *parser.VariableProxy:
+		fmt.Fprintf(r.out, "variable %s at 0x%x, %d bytes\n", p.Name(), p.Address, p.Type.BitSize()/8*numElements(p.Type.ArrayRanges()))
+	case *parser.TypeDefProxy:
+		fmt.Fprintf(r.out, "member %s%s at offset %d, %d bytes\n", p.Name(), dimsString(p.ArrayRanges()), p.BitOffset()/8, p.BitSize()/8*numElements(p.ArrayRanges()))
+	default:
+		fmt.Fprintln(r.out, r.ex.CurrName())
+	}
+	return nil
+}
+
+func (r *REPL) typeDef() error {
+	var t parser.TypeDefProxy
+	switch p := r.ex.CurrProxy().(type) {
+	case *parser.VariableProxy:
+		t = p.Type
+	case *parser.TypeDefProxy:
+		t = *p
+	case *parser.FunctionProxy:
+		fmt.Fprintln(r.out, p.Signature())
+		return nil
+	default:
+		return fmt.Errorf("%s has no type: %w", r.ex.CurrName(), explorer.ErrInvalidMode)
+	}
+	fmt.Fprintf(r.out, "%s%s, %d bytes\n", t.Name(), dimsString(t.ArrayRanges()), t.BitSize()/8)
+	members, err := t.Members()
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		fmt.Fprintf(r.out, "  %-16s offset %-4d size %d\n", m.Name()+dimsString(m.ArrayRanges()), m.BitOffset()/8, m.BitSize()/8)
+	}
+	return nil
+}
+
+func (r *REPL) read(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: read [path]")
+	}
+	rel := ""
+	if len(args) == 1 {
+		rel = args[0]
+	}
+	return r.printField(rel)
+}
+
+func (r *REPL) set(args []string) error {
+	var rel, value string
+	switch len(args) {
+	case 1:
+		value = args[0]
+	case 2:
+		rel, value = args[0], args[1]
+	default:
+		return fmt.Errorf("usage: set [path] <value>")
+	}
+	f, err := r.ex.Field(rel)
+	if err != nil {
+		return err
+	}
+	data, err := parser.ParseInt(value, (f.BitSize+7)/8, r.ex.Program().ByteOrder())
+	if err != nil {
+		return err
+	}
+	if err := r.ex.WriteField(rel, data); err != nil {
+		return err
+	}
+	return r.printField(rel)
+}
+
+func (r *REPL) regs(args []string) error {
+	if len(args) == 0 {
+		regs, err := r.ex.Registers()
+		if err != nil {
+			return err
+		}
+		nums := make([]int, 0, len(regs))
+		for n := range regs {
+			nums = append(nums, n)
+		}
+		sort.Ints(nums)
+		for _, n := range nums {
+			fmt.Fprintf(r.out, "%4d  0x%x\n", n, regs[n])
+		}
+		return nil
+	}
+	regs := make(parser.Registers)
+	if current, err := r.ex.Registers(); err == nil {
+		for n, v := range current {
+			regs[n] = v
+		}
+	}
+	for _, arg := range args {
+		eq := strings.Index(arg, "=")
+		if eq <= 0 {
+			return fmt.Errorf("usage: regs [<n>=<value>...]")
+		}
+		n, err := strconv.Atoi(arg[:eq])
+		if err != nil {
+			return fmt.Errorf("Bad register number %q: %w", arg[:eq], parser.ErrBadValue)
+		}
+		v, err := strconv.ParseUint(arg[eq+1:], 0, 64)
+		if err != nil {
+			return fmt.Errorf("Bad register value %q: %w", arg[eq+1:], parser.ErrBadValue)
+		}
+		regs[n] = v
+	}
+	r.ex.SetRegisters(regs)
+	return nil
+}
+
+func (r *REPL) printField(rel string) error {
+	f, err := r.ex.Field(rel)
+	if err != nil {
+		return err
+	}
+	data, err := r.ex.ReadField(rel)
+	if err != nil {
+		return err
+	}
+	if len(data) > 8 {
+		fmt.Fprintf(r.out, "%s at 0x%x\n", hex.EncodeToString(data), f.Address)
+		return nil
+	}
+	v, err := parser.DecodeUint(data, r.ex.Program().ByteOrder())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "%d (0x%x) at 0x%x\n", v, v, f.Address)
+	return nil
+}
+
+// Returns the ways the last word of this line could be completed: commands
+// for the first word, and children of the current item for the argument
+// of cd
+func (r *REPL) Complete(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || (len(fields) == 1 && !strings.HasSuffix(line, " ")) {
+		prefix := ""
+		if len(fields) == 1 {
+			prefix = fields[0]
+		}
+		return withPrefix(commands, prefix)
+	}
+	if fields[0] != "cd" || len(fields) > 2 || (len(fields) == 2 && strings.HasSuffix(line, " ")) {
+		return nil
+	}
+	prefix := ""
+	if len(fields) == 2 {
+		prefix = fields[1]
+	}
+	// Only the children of the current item are known, so names after a
+	// slash cannot be completed
+	if strings.Contains(prefix, "/") {
+		return nil
+	}
+	return withPrefix(r.ex.ListChildren(), prefix)
+}
+
+func withPrefix(names []string, prefix string) []string {
+	var ret []string
+	for _, n := range names {
+		if strings.HasPrefix(n, prefix) {
+			ret = append(ret, n)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Runs commands read line by line from in until it ends or exit is entered.
+// Errors from commands are printed and do not stop the shell.
+func (r *REPL) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, r.Prompt())
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		if err := r.Execute(scanner.Text()); err != nil {
+			if errors.Is(err, ErrExit) {
+				return nil
+			}
+			fmt.Fprintln(r.out, "error:", err)
+		}
+	}
+}
+
+// Runs commands entered at a terminal, which must already be in raw mode,
+// with tab completion and the terminal's own history
+func (r *REPL) RunTerminal(t *term.Terminal) error {
+	r.out = t
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return r.completeLine(t, line, pos)
+	}
+	for {
+		t.SetPrompt(r.Prompt())
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.Execute(line); err != nil {
+			if errors.Is(err, ErrExit) {
+				return nil
+			}
+			fmt.Fprintln(r.out, "error:", err)
+		}
+	}
+}
+
+// Completes the word before the cursor as far as all candidates agree and
+// lists the candidates if that is not far enough to be unique
+func (r *REPL) completeLine(t *term.Terminal, line string, pos int) (string, int, bool) {
+	before := line[:pos]
+	candidates := r.Complete(before)
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+	start := strings.LastIndexAny(before, " \t") + 1
+	completion := commonPrefix(candidates)
+	if len(candidates) == 1 {
+		completion += " "
+	}
+	if completion == before[start:] {
+		fmt.Fprintln(t, strings.Join(candidates, "  "))
+		return "", 0, false
+	}
+	newLine := before[:start] + completion + line[pos:]
+	return newLine, start + len(completion), true
+}
+
+func commonPrefix(names []string) string {
+	prefix := names[0]
+	for _, n := range names[1:] {
+		for !strings.HasPrefix(n, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func dimsString(dims []int) string {
+	var sb strings.Builder
+	for _, d := range dims {
+		fmt.Fprintf(&sb, "[%d]", d)
+	}
+	return sb.String()
+}
+
+func numElements(dims []int) int {
+	n := 1
+	for _, d := range dims {
+		n *= d
+	}
+	return n
+}
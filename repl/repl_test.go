@@ -0,0 +1,169 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/client/file"
+	"github.com/jdginn/durins-door/explorer"
+	"github.com/jdginn/durins-door/parser"
+)
+
+var testcaseFilename = "../testcase-compiler/testcase.dwarf"
+
+func newREPL(t *testing.T) (*REPL, *bytes.Buffer) {
+	ex, err := explorer.NewExplorerFromFile(testcaseFilename)
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	return New(ex, &out), &out
+}
+
+func TestNavigate(t *testing.T) {
+	r, out := newREPL(t)
+	assert.Equal(t, "all CUs> ", r.Prompt())
+	assert.NoError(t, r.Execute("ls"))
+	assert.Equal(t, "testcase.cpp\n", out.String())
+
+	assert.NoError(t, r.Execute("cd testcase.cpp/formula_1_teams"))
+	assert.Equal(t, "formula_1_teams> ", r.Prompt())
+	out.Reset()
+	assert.NoError(t, r.Execute("type"))
+	assert.Contains(t, out.String(), "  drivers[2]       offset 0    size 12\n")
+	assert.Contains(t, out.String(), "  last_wcc         offset 44   size 4\n")
+	out.Reset()
+	assert.NoError(t, r.Execute("info"))
+	assert.Regexp(t, `^variable formula_1_teams at 0x[0-9a-f]+, 96 bytes\n$`, out.String())
+
+	assert.NoError(t, r.Execute("cd sponsors"))
+	out.Reset()
+	assert.NoError(t, r.Execute("info"))
+	assert.Equal(t, "member sponsors[4] at offset 24, 8 bytes\n", out.String())
+
+	assert.NoError(t, r.Execute("cd ../.."))
+	assert.Equal(t, "testcase.cpp> ", r.Prompt())
+	assert.NoError(t, r.Execute("cd hamilton"))
+	assert.NoError(t, r.Execute("cd"))
+	assert.Equal(t, "all CUs> ", r.Prompt())
+
+	assert.NoError(t, r.Execute("cd /testcase.cpp/formula_1_teams[1].drivers"))
+	assert.NoError(t, r.Execute("cd [0]/car_number"))
+	out.Reset()
+	assert.NoError(t, r.Execute("pwd"))
+	assert.Equal(t, "/testcase.cpp/formula_1_teams[1].drivers[0].car_number\n", out.String())
+	assert.NoError(t, r.Execute("back"))
+	assert.Equal(t, "drivers[0]> ", r.Prompt())
+
+	// Names other than CUs are stepped into through their CU, so the
+	// printed path can be returned to
+	assert.NoError(t, r.Execute("cd /"))
+	assert.NoError(t, r.Execute("cd formula_1_teams"))
+	out.Reset()
+	assert.NoError(t, r.Execute("pwd"))
+	assert.Equal(t, "/testcase.cpp/formula_1_teams\n", out.String())
+	assert.NoError(t, r.Execute("cd /"))
+	assert.NoError(t, r.Execute("cd "+strings.TrimSpace(out.String())))
+	assert.Equal(t, "formula_1_teams> ", r.Prompt())
+	assert.NoError(t, r.Execute("cd .."))
+	assert.Equal(t, "testcase.cpp> ", r.Prompt())
+	assert.NoError(t, r.Execute("cd /"))
+	assert.NoError(t, r.Execute("cd formula_1_teams[1].drivers"))
+	out.Reset()
+	assert.NoError(t, r.Execute("pwd"))
+	assert.Equal(t, "/testcase.cpp/formula_1_teams[1].drivers\n", out.String())
+
+	assert.NoError(t, r.Execute("cd /testcase.cpp/main"))
+	out.Reset()
+	assert.NoError(t, r.Execute("type"))
+	assert.Equal(t, "int main()\n", out.String())
+
+	assert.ErrorIs(t, r.Execute("cd nowhere"), parser.ErrNotFound)
+	assert.ErrorIs(t, r.Execute("frobnicate"), ErrUnknownCommand)
+	assert.ErrorIs(t, r.Execute("exit"), ErrExit)
+
+	out.Reset()
+	assert.NoError(t, r.Execute("history"))
+	assert.True(t, strings.HasPrefix(out.String(), "   1  ls\n   2  cd testcase.cpp/formula_1_teams\n"))
+	assert.Equal(t, "history", r.History()[len(r.History())-1])
+}
+
+func TestReadSet(t *testing.T) {
+	r, out := newREPL(t)
+	assert.NoError(t, r.Execute("cd /testcase.cpp/hamilton"))
+	assert.ErrorIs(t, r.Execute("read"), parser.ErrNoClient)
+
+	// Serve memory from a scratch file of zeroes large enough to cover
+	// every global
+	f, err := os.Create(filepath.Join(t.TempDir(), "memory.bin"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Truncate(0x500000))
+	c, err := file.New(f)
+	assert.NoError(t, err)
+	r.ex.SetClient(c)
+
+	assert.NoError(t, r.Execute("set car_number 44"))
+	assert.Regexp(t, `^44 \(0x2c\) at 0x[0-9a-f]+\n$`, out.String())
+	assert.NoError(t, r.Execute("cd car_number"))
+	assert.NoError(t, r.Execute("set -1"))
+	out.Reset()
+	assert.NoError(t, r.Execute("read"))
+	assert.Regexp(t, `^4294967295 \(0xffffffff\) at`, out.String())
+	assert.NoError(t, r.Execute("cd .."))
+	out.Reset()
+	assert.NoError(t, r.Execute("read"))
+	assert.Regexp(t, `^00000000ffffffff00000000 at 0x[0-9a-f]+\n$`, out.String())
+	assert.ErrorIs(t, r.Execute("set initials 65536"), parser.ErrOutOfRange)
+	assert.ErrorIs(t, r.Execute("set car_number x"), parser.ErrBadValue)
+}
+
+func TestRegs(t *testing.T) {
+	r, out := newREPL(t)
+	assert.ErrorIs(t, r.Execute("regs"), parser.ErrNoRegister)
+	assert.NoError(t, r.Execute("regs 7=32 6=0x10"))
+	assert.NoError(t, r.Execute("regs 7=0x30"))
+	assert.NoError(t, r.Execute("regs"))
+	assert.Equal(t, "   6  0x10\n   7  0x30\n", out.String())
+	assert.ErrorIs(t, r.Execute("regs rbp=1"), parser.ErrBadValue)
+	assert.ErrorIs(t, r.Execute("regs 6=x"), parser.ErrBadValue)
+}
+
+func TestComplete(t *testing.T) {
+	r, _ := newREPL(t)
+	assert.Equal(t, []string{"cd"}, r.Complete("c"))
+	assert.Equal(t, []string{"pwd"}, r.Complete("p"))
+	assert.Equal(t, []string{"help", "history"}, r.Complete("h"))
+	assert.Equal(t, []string{"testcase.cpp"}, r.Complete("cd "))
+	assert.NoError(t, r.Execute("cd testcase.cpp"))
+	assert.Equal(t, []string{"formula_1_teams"}, r.Complete("cd f"))
+	assert.Nil(t, r.Complete("cd formula_1_teams "))
+	assert.Nil(t, r.Complete("ls f"))
+
+	assert.Equal(t, []string{"quit"}, r.Complete("q"))
+
+	assert.Equal(t, "h", commonPrefix([]string{"help", "history"}))
+	assert.Equal(t, "his", commonPrefix([]string{"history", "hist", "his"}))
+}
+
+func TestRun(t *testing.T) {
+	r, out := newREPL(t)
+	assert.NoError(t, r.Run(strings.NewReader("cd testcase.cpp\nbad\nls\nexit\nls\n")))
+	assert.Contains(t, out.String(), "testcase.cpp> error: bad: unknown command\n")
+	assert.Contains(t, out.String(), "formula_1_teams\n")
+	assert.Equal(t, []string{"cd testcase.cpp", "bad", "ls", "exit"}, r.History())
+}
+
+func TestHelp(t *testing.T) {
+	r, out := newREPL(t)
+	assert.NoError(t, r.Execute("help"))
+	assert.Contains(t, out.String(), "\nls                     list the children of the current item\n")
+	assert.Contains(t, out.String(), "\nexit, quit             leave the shell\n")
+	// Every command has its usage listed
+	for _, c := range commands {
+		assert.Regexp(t, "(?m)^(exit, )?"+c+"\\b", out.String())
+	}
+	assert.ErrorIs(t, r.Execute("quit"), ErrExit)
+}
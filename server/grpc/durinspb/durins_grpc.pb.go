@@ -0,0 +1,557 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: durins.proto
+
+package durinspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// DurinsDoorClient is the client API for DurinsDoor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DurinsDoorClient interface {
+	// Lists the loaded binaries
+	ListBinaries(ctx context.Context, in *ListBinariesRequest, opts ...grpc.CallOption) (*ListBinariesResponse, error)
+	// Lists the compile units of a binary
+	ListCUs(ctx context.Context, in *ListCUsRequest, opts ...grpc.CallOption) (*ListCUsResponse, error)
+	// Finds entries by name, optionally restricted to a tag. Names no entry
+	// carries are looked up as mangled or demangled symbol names.
+	LookupEntries(ctx context.Context, in *LookupEntriesRequest, opts ...grpc.CallOption) (*LookupEntriesResponse, error)
+	// Describes a type by name
+	GetType(ctx context.Context, in *GetTypeRequest, opts ...grpc.CallOption) (*Type, error)
+	// Describes a global variable by name
+	GetVariable(ctx context.Context, in *GetVariableRequest, opts ...grpc.CallOption) (*Variable, error)
+	// Reads a variable or a field of one
+	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*Value, error)
+	// Writes a variable or a field of one and returns the value read back
+	Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*Value, error)
+	// Reads a variable or field periodically, sending its value whenever it
+	// changes
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (DurinsDoor_WatchClient, error)
+	// Lists the source files of each compile unit
+	ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error)
+	// Finds the source line containing an address
+	AddrToLine(ctx context.Context, in *AddrToLineRequest, opts ...grpc.CallOption) (*SourceLine, error)
+	// Finds the addresses of the instructions starting a source line
+	LineToAddrs(ctx context.Context, in *LineToAddrsRequest, opts ...grpc.CallOption) (*LineToAddrsResponse, error)
+	// Names the variable, member and element containing an address
+	Symbolize(ctx context.Context, in *SymbolizeRequest, opts ...grpc.CallOption) (*Symbol, error)
+}
+
+type durinsDoorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDurinsDoorClient(cc grpc.ClientConnInterface) DurinsDoorClient {
+	return &durinsDoorClient{cc}
+}
+
+func (c *durinsDoorClient) ListBinaries(ctx context.Context, in *ListBinariesRequest, opts ...grpc.CallOption) (*ListBinariesResponse, error) {
+	out := new(ListBinariesResponse)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/ListBinaries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) ListCUs(ctx context.Context, in *ListCUsRequest, opts ...grpc.CallOption) (*ListCUsResponse, error) {
+	out := new(ListCUsResponse)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/ListCUs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) LookupEntries(ctx context.Context, in *LookupEntriesRequest, opts ...grpc.CallOption) (*LookupEntriesResponse, error) {
+	out := new(LookupEntriesResponse)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/LookupEntries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) GetType(ctx context.Context, in *GetTypeRequest, opts ...grpc.CallOption) (*Type, error) {
+	out := new(Type)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/GetType", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) GetVariable(ctx context.Context, in *GetVariableRequest, opts ...grpc.CallOption) (*Variable, error) {
+	out := new(Variable)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/GetVariable", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*Value, error) {
+	out := new(Value)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/Read", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*Value, error) {
+	out := new(Value)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/Write", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (DurinsDoor_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DurinsDoor_ServiceDesc.Streams[0], "/durins.v1.DurinsDoor/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &durinsDoorWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DurinsDoor_WatchClient interface {
+	Recv() (*Value, error)
+	grpc.ClientStream
+}
+
+type durinsDoorWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *durinsDoorWatchClient) Recv() (*Value, error) {
+	m := new(Value)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *durinsDoorClient) ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error) {
+	out := new(ListFilesResponse)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/ListFiles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) AddrToLine(ctx context.Context, in *AddrToLineRequest, opts ...grpc.CallOption) (*SourceLine, error) {
+	out := new(SourceLine)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/AddrToLine", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) LineToAddrs(ctx context.Context, in *LineToAddrsRequest, opts ...grpc.CallOption) (*LineToAddrsResponse, error) {
+	out := new(LineToAddrsResponse)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/LineToAddrs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *durinsDoorClient) Symbolize(ctx context.Context, in *SymbolizeRequest, opts ...grpc.CallOption) (*Symbol, error) {
+	out := new(Symbol)
+	err := c.cc.Invoke(ctx, "/durins.v1.DurinsDoor/Symbolize", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DurinsDoorServer is the server API for DurinsDoor service.
+// All implementations must embed UnimplementedDurinsDoorServer
+// for forward compatibility
+type DurinsDoorServer interface {
+	// Lists the loaded binaries
+	ListBinaries(context.Context, *ListBinariesRequest) (*ListBinariesResponse, error)
+	// Lists the compile units of a binary
+	ListCUs(context.Context, *ListCUsRequest) (*ListCUsResponse, error)
+	// Finds entries by name, optionally restricted to a tag. Names no entry
+	// carries are looked up as mangled or demangled symbol names.
+	LookupEntries(context.Context, *LookupEntriesRequest) (*LookupEntriesResponse, error)
+	// Describes a type by name
+	GetType(context.Context, *GetTypeRequest) (*Type, error)
+	// Describes a global variable by name
+	GetVariable(context.Context, *GetVariableRequest) (*Variable, error)
+	// Reads a variable or a field of one
+	Read(context.Context, *ReadRequest) (*Value, error)
+	// Writes a variable or a field of one and returns the value read back
+	Write(context.Context, *WriteRequest) (*Value, error)
+	// Reads a variable or field periodically, sending its value whenever it
+	// changes
+	Watch(*WatchRequest, DurinsDoor_WatchServer) error
+	// Lists the source files of each compile unit
+	ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error)
+	// Finds the source line containing an address
+	AddrToLine(context.Context, *AddrToLineRequest) (*SourceLine, error)
+	// Finds the addresses of the instructions starting a source line
+	LineToAddrs(context.Context, *LineToAddrsRequest) (*LineToAddrsResponse, error)
+	// Names the variable, member and element containing an address
+	Symbolize(context.Context, *SymbolizeRequest) (*Symbol, error)
+	mustEmbedUnimplementedDurinsDoorServer()
+}
+
+// UnimplementedDurinsDoorServer must be embedded to have forward compatible implementations.
+type UnimplementedDurinsDoorServer struct {
+}
+
+func (UnimplementedDurinsDoorServer) ListBinaries(context.Context, *ListBinariesRequest) (*ListBinariesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBinaries not implemented")
+}
+func (UnimplementedDurinsDoorServer) ListCUs(context.Context, *ListCUsRequest) (*ListCUsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCUs not implemented")
+}
+func (UnimplementedDurinsDoorServer) LookupEntries(context.Context, *LookupEntriesRequest) (*LookupEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupEntries not implemented")
+}
+func (UnimplementedDurinsDoorServer) GetType(context.Context, *GetTypeRequest) (*Type, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetType not implemented")
+}
+func (UnimplementedDurinsDoorServer) GetVariable(context.Context, *GetVariableRequest) (*Variable, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVariable not implemented")
+}
+func (UnimplementedDurinsDoorServer) Read(context.Context, *ReadRequest) (*Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Read not implemented")
+}
+func (UnimplementedDurinsDoorServer) Write(context.Context, *WriteRequest) (*Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Write not implemented")
+}
+func (UnimplementedDurinsDoorServer) Watch(*WatchRequest, DurinsDoor_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDurinsDoorServer) ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFiles not implemented")
+}
+func (UnimplementedDurinsDoorServer) AddrToLine(context.Context, *AddrToLineRequest) (*SourceLine, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddrToLine not implemented")
+}
+func (UnimplementedDurinsDoorServer) LineToAddrs(context.Context, *LineToAddrsRequest) (*LineToAddrsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LineToAddrs not implemented")
+}
+func (UnimplementedDurinsDoorServer) Symbolize(context.Context, *SymbolizeRequest) (*Symbol, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Symbolize not implemented")
+}
+func (UnimplementedDurinsDoorServer) mustEmbedUnimplementedDurinsDoorServer() {}
+
+// UnsafeDurinsDoorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DurinsDoorServer will
+// result in compilation errors.
+type UnsafeDurinsDoorServer interface {
+	mustEmbedUnimplementedDurinsDoorServer()
+}
+
+func RegisterDurinsDoorServer(s grpc.ServiceRegistrar, srv DurinsDoorServer) {
+	s.RegisterService(&DurinsDoor_ServiceDesc, srv)
+}
+
+func _DurinsDoor_ListBinaries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBinariesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).ListBinaries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/ListBinaries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).ListBinaries(ctx, req.(*ListBinariesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_ListCUs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCUsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).ListCUs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/ListCUs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).ListCUs(ctx, req.(*ListCUsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_LookupEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).LookupEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/LookupEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).LookupEntries(ctx, req.(*LookupEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_GetType_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).GetType(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/GetType",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).GetType(ctx, req.(*GetTypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_GetVariable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVariableRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).GetVariable(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/GetVariable",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).GetVariable(ctx, req.(*GetVariableRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_Read_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).Read(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/Read",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).Read(ctx, req.(*ReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_Write_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).Write(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/Write",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).Write(ctx, req.(*WriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DurinsDoorServer).Watch(m, &durinsDoorWatchServer{stream})
+}
+
+type DurinsDoor_WatchServer interface {
+	Send(*Value) error
+	grpc.ServerStream
+}
+
+type durinsDoorWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *durinsDoorWatchServer) Send(m *Value) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _DurinsDoor_ListFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).ListFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/ListFiles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).ListFiles(ctx, req.(*ListFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_AddrToLine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddrToLineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).AddrToLine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/AddrToLine",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).AddrToLine(ctx, req.(*AddrToLineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_LineToAddrs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LineToAddrsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).LineToAddrs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/LineToAddrs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).LineToAddrs(ctx, req.(*LineToAddrsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DurinsDoor_Symbolize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SymbolizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DurinsDoorServer).Symbolize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/durins.v1.DurinsDoor/Symbolize",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DurinsDoorServer).Symbolize(ctx, req.(*SymbolizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DurinsDoor_ServiceDesc is the grpc.ServiceDesc for DurinsDoor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DurinsDoor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "durins.v1.DurinsDoor",
+	HandlerType: (*DurinsDoorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListBinaries",
+			Handler:    _DurinsDoor_ListBinaries_Handler,
+		},
+		{
+			MethodName: "ListCUs",
+			Handler:    _DurinsDoor_ListCUs_Handler,
+		},
+		{
+			MethodName: "LookupEntries",
+			Handler:    _DurinsDoor_LookupEntries_Handler,
+		},
+		{
+			MethodName: "GetType",
+			Handler:    _DurinsDoor_GetType_Handler,
+		},
+		{
+			MethodName: "GetVariable",
+			Handler:    _DurinsDoor_GetVariable_Handler,
+		},
+		{
+			MethodName: "Read",
+			Handler:    _DurinsDoor_Read_Handler,
+		},
+		{
+			MethodName: "Write",
+			Handler:    _DurinsDoor_Write_Handler,
+		},
+		{
+			MethodName: "ListFiles",
+			Handler:    _DurinsDoor_ListFiles_Handler,
+		},
+		{
+			MethodName: "AddrToLine",
+			Handler:    _DurinsDoor_AddrToLine_Handler,
+		},
+		{
+			MethodName: "LineToAddrs",
+			Handler:    _DurinsDoor_LineToAddrs_Handler,
+		},
+		{
+			MethodName: "Symbolize",
+			Handler:    _DurinsDoor_Symbolize_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _DurinsDoor_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "durins.proto",
+}
@@ -0,0 +1,341 @@
+// Package grpc serves the types and variables of one or more binaries over
+// gRPC, implementing the DurinsDoor service described in proto/durins.proto.
+//
+// Every request names a binary by the ID it was loaded under. Values are
+// read and written through the client configured for the binary and are
+// exchanged as bytes in target memory order, plus an integer for values of
+// at most 8 bytes.
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jdginn/durins-door/parser"
+	"github.com/jdginn/durins-door/server"
+	"github.com/jdginn/durins-door/server/grpc/durinspb"
+)
+
+// How often Watch reads a value when the request does not say
+const DefaultWatchInterval = time.Second
+
+// Server implements the DurinsDoor service for the binaries in its registry
+type Server struct {
+	durinspb.UnimplementedDurinsDoorServer
+	binaries *server.Registry
+}
+
+// Returns a server with no binaries loaded
+func NewServer() *Server {
+	return NewServerFromRegistry(server.NewRegistry())
+}
+
+// Returns a server for the binaries in this registry, which may be shared
+// with other servers
+func NewServerFromRegistry(r *server.Registry) *Server {
+	return &Server{
+		binaries: r,
+	}
+}
+
+// Makes a binary available under its ID, replacing any binary already
+// loaded with that ID
+func (s *Server) Add(b *server.Binary) {
+	s.binaries.Add(b)
+}
+
+// Removes the binary with this ID
+func (s *Server) Remove(id string) {
+	s.binaries.Remove(id)
+}
+
+// Registers this server's service with a gRPC server
+func (s *Server) Register(r gogrpc.ServiceRegistrar) {
+	durinspb.RegisterDurinsDoorServer(r, s)
+}
+
+func (s *Server) ListBinaries(ctx context.Context, req *durinspb.ListBinariesRequest) (*durinspb.ListBinariesResponse, error) {
+	binaries := s.binaries.List()
+	ret := &durinspb.ListBinariesResponse{
+		Binaries: make([]*durinspb.Binary, len(binaries)),
+	}
+	for i, b := range binaries {
+		ret.Binaries[i] = &durinspb.Binary{Id: b.ID, Path: b.Path}
+	}
+	return ret, nil
+}
+
+func (s *Server) ListCUs(ctx context.Context, req *durinspb.ListCUsRequest) (*durinspb.ListCUsResponse, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	cus := b.Program.Index().CUs()
+	ret := &durinspb.ListCUsResponse{
+		Names: make([]string, len(cus)),
+	}
+	for i, cu := range cus {
+		ret.Names[i] = cu.Name
+	}
+	return ret, nil
+}
+
+func (s *Server) LookupEntries(ctx context.Context, req *durinspb.LookupEntriesRequest) (*durinspb.LookupEntriesResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing name")
+	}
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	entries := b.Lookup(req.Name, req.Tag)
+	ret := &durinspb.LookupEntriesResponse{
+		Entries: make([]*durinspb.Entry, len(entries)),
+	}
+	for i, ie := range entries {
+		ret.Entries[i] = &durinspb.Entry{
+			Name:     ie.Name,
+			Tag:      ie.Tag.String(),
+			Offset:   uint64(ie.Offset),
+			CuOffset: uint64(ie.CU),
+		}
+	}
+	return ret, nil
+}
+
+func (s *Server) GetType(ctx context.Context, req *durinspb.GetTypeRequest) (*durinspb.Type, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	p, err := b.Type(req.Name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	ret, err := toType(*p)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return ret, nil
+}
+
+func (s *Server) GetVariable(ctx context.Context, req *durinspb.GetVariableRequest) (*durinspb.Variable, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	p, err := b.Variable(req.Name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	t, err := toType(p.Type)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &durinspb.Variable{
+		Name:          p.Name(),
+		Address:       uint64(p.Address),
+		Type:          t,
+		AddressSource: p.Source.String(),
+		LinkageName:   p.LinkageName,
+		DemangledName: p.DemangledName(),
+	}, nil
+}
+
+func (s *Server) Read(ctx context.Context, req *durinspb.ReadRequest) (*durinspb.Value, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	v, err := b.Read(req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toValue(v), nil
+}
+
+func (s *Server) Write(ctx context.Context, req *durinspb.WriteRequest) (*durinspb.Value, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	var v server.Value
+	switch value := req.Value.(type) {
+	case *durinspb.WriteRequest_Data:
+		v, err = b.Write(req.Path, value.Data)
+	case *durinspb.WriteRequest_Integer:
+		v, err = b.WriteUint(req.Path, value.Integer)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Exactly one of data and integer must be set")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toValue(v), nil
+}
+
+// Sends the current value, then polls and sends it again each time it
+// changes until the client cancels or a read fails
+func (s *Server) Watch(req *durinspb.WatchRequest, stream durinspb.DurinsDoor_WatchServer) error {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return toStatus(err)
+	}
+	interval := DefaultWatchInterval
+	if req.IntervalMs != 0 {
+		interval = time.Duration(req.IntervalMs) * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		v, err := b.Read(req.Path)
+		if err != nil {
+			return toStatus(err)
+		}
+		if last == nil || !bytes.Equal(last, v.Bytes) {
+			if err := stream.Send(toValue(v)); err != nil {
+				return err
+			}
+			last = v.Bytes
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) ListFiles(ctx context.Context, req *durinspb.ListFilesRequest) (*durinspb.ListFilesResponse, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	files, err := b.Files()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	ret := &durinspb.ListFilesResponse{
+		Cus: make([]*durinspb.CUFiles, len(files)),
+	}
+	for i, f := range files {
+		ret.Cus[i] = &durinspb.CUFiles{Cu: f.CU, Files: f.Files}
+	}
+	return ret, nil
+}
+
+func (s *Server) AddrToLine(ctx context.Context, req *durinspb.AddrToLineRequest) (*durinspb.SourceLine, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	l, err := b.Program.AddrToLine(req.Address)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &durinspb.SourceLine{
+		File:    l.File,
+		Line:    int64(l.Line),
+		Column:  int64(l.Column),
+		Address: l.Address,
+	}, nil
+}
+
+func (s *Server) LineToAddrs(ctx context.Context, req *durinspb.LineToAddrsRequest) (*durinspb.LineToAddrsResponse, error) {
+	if req.File == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing file")
+	}
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	addrs, err := b.Program.LineToAddrs(req.File, int(req.Line))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &durinspb.LineToAddrsResponse{Addresses: addrs}, nil
+}
+
+func (s *Server) Symbolize(ctx context.Context, req *durinspb.SymbolizeRequest) (*durinspb.Symbol, error) {
+	b, err := s.binaries.Get(req.BinaryId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	sym, err := b.Program.Symbolize(req.Address)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &durinspb.Symbol{
+		Symbol:   sym.String(),
+		Variable: sym.Variable,
+		Address:  sym.Address,
+		Path:     sym.Path,
+		Offset:   int64(sym.Offset),
+	}, nil
+}
+
+// Converts a proxy, including all of its members, to its message
+func toType(p parser.TypeDefProxy) (*durinspb.Type, error) {
+	members, err := p.Members()
+	if err != nil {
+		return nil, err
+	}
+	ret := &durinspb.Type{
+		Name:        p.Name(),
+		BitSize:     int64(p.BitSize()),
+		ArrayRanges: make([]*durinspb.ArrayRange, len(p.ArrayRanges())),
+		Members:     make([]*durinspb.Member, len(members)),
+	}
+	for i, n := range p.ArrayRanges() {
+		ret.ArrayRanges[i] = &durinspb.ArrayRange{Count: int64(n)}
+	}
+	for i, m := range members {
+		t, err := toType(m)
+		if err != nil {
+			return nil, err
+		}
+		ret.Members[i] = &durinspb.Member{
+			Name:      m.Name(),
+			BitOffset: int64(m.BitOffset()),
+			Type:      t,
+		}
+	}
+	return ret, nil
+}
+
+func toValue(v server.Value) *durinspb.Value {
+	return &durinspb.Value{
+		Path:    v.Path,
+		Address: uint64(v.Address),
+		Data:    v.Bytes,
+		Integer: v.Uint,
+	}
+}
+
+// Returns the status best describing this error
+func toStatus(err error) error {
+	return status.Error(codeFor(err), err.Error())
+}
+
+func codeFor(err error) codes.Code {
+	switch {
+	case errors.Is(err, server.ErrUnknownBinary), errors.Is(err, parser.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, parser.ErrBadPath), errors.Is(err, parser.ErrOutOfRange), errors.Is(err, parser.ErrBadValue):
+		return codes.InvalidArgument
+	case errors.Is(err, parser.ErrNoClient), errors.Is(err, parser.ErrNoLocation), errors.Is(err, parser.ErrNoSize):
+		return codes.FailedPrecondition
+	case errors.Is(err, parser.ErrUnsupportedForm):
+		return codes.Unimplemented
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,183 @@
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/jdginn/durins-door/client/file"
+	"github.com/jdginn/durins-door/server"
+	"github.com/jdginn/durins-door/server/grpc"
+	"github.com/jdginn/durins-door/server/grpc/durinspb"
+)
+
+var testcaseFilename = "../../testcase-compiler/testcase.dwarf"
+
+// Serves s over an in-memory connection and returns a client for it
+func dial(t *testing.T, s *grpc.Server) durinspb.DurinsDoorClient {
+	lis := bufconn.Listen(1 << 20)
+	gs := gogrpc.NewServer()
+	s.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := gogrpc.Dial("bufnet",
+		gogrpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		gogrpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return durinspb.NewDurinsDoorClient(conn)
+}
+
+func newServer(t *testing.T) *grpc.Server {
+	s := grpc.NewServer()
+	b, err := server.LoadBinary("testcase", testcaseFilename, nil)
+	assert.NoError(t, err)
+	s.Add(b)
+	return s
+}
+
+func TestLookups(t *testing.T) {
+	c := dial(t, newServer(t))
+	ctx := context.Background()
+
+	binaries, err := c.ListBinaries(ctx, &durinspb.ListBinariesRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(binaries.Binaries))
+	assert.Equal(t, "testcase", binaries.Binaries[0].Id)
+
+	cus, err := c.ListCUs(ctx, &durinspb.ListCUsRequest{BinaryId: "testcase"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testcase.cpp"}, cus.Names)
+
+	entries, err := c.LookupEntries(ctx, &durinspb.LookupEntriesRequest{BinaryId: "testcase", Name: "Driver", Tag: "DW_TAG_structure_type"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries.Entries))
+	assert.Equal(t, "StructType", entries.Entries[0].Tag)
+
+	team, err := c.GetType(ctx, &durinspb.GetTypeRequest{BinaryId: "testcase", Name: "Team"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(48*8), team.BitSize)
+	assert.Equal(t, 6, len(team.Members))
+	drivers := team.Members[0]
+	assert.Equal(t, "drivers", drivers.Name)
+	assert.Equal(t, int64(2), drivers.Type.ArrayRanges[0].Count)
+	assert.Equal(t, "car_number", drivers.Type.Members[1].Name)
+	assert.Equal(t, int64(32), drivers.Type.Members[1].BitOffset)
+
+	teams, err := c.GetVariable(ctx, &durinspb.GetVariableRequest{BinaryId: "testcase", Name: "formula_1_teams"})
+	assert.NoError(t, err)
+	assert.NotZero(t, teams.Address)
+	assert.Equal(t, "Team", teams.Type.Name)
+}
+
+func TestReadWriteWatch(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s)
+	ctx := context.Background()
+
+	_, err := c.Read(ctx, &durinspb.ReadRequest{BinaryId: "testcase", Path: "formula_1_teams"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	teams, err := c.GetVariable(ctx, &durinspb.GetVariableRequest{BinaryId: "testcase", Name: "formula_1_teams"})
+	assert.NoError(t, err)
+	f, err := os.Create(filepath.Join(t.TempDir(), "memory.bin"))
+	assert.NoError(t, err)
+	fc, err := file.New(f)
+	assert.NoError(t, err)
+	assert.NoError(t, fc.Write(0, make([]byte, 96)))
+	fc.SetOffset(int64(teams.Address))
+	b, err := server.LoadBinary("testcase", testcaseFilename, fc)
+	assert.NoError(t, err)
+	s.Add(b)
+
+	path := "formula_1_teams[1].drivers[0].car_number"
+	v, err := c.Write(ctx, &durinspb.WriteRequest{BinaryId: "testcase", Path: path, Value: &durinspb.WriteRequest_Integer{Integer: 44}})
+	assert.NoError(t, err)
+	assert.Equal(t, teams.Address+48+4, v.Address)
+	assert.Equal(t, []byte{44, 0, 0, 0}, v.Data)
+	assert.Equal(t, uint64(44), v.GetInteger())
+
+	v, err = c.Write(ctx, &durinspb.WriteRequest{BinaryId: "testcase", Path: "formula_1_teams[1].drivers[0].initials", Value: &durinspb.WriteRequest_Data{Data: []byte("LH")}})
+	assert.NoError(t, err)
+	v, err = c.Read(ctx, &durinspb.ReadRequest{BinaryId: "testcase", Path: "formula_1_teams[1].drivers[0]"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'L', 'H', 0, 0, 44, 0, 0, 0, 0, 0, 0, 0}, v.Data)
+	assert.Nil(t, v.Integer)
+
+	watchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	stream, err := c.Watch(watchCtx, &durinspb.WatchRequest{BinaryId: "testcase", Path: path, IntervalMs: 5})
+	assert.NoError(t, err)
+	v, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(44), v.GetInteger())
+	_, err = b.WriteUint(path, 33)
+	assert.NoError(t, err)
+	v, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(33), v.GetInteger())
+}
+
+func TestSourceLines(t *testing.T) {
+	c := dial(t, newServer(t))
+	ctx := context.Background()
+
+	files, err := c.ListFiles(ctx, &durinspb.ListFilesRequest{BinaryId: "testcase"})
+	assert.NoError(t, err)
+	assert.Equal(t, "testcase.cpp", files.Cus[0].Cu)
+	assert.Contains(t, files.Cus[0].Files, "/tmp/tc/testcase.cpp")
+
+	line, err := c.AddrToLine(ctx, &durinspb.AddrToLineRequest{BinaryId: "testcase", Address: 0x401114})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), line.Line)
+	assert.Equal(t, uint64(0x401110), line.Address)
+	_, err = c.AddrToLine(ctx, &durinspb.AddrToLineRequest{BinaryId: "testcase", Address: 0})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	addrs, err := c.LineToAddrs(ctx, &durinspb.LineToAddrsRequest{BinaryId: "testcase", File: "testcase.cpp", Line: 30})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0x401110}, addrs.Addresses)
+	_, err = c.LineToAddrs(ctx, &durinspb.LineToAddrsRequest{BinaryId: "testcase", Line: 30})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSymbolize(t *testing.T) {
+	c := dial(t, newServer(t))
+	ctx := context.Background()
+
+	teams, err := c.GetVariable(ctx, &durinspb.GetVariableRequest{BinaryId: "testcase", Name: "formula_1_teams"})
+	assert.NoError(t, err)
+	sym, err := c.Symbolize(ctx, &durinspb.SymbolizeRequest{BinaryId: "testcase", Address: teams.Address + 48 + 25})
+	assert.NoError(t, err)
+	assert.Equal(t, "formula_1_teams[1].sponsors[0] + 1", sym.Symbol)
+	assert.Equal(t, teams.Address, sym.Address)
+	_, err = c.Symbolize(ctx, &durinspb.SymbolizeRequest{BinaryId: "testcase", Address: 0x10})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestErrors(t *testing.T) {
+	c := dial(t, newServer(t))
+	ctx := context.Background()
+
+	_, err := c.ListCUs(ctx, &durinspb.ListCUsRequest{BinaryId: "other"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = c.GetType(ctx, &durinspb.GetTypeRequest{BinaryId: "testcase", Name: "badname"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = c.LookupEntries(ctx, &durinspb.LookupEntriesRequest{BinaryId: "testcase"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = c.Read(ctx, &durinspb.ReadRequest{BinaryId: "testcase", Path: "formula_1_teams["})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = c.Write(ctx, &durinspb.WriteRequest{BinaryId: "testcase", Path: "formula_1_teams"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
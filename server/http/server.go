@@ -0,0 +1,388 @@
+// Package http serves the types and variables of one or more binaries over
+// an HTTP/JSON API.
+//
+// Each binary is loaded under an ID of the caller's choosing and all of its
+// endpoints live under /binaries/{id}:
+//
+//	GET /binaries                              list loaded binaries
+//	GET /binaries/{id}/cus                     list compile units
+//	GET /binaries/{id}/entries?name=&tag=      search entries by name and tag
+//	GET /binaries/{id}/types/{name}            a TypeDefProxy
+//	GET /binaries/{id}/variables/{name}        a VariableProxy
+//	GET /binaries/{id}/values/{path}           read a variable or field
+//	PUT /binaries/{id}/values/{path}           write a variable or field
+//	GET /binaries/{id}/files                   list the source files of each compile unit
+//	GET /binaries/{id}/lines/{address}         the source line of an address
+//	GET /binaries/{id}/addresses?file=&line=   the addresses of a source line
+//	GET /binaries/{id}/symbols/{address}       the variable, member and element at an address
+//
+// Paths are written as in C, for example "teams[1].drivers[0].car_number".
+// Values are read and written through the client configured for the binary
+// and are exchanged as hex-encoded bytes in target memory order, plus an
+// integer for values of at most 8 bytes.
+package http
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jdginn/durins-door/parser"
+	"github.com/jdginn/durins-door/server"
+)
+
+// Server is an http.Handler serving the API for the binaries in its registry
+type Server struct {
+	binaries *server.Registry
+}
+
+// Returns a server with no binaries loaded
+func NewServer() *Server {
+	return NewServerFromRegistry(server.NewRegistry())
+}
+
+// Returns a server for the binaries in this registry, which may be shared
+// with other servers
+func NewServerFromRegistry(r *server.Registry) *Server {
+	return &Server{
+		binaries: r,
+	}
+}
+
+// Makes a binary available under its ID, replacing any binary already
+// loaded with that ID
+func (s *Server) Add(b *server.Binary) {
+	s.binaries.Add(b)
+}
+
+// Removes the binary with this ID
+func (s *Server) Remove(id string) {
+	s.binaries.Remove(id)
+}
+
+type binaryJSON struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type entryJSON struct {
+	Name   string `json:"name"`
+	Tag    string `json:"tag"`
+	Offset int64  `json:"offset"`
+	CU     int64  `json:"cu"`
+}
+
+type valueJSON struct {
+	Path    string  `json:"path"`
+	Address int     `json:"address"`
+	Bytes   string  `json:"bytes"`
+	Value   *uint64 `json:"value,omitempty"`
+}
+
+// The body of a PUT to a value. Exactly one of the fields must be set.
+type writeBody struct {
+	Bytes *string `json:"bytes"`
+	Value *uint64 `json:"value"`
+}
+
+type cuFilesJSON struct {
+	CU    string   `json:"cu"`
+	Files []string `json:"files"`
+}
+
+type sourceLineJSON struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Address uint64 `json:"address"`
+}
+
+type symbolJSON struct {
+	Symbol   string `json:"symbol"`
+	Variable string `json:"variable"`
+	Address  uint64 `json:"address"`
+	Path     string `json:"path"`
+	Offset   int    `json:"offset"`
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) ServeHTTP(w nethttp.ResponseWriter, r *nethttp.Request) {
+	parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+	if parts[0] != "binaries" {
+		writeError(w, nethttp.StatusNotFound, fmt.Errorf("No such endpoint %s", r.URL.Path))
+		return
+	}
+	if len(parts) == 1 {
+		if !allowMethods(w, r, nethttp.MethodGet) {
+			return
+		}
+		s.listBinaries(w)
+		return
+	}
+	b, err := s.binaries.Get(parts[1])
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	resource := ""
+	if len(parts) > 2 {
+		resource = parts[2]
+	}
+	arg := ""
+	if len(parts) > 3 {
+		arg = parts[3]
+	}
+	switch {
+	case resource == "cus" && len(parts) == 3:
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.listCUs(w, b)
+		}
+	case resource == "entries" && len(parts) == 3:
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.searchEntries(w, r, b)
+		}
+	case resource == "types" && arg != "":
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.getType(w, b, arg)
+		}
+	case resource == "variables" && arg != "":
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.getVariable(w, b, arg)
+		}
+	case resource == "values" && arg != "":
+		if !allowMethods(w, r, nethttp.MethodGet, nethttp.MethodPut) {
+			return
+		}
+		if r.Method == nethttp.MethodGet {
+			s.readValue(w, b, arg)
+		} else {
+			s.writeValue(w, r, b, arg)
+		}
+	case resource == "files" && len(parts) == 3:
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.listFiles(w, b)
+		}
+	case resource == "lines" && arg != "":
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.addrToLine(w, b, arg)
+		}
+	case resource == "addresses" && len(parts) == 3:
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.lineToAddrs(w, r, b)
+		}
+	case resource == "symbols" && arg != "":
+		if allowMethods(w, r, nethttp.MethodGet) {
+			s.symbolize(w, b, arg)
+		}
+	default:
+		writeError(w, nethttp.StatusNotFound, fmt.Errorf("No such endpoint %s", r.URL.Path))
+	}
+}
+
+func (s *Server) listBinaries(w nethttp.ResponseWriter) {
+	binaries := s.binaries.List()
+	ret := make([]binaryJSON, len(binaries))
+	for i, b := range binaries {
+		ret[i] = binaryJSON{b.ID, b.Path}
+	}
+	writeJSON(w, nethttp.StatusOK, ret)
+}
+
+func (s *Server) listCUs(w nethttp.ResponseWriter, b *server.Binary) {
+	cus := b.Program.Index().CUs()
+	ret := make([]string, len(cus))
+	for i, cu := range cus {
+		ret[i] = cu.Name
+	}
+	writeJSON(w, nethttp.StatusOK, ret)
+}
+
+func (s *Server) searchEntries(w nethttp.ResponseWriter, r *nethttp.Request, b *server.Binary) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, nethttp.StatusBadRequest, errors.New("Missing query parameter name"))
+		return
+	}
+	entries := b.Lookup(name, r.URL.Query().Get("tag"))
+	ret := make([]entryJSON, len(entries))
+	for i, ie := range entries {
+		ret[i] = entryJSON{ie.Name, ie.Tag.String(), int64(ie.Offset), int64(ie.CU)}
+	}
+	writeJSON(w, nethttp.StatusOK, ret)
+}
+
+func (s *Server) getType(w nethttp.ResponseWriter, b *server.Binary, name string) {
+	p, err := b.Type(name)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, p)
+}
+
+func (s *Server) getVariable(w nethttp.ResponseWriter, b *server.Binary, name string) {
+	p, err := b.Variable(name)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, p)
+}
+
+func (s *Server) listFiles(w nethttp.ResponseWriter, b *server.Binary) {
+	files, err := b.Files()
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	ret := make([]cuFilesJSON, len(files))
+	for i, f := range files {
+		ret[i] = cuFilesJSON{f.CU, f.Files}
+	}
+	writeJSON(w, nethttp.StatusOK, ret)
+}
+
+func (s *Server) addrToLine(w nethttp.ResponseWriter, b *server.Binary, arg string) {
+	addr, err := server.ParseAddress(arg)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	l, err := b.Program.AddrToLine(addr)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, sourceLineJSON{l.File, l.Line, l.Column, l.Address})
+}
+
+func (s *Server) lineToAddrs(w nethttp.ResponseWriter, r *nethttp.Request, b *server.Binary) {
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		writeError(w, nethttp.StatusBadRequest, errors.New("Missing query parameter file"))
+		return
+	}
+	line, err := strconv.Atoi(r.URL.Query().Get("line"))
+	if err != nil {
+		writeError(w, nethttp.StatusBadRequest, errors.New("Missing or bad query parameter line"))
+		return
+	}
+	addrs, err := b.Program.LineToAddrs(file, line)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, addrs)
+}
+
+func (s *Server) symbolize(w nethttp.ResponseWriter, b *server.Binary, arg string) {
+	addr, err := server.ParseAddress(arg)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	sym, err := b.Program.Symbolize(addr)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, symbolJSON{sym.String(), sym.Variable, sym.Address, sym.Path, sym.Offset})
+}
+
+func (s *Server) readValue(w nethttp.ResponseWriter, b *server.Binary, path string) {
+	v, err := b.Read(path)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, toValueJSON(v))
+}
+
+func toValueJSON(v server.Value) valueJSON {
+	return valueJSON{
+		Path:    v.Path,
+		Address: v.Address,
+		Bytes:   hex.EncodeToString(v.Bytes),
+		Value:   v.Uint,
+	}
+}
+
+func (s *Server) writeValue(w nethttp.ResponseWriter, r *nethttp.Request, b *server.Binary, path string) {
+	var body writeBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, nethttp.StatusBadRequest, fmt.Errorf("Could not decode request body: %v", err))
+		return
+	}
+	if (body.Bytes == nil) == (body.Value == nil) {
+		writeError(w, nethttp.StatusBadRequest, errors.New("Exactly one of bytes and value must be set"))
+		return
+	}
+	var v server.Value
+	var err error
+	if body.Bytes != nil {
+		var data []byte
+		data, err = hex.DecodeString(*body.Bytes)
+		if err != nil {
+			writeError(w, nethttp.StatusBadRequest, fmt.Errorf("Could not decode bytes: %v", err))
+			return
+		}
+		v, err = b.Write(path, data)
+	} else {
+		v, err = b.WriteUint(path, *body.Value)
+	}
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, toValueJSON(v))
+}
+
+// Responds with 405 and returns false unless the request uses one of these
+// methods
+func allowMethods(w nethttp.ResponseWriter, r *nethttp.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, nethttp.StatusMethodNotAllowed, fmt.Errorf("Method %s not allowed", r.Method))
+	return false
+}
+
+// Returns the HTTP status best describing this error
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, server.ErrUnknownBinary), errors.Is(err, parser.ErrNotFound):
+		return nethttp.StatusNotFound
+	case errors.Is(err, parser.ErrBadPath), errors.Is(err, parser.ErrOutOfRange), errors.Is(err, parser.ErrBadValue):
+		return nethttp.StatusBadRequest
+	case errors.Is(err, parser.ErrNoClient):
+		return nethttp.StatusConflict
+	case errors.Is(err, parser.ErrNoLocation), errors.Is(err, parser.ErrUnsupportedForm), errors.Is(err, parser.ErrNoSize):
+		return nethttp.StatusUnprocessableEntity
+	default:
+		return nethttp.StatusInternalServerError
+	}
+}
+
+func writeErr(w nethttp.ResponseWriter, err error) {
+	writeError(w, statusFor(err), err)
+}
+
+func writeError(w nethttp.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorJSON{err.Error()})
+}
+
+func writeJSON(w nethttp.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,186 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/client/file"
+	"github.com/jdginn/durins-door/server"
+	"github.com/jdginn/durins-door/server/http"
+)
+
+var testcaseFilename = "../../testcase-compiler/testcase.dwarf"
+
+func do(t *testing.T, s nethttp.Handler, method string, url string, body string, v interface{}) int {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	b, err := io.ReadAll(rec.Body)
+	assert.NoError(t, err)
+	if v != nil {
+		assert.NoError(t, json.Unmarshal(b, v), string(b))
+	}
+	return rec.Code
+}
+
+func newServer(t *testing.T) *http.Server {
+	s := http.NewServer()
+	b, err := server.LoadBinary("testcase", testcaseFilename, nil)
+	assert.NoError(t, err)
+	s.Add(b)
+	return s
+}
+
+func TestListEndpoints(t *testing.T) {
+	s := newServer(t)
+
+	var binaries []map[string]string
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries", "", &binaries))
+	assert.Equal(t, []map[string]string{{"id": "testcase", "path": testcaseFilename}}, binaries)
+
+	var cus []string
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/cus", "", &cus))
+	assert.Equal(t, []string{"testcase.cpp"}, cus)
+
+	var entries []map[string]interface{}
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/entries?name=has_won_wdc", "", &entries))
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/entries?name=Driver&tag=variable", "", &entries))
+	assert.Equal(t, 0, len(entries))
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/entries?name=Driver&tag=DW_TAG_structure_type", "", &entries))
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "StructType", entries[0]["tag"])
+}
+
+func TestTypesAndVariables(t *testing.T) {
+	s := newServer(t)
+
+	var driver struct {
+		Name     string `json:"name"`
+		BitSize  int    `json:"bit_size"`
+		Children []struct {
+			Name string `json:"name"`
+		} `json:"children"`
+	}
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/types/Driver", "", &driver))
+	assert.Equal(t, "Driver", driver.Name)
+	assert.Equal(t, 96, driver.BitSize)
+	assert.Equal(t, 3, len(driver.Children))
+	assert.Equal(t, "car_number", driver.Children[1].Name)
+
+	var teams map[string]interface{}
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/variables/formula_1_teams", "", &teams))
+	assert.Equal(t, "formula_1_teams", teams["name"])
+	assert.NotZero(t, teams["address"])
+}
+
+func TestReadWriteValues(t *testing.T) {
+	s := newServer(t)
+	var teams struct {
+		Address int64 `json:"address"`
+	}
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/variables/formula_1_teams", "", &teams))
+
+	var errBody map[string]string
+	assert.Equal(t, 409, do(t, s, "GET", "/binaries/testcase/values/formula_1_teams", "", &errBody))
+	assert.Contains(t, errBody["error"], "no client")
+
+	// Serve memory from a scratch file starting at the variable's address
+	f, err := os.Create(filepath.Join(t.TempDir(), "memory.bin"))
+	assert.NoError(t, err)
+	c, err := file.New(f)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Write(0, make([]byte, 96)))
+	c.SetOffset(teams.Address)
+	b, err := server.LoadBinary("testcase", testcaseFilename, c)
+	assert.NoError(t, err)
+	s.Add(b)
+
+	var value struct {
+		Address int64   `json:"address"`
+		Bytes   string  `json:"bytes"`
+		Value   *uint64 `json:"value"`
+	}
+	path := "/binaries/testcase/values/formula_1_teams[1].drivers[0].car_number"
+	assert.Equal(t, 200, do(t, s, "PUT", path, `{"value": 44}`, &value))
+	assert.Equal(t, teams.Address+48+4, value.Address)
+	assert.Equal(t, "2c000000", value.Bytes)
+	assert.Equal(t, uint64(44), *value.Value)
+
+	assert.Equal(t, 200, do(t, s, "PUT", "/binaries/testcase/values/formula_1_teams[1].drivers[0].initials", `{"bytes": "4c48"}`, &value))
+	value.Value = nil
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/values/formula_1_teams[1].drivers[0]", "", &value))
+	assert.Equal(t, "4c4800002c00000000000000", value.Bytes)
+	assert.Nil(t, value.Value)
+
+	assert.Equal(t, 400, do(t, s, "PUT", path, `{"value": 1, "bytes": "01"}`, &errBody))
+	assert.Equal(t, 400, do(t, s, "PUT", path, `{"bytes": "01"}`, &errBody))
+	assert.Equal(t, 400, do(t, s, "PUT", path, `{"value": 4294967296}`, &errBody))
+	assert.Equal(t, 400, do(t, s, "GET", "/binaries/testcase/values/formula_1_teams[2]", "", &errBody))
+	assert.Equal(t, 400, do(t, s, "GET", "/binaries/testcase/values/formula_1_teams[", "", &errBody))
+}
+
+func TestErrors(t *testing.T) {
+	s := newServer(t)
+	var errBody map[string]string
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/other/cus", "", &errBody))
+	assert.Contains(t, errBody["error"], "unknown binary")
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/types/badname", "", &errBody))
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/variables/badname", "", &errBody))
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/variables/formula_1_teams/extra", "", &errBody))
+	assert.Equal(t, 404, do(t, s, "GET", "/elsewhere", "", &errBody))
+	assert.Equal(t, 400, do(t, s, "GET", "/binaries/testcase/entries", "", &errBody))
+	assert.Equal(t, 405, do(t, s, "POST", "/binaries/testcase/cus", "", &errBody))
+	// Types have no location
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/variables/Driver", "", &errBody))
+
+	s.Remove("testcase")
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/cus", "", &errBody))
+}
+
+func TestSourceLines(t *testing.T) {
+	s := newServer(t)
+
+	var files []map[string]interface{}
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/files", "", &files))
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "testcase.cpp", files[0]["cu"])
+	assert.Contains(t, files[0]["files"], "/tmp/tc/testcase.cpp")
+
+	var line map[string]interface{}
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/lines/0x401114", "", &line))
+	assert.Equal(t, float64(30), line["line"])
+	assert.Equal(t, float64(0x401110), line["address"])
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/lines/0", "", nil))
+	assert.Equal(t, 400, do(t, s, "GET", "/binaries/testcase/lines/pc", "", nil))
+
+	var addrs []uint64
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/addresses?file=testcase.cpp&line=30", "", &addrs))
+	assert.Equal(t, []uint64{0x401110}, addrs)
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/addresses?file=testcase.cpp&line=1", "", nil))
+	assert.Equal(t, 400, do(t, s, "GET", "/binaries/testcase/addresses?file=testcase.cpp", "", nil))
+}
+
+func TestSymbols(t *testing.T) {
+	s := newServer(t)
+
+	var teams map[string]interface{}
+	assert.Equal(t, 200, do(t, s, "GET", "/binaries/testcase/variables/formula_1_teams", "", &teams))
+	addr := int(teams["address"].(float64))
+
+	var sym map[string]interface{}
+	assert.Equal(t, 200, do(t, s, "GET", fmt.Sprintf("/binaries/testcase/symbols/%d", addr+48+4), "", &sym))
+	assert.Equal(t, "formula_1_teams[1].drivers[0].car_number", sym["symbol"])
+	assert.Equal(t, "[1].drivers[0].car_number", sym["path"])
+	assert.Equal(t, 404, do(t, s, "GET", "/binaries/testcase/symbols/0x10", "", nil))
+	assert.Equal(t, 400, do(t, s, "GET", "/binaries/testcase/symbols/x", "", nil))
+}
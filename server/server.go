@@ -0,0 +1,334 @@
+// Package server holds what the HTTP and gRPC APIs have in common: the set
+// of loaded binaries and the lookups, reads and writes both of them serve.
+//
+// Paths are written as in C, for example "teams[1].drivers[0].car_number",
+// and values are exchanged as bytes in target memory order.
+package server
+
+import (
+	"debug/dwarf"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jdginn/durins-door/client"
+	"github.com/jdginn/durins-door/explorer"
+	"github.com/jdginn/durins-door/parser"
+)
+
+// Returned when a request names a binary that has not been loaded
+var ErrUnknownBinary = errors.New("unknown binary")
+
+// A Binary is a loaded program along with the client used to access the
+// memory it describes
+type Binary struct {
+	ID      string
+	Path    string
+	Program *parser.Program
+	// May be nil, in which case values cannot be read or written
+	Client client.Client
+}
+
+// Loads the DWARF of the file at this path, looking for its separate debug
+// file and split DWARF in debugDirs or else plat.DefaultDebugDirs
+func LoadBinary(id string, path string, c client.Client, debugDirs ...string) (*Binary, error) {
+	return LoadBinaryArch(id, path, "", c, debugDirs...)
+}
+
+// Loads a binary as LoadBinary does, reading a universal Mach-O binary from
+// its slice for arch, such as arm64 or x86_64, or the host's when empty
+func LoadBinaryArch(id string, path string, arch string, c client.Client, debugDirs ...string) (*Binary, error) {
+	program, err := explorer.LoadProgram(path, arch, debugDirs)
+	if err != nil {
+		return nil, err
+	}
+	return &Binary{
+		ID:      id,
+		Path:    path,
+		Program: program,
+		Client:  c,
+	}, nil
+}
+
+// A Registry is the set of binaries served, keyed by ID. It is safe for
+// concurrent use.
+type Registry struct {
+	mux      sync.RWMutex
+	binaries map[string]*Binary
+}
+
+// Returns a registry with no binaries loaded
+func NewRegistry() *Registry {
+	return &Registry{
+		binaries: make(map[string]*Binary),
+	}
+}
+
+// Makes a binary available under its ID, replacing any binary already
+// loaded with that ID
+func (r *Registry) Add(b *Binary) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.binaries[b.ID] = b
+}
+
+// Removes the binary with this ID
+func (r *Registry) Remove(id string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.binaries, id)
+}
+
+// Returns the binary with this ID
+func (r *Registry) Get(id string) (*Binary, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	b, ok := r.binaries[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", id, ErrUnknownBinary)
+	}
+	return b, nil
+}
+
+// Returns every loaded binary, sorted by ID
+func (r *Registry) List() []*Binary {
+	r.mux.RLock()
+	ret := make([]*Binary, 0, len(r.binaries))
+	for _, b := range r.binaries {
+		ret = append(ret, b)
+	}
+	r.mux.RUnlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// Returns true if this tag is written as s, with or without a prefix, for
+// example "TagVariable", "DW_TAG_variable" or "variable"
+func MatchesTag(tag dwarf.Tag, s string) bool {
+	return shortTagName(tag.String()) == shortTagName(s)
+}
+
+func shortTagName(s string) string {
+	s = strings.ToLower(s)
+	s = strings.TrimPrefix(s, "dwarf.")
+	s = strings.TrimPrefix(s, "dw_tag_")
+	s = strings.TrimPrefix(s, "tag")
+	s = strings.ReplaceAll(s, "_", "")
+	// The only tag Go names differently from the DWARF standard
+	return strings.Replace(s, "structure", "struct", 1)
+}
+
+// Returns true if entries with this tag describe a type
+func IsTypeTag(tag dwarf.Tag) bool {
+	switch tag {
+	case dwarf.TagBaseType, dwarf.TagStructType, dwarf.TagUnionType, dwarf.TagClassType,
+		dwarf.TagEnumerationType, dwarf.TagTypedef:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the entries with this name, restricted to a tag unless tag is
+// empty. See MatchesTag for how tags may be written.
+func (b *Binary) Lookup(name string, tag string) []parser.IndexEntry {
+	ret := make([]parser.IndexEntry, 0)
+	for _, ie := range b.Program.Index().Lookup(name) {
+		if tag != "" && !MatchesTag(ie.Tag, tag) {
+			continue
+		}
+		ret = append(ret, ie)
+	}
+	return ret
+}
+
+// Returns the proxy for the first type with this name
+func (b *Binary) Type(name string) (*parser.TypeDefProxy, error) {
+	for _, ie := range b.Program.Index().Lookup(name) {
+		if !IsTypeTag(ie.Tag) {
+			continue
+		}
+		entry, err := b.Program.Index().Entry(ie)
+		if err != nil {
+			return nil, err
+		}
+		return parser.NewTypeDefProxy(b.Program.Types(), entry)
+	}
+	return nil, fmt.Errorf("Could not find type %s: %w", name, parser.ErrNotFound)
+}
+
+// Returns the proxy for a global variable, with the binary's client set
+func (b *Binary) Variable(name string) (*parser.VariableProxy, error) {
+	var lastErr error = fmt.Errorf("Could not find variable %s: %w", name, parser.ErrNotFound)
+	var found *parser.VariableProxy
+	for _, ie := range b.Program.Index().Lookup(name) {
+		if ie.Tag != dwarf.TagVariable && ie.Tag != dwarf.TagMember {
+			continue
+		}
+		entry, err := b.Program.Index().Entry(ie)
+		if err != nil {
+			return nil, err
+		}
+		// Static members are declared as members before DWARF 5; any other
+		// member is not a variable
+		if entry, err = b.Program.Index().Complete(entry); err != nil {
+			return nil, err
+		}
+		if entry.Tag != dwarf.TagVariable {
+			continue
+		}
+		// Declarations have no location; keep looking for the definition,
+		// settling for the symbol table only if none has one
+		p, err := b.Program.Variable(entry)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		found = p
+		if p.Source == parser.SourceDWARF {
+			break
+		}
+	}
+	if found == nil {
+		return nil, lastErr
+	}
+	if b.Client != nil {
+		found.SetClient(b.Client)
+	}
+	return found, nil
+}
+
+// Locates the variable or field at this path
+func (b *Binary) Field(path string) (parser.Field, error) {
+	name, fieldPath := parser.SplitVariablePath(path)
+	p, err := b.Variable(name)
+	if err != nil {
+		return parser.Field{}, err
+	}
+	return p.Field(fieldPath)
+}
+
+// The source files of one compile unit, in the order of its line table
+type CUFiles struct {
+	CU    string
+	Files []string
+}
+
+// Returns the source files of every named compile unit
+func (b *Binary) Files() ([]CUFiles, error) {
+	cus := b.Program.Index().CUs()
+	ret := make([]CUFiles, 0, len(cus))
+	for _, cu := range cus {
+		files, err := b.Program.Files(cu.Offset)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, CUFiles{CU: cu.Name, Files: files})
+	}
+	return ret, nil
+}
+
+// Parses a line of a file written as file:line
+func ParseFileLine(s string) (string, int, error) {
+	colon := strings.LastIndex(s, ":")
+	if colon <= 0 {
+		return "", 0, fmt.Errorf("Expected file:line, got %q: %w", s, parser.ErrBadValue)
+	}
+	line, err := strconv.Atoi(s[colon+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("Bad line number in %q: %w", s, parser.ErrBadValue)
+	}
+	return s[:colon], line, nil
+}
+
+// Parses an address written in decimal or, with a 0x prefix, in hex
+func ParseAddress(s string) (uint64, error) {
+	addr, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Bad address %q: %w", s, parser.ErrBadValue)
+	}
+	return addr, nil
+}
+
+// The value of a variable or field at the time it was read
+type Value struct {
+	Path    string
+	Address int
+	Bytes   []byte
+	// The bytes decoded as an unsigned integer in target byte order; nil
+	// for values longer than 8 bytes
+	Uint *uint64
+}
+
+// Reads the variable or field at this path
+func (b *Binary) Read(path string) (Value, error) {
+	name, fieldPath := parser.SplitVariablePath(path)
+	p, err := b.Variable(name)
+	if err != nil {
+		return Value{}, err
+	}
+	return b.value(p, path, fieldPath)
+}
+
+// Writes these bytes to the variable or field at this path and returns the
+// value read back
+func (b *Binary) Write(path string, data []byte) (Value, error) {
+	name, fieldPath := parser.SplitVariablePath(path)
+	p, err := b.Variable(name)
+	if err != nil {
+		return Value{}, err
+	}
+	if err := p.WriteField(fieldPath, data); err != nil {
+		return Value{}, err
+	}
+	return b.value(p, path, fieldPath)
+}
+
+// Writes an integer, encoded in target byte order to the size of the field,
+// to the variable or field at this path and returns the value read back
+func (b *Binary) WriteUint(path string, v uint64) (Value, error) {
+	name, fieldPath := parser.SplitVariablePath(path)
+	p, err := b.Variable(name)
+	if err != nil {
+		return Value{}, err
+	}
+	f, err := p.Field(fieldPath)
+	if err != nil {
+		return Value{}, err
+	}
+	data, err := parser.EncodeUint(v, (f.BitSize+7)/8, b.Program.ByteOrder())
+	if err != nil {
+		return Value{}, err
+	}
+	if err := p.WriteField(fieldPath, data); err != nil {
+		return Value{}, err
+	}
+	return b.value(p, path, fieldPath)
+}
+
+func (b *Binary) value(p *parser.VariableProxy, path string, fieldPath string) (Value, error) {
+	f, err := p.Field(fieldPath)
+	if err != nil {
+		return Value{}, err
+	}
+	data, err := p.ReadField(fieldPath)
+	if err != nil {
+		return Value{}, err
+	}
+	ret := Value{
+		Path:    path,
+		Address: f.Address,
+		Bytes:   data,
+	}
+	if len(data) <= 8 {
+		v, err := parser.DecodeUint(data, b.Program.ByteOrder())
+		if err != nil {
+			return Value{}, err
+		}
+		ret.Uint = &v
+	}
+	return ret, nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"debug/dwarf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jdginn/durins-door/internal/dwarftest"
+	"github.com/jdginn/durins-door/parser"
+)
+
+var testcaseFilename = "../testcase-compiler/testcase.dwarf"
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	_, err := r.Get("testcase")
+	assert.ErrorIs(t, err, ErrUnknownBinary)
+
+	b, err := LoadBinary("testcase", testcaseFilename, nil)
+	assert.NoError(t, err)
+	r.Add(b)
+	r.Add(&Binary{ID: "another"})
+	got, err := r.Get("testcase")
+	assert.NoError(t, err)
+	assert.Equal(t, b, got)
+	list := r.List()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "another", list[0].ID)
+
+	r.Remove("testcase")
+	_, err = r.Get("testcase")
+	assert.ErrorIs(t, err, ErrUnknownBinary)
+}
+
+func TestMatchesTag(t *testing.T) {
+	for _, s := range []string{"TagVariable", "dwarf.TagVariable", "DW_TAG_variable", "variable"} {
+		assert.True(t, MatchesTag(dwarf.TagVariable, s), s)
+	}
+	assert.True(t, MatchesTag(dwarf.TagStructType, "DW_TAG_structure_type"))
+	assert.False(t, MatchesTag(dwarf.TagVariable, "member"))
+}
+
+func TestBinaryLookups(t *testing.T) {
+	b, err := LoadBinary("testcase", testcaseFilename, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(b.Lookup("has_won_wdc", "")))
+	assert.Equal(t, 0, len(b.Lookup("Driver", "variable")))
+
+	p, err := b.Type("Driver")
+	assert.NoError(t, err)
+	assert.Equal(t, 96, p.BitSize())
+	_, err = b.Type("formula_1_teams")
+	assert.ErrorIs(t, err, parser.ErrNotFound)
+
+	_, err = b.Read("formula_1_teams[1]")
+	assert.ErrorIs(t, err, parser.ErrNoClient)
+}
+
+// A static member is found by name even though its declaration comes first
+// and a plain member shares its name
+func TestBinaryStaticMember(t *testing.T) {
+	intType := dwarftest.New(dwarf.TagBaseType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "int"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4})
+	plain := dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "Plain"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 4},
+	).With(dwarftest.New(dwarf.TagMember,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "count"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrDataMemberLoc, Val: 0}))
+	decl := dwarftest.New(dwarf.TagMember,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "count"},
+		dwarftest.Attr{Attr: dwarf.AttrType, Val: intType},
+		dwarftest.Attr{Attr: dwarf.AttrDeclaration, Val: true})
+	counter := dwarftest.New(dwarf.TagStructType,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "Counter"},
+		dwarftest.Attr{Attr: dwarf.AttrByteSize, Val: 1},
+	).With(decl)
+	// DW_OP_addr 0x5000
+	def := dwarftest.New(dwarf.TagVariable,
+		dwarftest.Attr{Attr: dwarf.AttrSpecification, Val: decl},
+		dwarftest.Attr{Attr: dwarf.AttrLocation, Val: []byte{0x03, 0x00, 0x50, 0, 0, 0, 0, 0, 0}})
+	data, err := dwarftest.Build(dwarftest.New(dwarf.TagCompileUnit,
+		dwarftest.Attr{Attr: dwarf.AttrName, Val: "counter.cpp"},
+	).With(intType, plain, counter, def))
+	assert.NoError(t, err)
+	prog, err := parser.NewProgram(data)
+	assert.NoError(t, err)
+
+	b := &Binary{ID: "counter", Program: prog}
+	p, err := b.Variable("count")
+	assert.NoError(t, err)
+	assert.Equal(t, 0x5000, p.Address)
+}